@@ -0,0 +1,40 @@
+package flags
+
+import (
+	"strings"
+	"sync"
+)
+
+// aliases maps the old names of renamed flags to their new ones
+type aliases struct {
+	names  map[string]string
+	warned sync.Map
+}
+
+// WithAlias has the old name of a renamed flag evaluate the new one, so code still using the old name keeps working.
+// A deprecation warning is logged the first time each alias is used
+func WithAlias(oldName, newName string) Option {
+	return func(c *Client) {
+		if c.aliases == nil {
+			c.aliases = &aliases{names: make(map[string]string)}
+		}
+		c.aliases.names[strings.ToLower(oldName)] = strings.ToLower(newName)
+	}
+}
+
+// canonical gives the lowercased name the flag is evaluated as, following its alias if it has one
+func (c *Client) canonical(name string) string {
+	name = strings.ToLower(name)
+	if c.aliases == nil {
+		return name
+	}
+
+	newName, ok := c.aliases.names[name]
+	if !ok {
+		return name
+	}
+	if _, warned := c.aliases.warned.LoadOrStore(name, struct{}{}); !warned {
+		c.warnf("flag %s is deprecated, it has been renamed to %s", name, newName)
+	}
+	return newName
+}
@@ -0,0 +1,89 @@
+package flags
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// background tracks the goroutines a client starts, so Close can wait for them to exit
+type background struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+	// revalidating is set while an offline first refetch is running
+	revalidating atomic.Bool
+}
+
+// goroutine runs fn in a tracked goroutine, it's false if the client is closed and fn wasn't started
+func (b *background) goroutine(fn func()) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return false
+	}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// stop marks the client closed, cancels its context, and waits for its goroutines, it's false if already stopped
+func (b *background) stop(cancel context.CancelFunc) bool {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return false
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	cancel()
+	b.wg.Wait()
+	return true
+}
+
+// withBackgroundRefresh checks every interval whether the cache is stale and refetches it if so, it's how the tests
+// get a background goroutine for Close to stop
+func withBackgroundRefresh(interval time.Duration) Option {
+	return func(c *Client) {
+		c.refreshInterval = interval
+	}
+}
+
+// start starts the clients background goroutines
+func (c *Client) start() {
+	if c.readOnly {
+		return
+	}
+
+	ctx := c.Cache.Context
+	if c.webSocketURL != "" {
+		c.background.goroutine(func() {
+			c.watchWebSocket(ctx)
+		})
+	}
+	if c.refreshInterval <= 0 {
+		return
+	}
+
+	c.background.goroutine(func() {
+		ticker := time.NewTicker(c.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.refreshIfStale(); err != nil {
+					c.reportError(c.errorf("failed to refresh flags in the background: %w", err))
+				}
+			}
+		}
+	})
+}
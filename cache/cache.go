@@ -3,25 +3,127 @@ package cache
 import (
 	"context"
 	"github.com/flags-gg/go-flags/flag"
+	"time"
 )
 
 type Caching interface {
 	Get(name string) (bool, bool)
+	GetFlag(name string) (flag.FeatureFlag, bool)
 	GetAll() ([]flag.FeatureFlag, error)
+	// GetAllMap is GetAll keyed by flag name, names are unique within a backend
+	GetAllMap() (map[string]flag.FeatureFlag, error)
+	// Count is how many flags are cached, without reading them
+	Count() (int, error)
 	Refresh(flags []flag.FeatureFlag, intervalAllowed int) error
 	ShouldRefreshCache() bool
+	// Clear removes every flag so the next read refreshes the cache
+	Clear() error
 	Init() error
+	Close() error
 }
 
+// Cache wraps a backend
+//
+// Deprecated: use Caching, backends are used through System directly
 type Cache struct {
 	Caching
 }
 
+// Namespacer is implemented by backends that can hold several independent flag sets at once
+type Namespacer interface {
+	Namespace(namespace string) (Caching, error)
+}
+
+// Dropper is implemented by namespaced backends that can remove the namespace altogether, not just its flags
+type Dropper interface {
+	Drop() error
+}
+
+// Change is a flag changing value, as recorded by a Historian
+type Change struct {
+	Name      string
+	Enabled   bool
+	ChangedAt time.Time
+}
+
+// Historian is implemented by backends that keep a record of each time a flags value changed
+type Historian interface {
+	History(name string, limit int) ([]Change, error)
+}
+
+// Sticker is implemented by backends that can remember the keys a rollout has enabled a flag for
+type Sticker interface {
+	Stuck(name, key string) (bool, error)
+	Stick(name, key string) error
+}
+
+// OverrideStorer is implemented by backends that can keep the overrides set on the client across restarts
+type OverrideStorer interface {
+	Overrides() (map[string]bool, error)
+	StoreOverride(name string, enabled bool) error
+	ClearOverrides() error
+}
+
+// UpdatedFlag is a flag with when it was last refreshed
+type UpdatedFlag struct {
+	flag.FeatureFlag
+	UpdatedAt time.Time
+}
+
+// Updater is implemented by backends that know when each flag was last refreshed
+type Updater interface {
+	// GetAllUpdated is GetAll with when each flag was last refreshed
+	GetAllUpdated() ([]UpdatedFlag, error)
+}
+
+// Expirer is implemented by backends that can say when they are next due a refresh
+type Expirer interface {
+	// NextRefresh is when the cache goes stale, ok is false if it has never been refreshed
+	NextRefresh() (time.Time, bool)
+}
+
+// DefaultInterval is how long the flags live for until the first refresh gives an interval
+const DefaultInterval = 60 * time.Second
+
+// Scheduler is implemented by backends that can be given the refresh interval to use before their first refresh
+type Scheduler interface {
+	// Seed sets the interval until a refresh gives one, the cache is left due a refresh and an interval from an
+	// earlier refresh is kept
+	Seed(interval time.Duration) error
+	// Interval is how long the flags live for, the seeded interval until the first refresh
+	Interval() time.Duration
+}
+
+// Indexer is implemented by backends that index the flags by their ID as well as their name
+type Indexer interface {
+	GetFlagByID(id string) (flag.FeatureFlag, bool)
+}
+
+// Evictor is implemented by backends that can evict flags to stay under a size limit
+type Evictor interface {
+	// Evicted reports whether the flag was evicted, refreshing the cache brings it back
+	Evicted(name string) bool
+}
+
+// System is the single entry point the client uses for caching, everything is routed through the CacheSystem backend
 type System struct {
 	Context context.Context
 
 	FileName *string
 	IsMemory bool
+	ReadOnly bool
+
+	ErrorHandler  func(error)
+	EncryptionKey []byte
+	Quiet         bool
+	// MemoryLimit caps how many flags the memory backend keeps, zero is no cap
+	MemoryLimit int
+	// QueryLogger is told about every statement the SQLite backend runs
+	QueryLogger QueryLogger
+	// SwapOnRefresh has the SQLite backend refresh a copy of its file and rename it over the live one
+	SwapOnRefresh bool
+	// DefaultInterval is seeded into the backend by InitDB, DefaultInterval the constant if it's zero
+	DefaultInterval time.Duration
 
 	CacheSystem Caching
 }
@@ -32,19 +134,336 @@ func NewSystem() *System {
 	}
 }
 
+// SetContext is the context the backend runs queries with, cancelling it aborts a refresh or read that's stuck
 func (s *System) SetContext(ctx context.Context) {
 	s.Context = ctx
+	if sqlLite, ok := s.CacheSystem.(*SQLLite); ok {
+		sqlLite.Context = ctx
+	}
 }
 
 func (s *System) SetFileName(fileName *string) {
 	s.FileName = fileName
 }
 
+// SetErrorHandler is called with errors the backend would otherwise only log
+func (s *System) SetErrorHandler(fn func(error)) {
+	s.ErrorHandler = fn
+	if sqlLite, ok := s.CacheSystem.(*SQLLite); ok {
+		sqlLite.ErrorHandler = fn
+	}
+}
+
+// SetQueryLogger is called with every statement the SQLite backend runs, the memory backend doesn't run any
+func (s *System) SetQueryLogger(fn QueryLogger) {
+	s.QueryLogger = fn
+	if sqlLite, ok := s.CacheSystem.(*SQLLite); ok {
+		sqlLite.QueryLogger = fn
+	}
+}
+
+// SetSwapOnRefresh has the SQLite backend refresh a copy of its file and swap it in, see SQLLite.SwapOnRefresh
+func (s *System) SetSwapOnRefresh() {
+	s.SwapOnRefresh = true
+	if sqlLite, ok := s.CacheSystem.(*SQLLite); ok {
+		sqlLite.SwapOnRefresh = true
+	}
+}
+
+// SetEncryptionKey encrypts the flags at rest, only the SQLite backend writes anything to disk
+func (s *System) SetEncryptionKey(key []byte) {
+	s.EncryptionKey = key
+}
+
+// SetQuiet stops the backend logging errors, they're still returned and passed to the error handler
+func (s *System) SetQuiet() {
+	s.Quiet = true
+	switch backend := s.CacheSystem.(type) {
+	case *SQLLite:
+		backend.Quiet = true
+	case *Memory:
+		backend.Quiet = true
+	}
+}
+
+// SetReadOnly only reads what's already in the database, nothing is ever written
+func (s *System) SetReadOnly() {
+	s.ReadOnly = true
+}
+
 func (s *System) NewMemory() {
 	s.IsMemory = true
-	s.CacheSystem = NewMemory()
+	memory := NewMemory()
+	memory.MaxEntries = s.MemoryLimit
+	memory.Quiet = s.Quiet
+	s.CacheSystem = memory
+}
+
+// SetMemoryLimit caps how many flags the memory backend keeps, the least recently read are evicted past it
+func (s *System) SetMemoryLimit(n int) {
+	s.MemoryLimit = n
+	if memory, ok := s.CacheSystem.(*Memory); ok {
+		memory.MaxEntries = n
+	}
 }
 
 func (s *System) NewSQLLite() {
-	s.CacheSystem = NewSQLLite(s.FileName)
+	s.IsMemory = false
+	sqlLite := NewSQLLite(s.FileName)
+	sqlLite.ReadOnly = s.ReadOnly
+	sqlLite.ErrorHandler = s.ErrorHandler
+	sqlLite.EncryptionKey = s.EncryptionKey
+	sqlLite.Quiet = s.Quiet
+	sqlLite.Context = s.Context
+	sqlLite.QueryLogger = s.QueryLogger
+	sqlLite.SwapOnRefresh = s.SwapOnRefresh
+	s.CacheSystem = sqlLite
+}
+
+// UseSQLLite picks SQLite as the backend, it's created by InitDB so the file name can still be set after
+func (s *System) UseSQLLite() {
+	s.IsMemory = false
+	s.CacheSystem = nil
+}
+
+// SetDefaultInterval is the interval the backend is seeded with, so every backend schedules its first refresh the same
+func (s *System) SetDefaultInterval(d time.Duration) {
+	s.DefaultInterval = d
+}
+
+// UseBackend picks a backend from outside the package, it's initialized by InitDB like the built in ones
+func (s *System) UseBackend(backend Caching) {
+	s.IsMemory = false
+	s.CacheSystem = backend
+}
+
+// InitDB initializes the backend, defaulting to SQLite if no backend has been chosen
+func (s *System) InitDB() error {
+	if s.CacheSystem == nil {
+		s.NewSQLLite()
+	}
+
+	if err := s.CacheSystem.Init(); err != nil {
+		return err
+	}
+	return s.seed(s.CacheSystem)
+}
+
+// seed gives the backend the default interval, if it can be scheduled
+func (s *System) seed(backend Caching) error {
+	scheduler, ok := backend.(Scheduler)
+	if !ok || s.ReadOnly {
+		return nil
+	}
+
+	interval := s.DefaultInterval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return scheduler.Seed(interval)
+}
+
+// Interval is how long the flags live for, zero if the backend can't say
+func (s *System) Interval() time.Duration {
+	scheduler, ok := s.CacheSystem.(Scheduler)
+	if !ok {
+		return 0
+	}
+	return scheduler.Interval()
+}
+
+// Namespaced gives a new System backed by the given namespace of this systems backend
+func (s *System) Namespaced(namespace string) (*System, error) {
+	namespacer, ok := s.CacheSystem.(Namespacer)
+	if !ok {
+		return nil, errorf(s.Quiet, "cache backend %T does not support namespaces", s.CacheSystem)
+	}
+
+	backend, err := namespacer.Namespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	if err := backend.Init(); err != nil {
+		return nil, err
+	}
+	if err := s.seed(backend); err != nil {
+		return nil, err
+	}
+
+	return &System{
+		Context:         s.Context,
+		FileName:        s.FileName,
+		IsMemory:        s.IsMemory,
+		ReadOnly:        s.ReadOnly,
+		ErrorHandler:    s.ErrorHandler,
+		EncryptionKey:   s.EncryptionKey,
+		Quiet:           s.Quiet,
+		MemoryLimit:     s.MemoryLimit,
+		QueryLogger:     s.QueryLogger,
+		DefaultInterval: s.DefaultInterval,
+		CacheSystem:     backend,
+	}, nil
+}
+
+// Drop removes the namespace and everything kept in it, a backend that can't drop is cleared instead
+func (s *System) Drop() error {
+	dropper, ok := s.CacheSystem.(Dropper)
+	if !ok {
+		return s.Clear()
+	}
+	return dropper.Drop()
+}
+
+// History gives the recorded value changes of the flag, newest first, if the backend keeps them
+func (s *System) History(name string, limit int) ([]Change, error) {
+	historian, ok := s.CacheSystem.(Historian)
+	if !ok {
+		return nil, errorf(s.Quiet, "cache backend %T does not keep history", s.CacheSystem)
+	}
+	return historian.History(name, limit)
+}
+
+// Stuck reports whether the key has been stuck to the flag, always false if the backend can't remember keys
+func (s *System) Stuck(name, key string) (bool, error) {
+	sticker, ok := s.CacheSystem.(Sticker)
+	if !ok {
+		return false, nil
+	}
+	return sticker.Stuck(name, key)
+}
+
+// Stick remembers the key is enabled for the flag
+func (s *System) Stick(name, key string) error {
+	sticker, ok := s.CacheSystem.(Sticker)
+	if !ok {
+		return errorf(s.Quiet, "cache backend %T does not support sticky rollouts", s.CacheSystem)
+	}
+	return sticker.Stick(name, key)
+}
+
+// GetAllUpdated gives every flag with when it was last refreshed
+func (s *System) GetAllUpdated() ([]UpdatedFlag, error) {
+	updater, ok := s.CacheSystem.(Updater)
+	if !ok {
+		return nil, errorf(s.Quiet, "cache backend %T does not track when flags were refreshed", s.CacheSystem)
+	}
+	return updater.GetAllUpdated()
+}
+
+// Overrides gives the stored overrides
+func (s *System) Overrides() (map[string]bool, error) {
+	storer, ok := s.CacheSystem.(OverrideStorer)
+	if !ok {
+		return nil, errorf(s.Quiet, "cache backend %T does not store overrides", s.CacheSystem)
+	}
+	return storer.Overrides()
+}
+
+// StoreOverride keeps the override so it's there after a restart
+func (s *System) StoreOverride(name string, enabled bool) error {
+	storer, ok := s.CacheSystem.(OverrideStorer)
+	if !ok {
+		return errorf(s.Quiet, "cache backend %T does not store overrides", s.CacheSystem)
+	}
+	return storer.StoreOverride(name, enabled)
+}
+
+// ClearOverrides removes every stored override
+func (s *System) ClearOverrides() error {
+	storer, ok := s.CacheSystem.(OverrideStorer)
+	if !ok {
+		return errorf(s.Quiet, "cache backend %T does not store overrides", s.CacheSystem)
+	}
+	return storer.ClearOverrides()
+}
+
+// GetFlagByID gives the flag with the ID, backends without an index are searched
+func (s *System) GetFlagByID(id string) (flag.FeatureFlag, bool) {
+	if id == "" {
+		return flag.FeatureFlag{}, false
+	}
+	if indexer, ok := s.CacheSystem.(Indexer); ok {
+		return indexer.GetFlagByID(id)
+	}
+
+	flags, err := s.CacheSystem.GetAll()
+	if err != nil {
+		return flag.FeatureFlag{}, false
+	}
+	for _, f := range flags {
+		if f.Details.ID == id {
+			return f, true
+		}
+	}
+	return flag.FeatureFlag{}, false
+}
+
+// Evicted reports whether the backend evicted the flag to stay under its size limit
+func (s *System) Evicted(name string) bool {
+	evictor, ok := s.CacheSystem.(Evictor)
+	if !ok {
+		return false
+	}
+	return evictor.Evicted(name)
+}
+
+// NextRefresh is when the cache goes stale, ok is false if it has never been refreshed or the backend can't say
+func (s *System) NextRefresh() (time.Time, bool) {
+	expirer, ok := s.CacheSystem.(Expirer)
+	if !ok {
+		return time.Time{}, false
+	}
+	return expirer.NextRefresh()
+}
+
+func validNamespace(namespace string) bool {
+	if namespace == "" {
+		return false
+	}
+	for _, r := range namespace {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *System) Get(name string) (bool, bool) {
+	return s.CacheSystem.Get(name)
+}
+
+func (s *System) GetFlag(name string) (flag.FeatureFlag, bool) {
+	return s.CacheSystem.GetFlag(name)
+}
+
+func (s *System) GetAll() ([]flag.FeatureFlag, error) {
+	return s.CacheSystem.GetAll()
+}
+
+func (s *System) GetAllMap() (map[string]flag.FeatureFlag, error) {
+	return s.CacheSystem.GetAllMap()
+}
+
+func (s *System) Count() (int, error) {
+	return s.CacheSystem.Count()
+}
+
+func (s *System) Refresh(flags []flag.FeatureFlag, intervalAllowed int) error {
+	return s.CacheSystem.Refresh(flags, intervalAllowed)
+}
+
+func (s *System) ShouldRefreshCache() bool {
+	return s.CacheSystem.ShouldRefreshCache()
+}
+
+func (s *System) Clear() error {
+	return s.CacheSystem.Clear()
+}
+
+func (s *System) Close() error {
+	if s.CacheSystem == nil {
+		return nil
+	}
+
+	return s.CacheSystem.Close()
 }
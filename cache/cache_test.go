@@ -0,0 +1,908 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/flags-gg/go-flags/flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSystems(t *testing.T) map[string]*System {
+	t.Helper()
+
+	memory := NewSystem()
+	memory.NewMemory()
+
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	sqlite := NewSystem()
+	sqlite.SetFileName(&fileName)
+
+	return map[string]*System{
+		"memory": memory,
+		"sqlite": sqlite,
+	}
+}
+
+func TestSystem_Backends(t *testing.T) {
+	for name, system := range newTestSystems(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := system.InitDB(); err != nil {
+				t.Fatalf("InitDB: %v", err)
+			}
+			defer func() {
+				if err := system.Close(); err != nil {
+					t.Errorf("Close: %v", err)
+				}
+			}()
+
+			if !system.ShouldRefreshCache() {
+				t.Error("Expected a fresh cache to need a refresh")
+			}
+
+			flags := []flag.FeatureFlag{
+				{Enabled: true, Details: flag.Details{Name: "enabled-flag", ID: "1"}},
+				{Enabled: false, Details: flag.Details{Name: "disabled-flag", ID: "2"}},
+			}
+			if err := system.Refresh(flags, 60); err != nil {
+				t.Fatalf("Refresh: %v", err)
+			}
+
+			if system.ShouldRefreshCache() {
+				t.Error("Expected cache to be fresh after refresh")
+			}
+
+			tests := []struct {
+				name       string
+				flagName   string
+				wantValue  bool
+				wantExists bool
+			}{
+				{
+					name:       "enabled flag",
+					flagName:   "enabled-flag",
+					wantValue:  true,
+					wantExists: true,
+				},
+				{
+					name:       "disabled flag",
+					flagName:   "disabled-flag",
+					wantValue:  false,
+					wantExists: true,
+				},
+				{
+					name:       "missing flag",
+					flagName:   "missing-flag",
+					wantValue:  false,
+					wantExists: false,
+				},
+			}
+			for _, tt := range tests {
+				value, exists := system.Get(tt.flagName)
+				if value != tt.wantValue || exists != tt.wantExists {
+					t.Errorf("%s: got (%v, %v), want (%v, %v)", tt.name, value, exists, tt.wantValue, tt.wantExists)
+				}
+			}
+
+			all, err := system.GetAll()
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			if len(all) != len(flags) {
+				t.Errorf("Expected %d flags, got %d", len(flags), len(all))
+			}
+
+			byName, err := system.GetAllMap()
+			if err != nil {
+				t.Fatalf("GetAllMap: %v", err)
+			}
+			if len(byName) != len(all) {
+				t.Errorf("Expected the map to have the same %d flags as the slice, got %d", len(all), len(byName))
+			}
+			for _, f := range all {
+				if got, ok := byName[f.Details.Name]; !ok || got.Enabled != f.Enabled {
+					t.Errorf("Expected %s in the map as %+v, got %+v (%v)", f.Details.Name, f, got, ok)
+				}
+			}
+
+			if err := system.Clear(); err != nil {
+				t.Fatalf("Clear: %v", err)
+			}
+			if _, exists := system.Get("enabled-flag"); exists {
+				t.Error("Expected no flags after clear")
+			}
+			if !system.ShouldRefreshCache() {
+				t.Error("Expected a cleared cache to need a refresh")
+			}
+		})
+	}
+}
+
+func TestSystem_Count(t *testing.T) {
+	for name, system := range newTestSystems(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := system.InitDB(); err != nil {
+				t.Fatalf("InitDB: %v", err)
+			}
+			defer func() {
+				_ = system.Close()
+			}()
+
+			if count, err := system.Count(); err != nil || count != 0 {
+				t.Errorf("Expected an empty cache to count 0, got %d (%v)", count, err)
+			}
+
+			for _, size := range []int{3, 0, 5, 1} {
+				flags := make([]flag.FeatureFlag, size)
+				for i := range flags {
+					flags[i] = flag.FeatureFlag{Enabled: i%2 == 0, Details: flag.Details{Name: fmt.Sprintf("flag-%d", i), ID: fmt.Sprint(i)}}
+				}
+				if err := system.Refresh(flags, 60); err != nil {
+					t.Fatalf("Refresh: %v", err)
+				}
+
+				count, err := system.Count()
+				if err != nil {
+					t.Fatalf("Count: %v", err)
+				}
+				all, err := system.GetAll()
+				if err != nil {
+					t.Fatalf("GetAll: %v", err)
+				}
+				// SQLite keeps the flags it has when a refresh has none
+				if count != len(all) || (size > 0 && count != size) {
+					t.Errorf("Expected the count to match the %d cached flags after refreshing %d, got %d", len(all), size, count)
+				}
+			}
+
+			if err := system.Clear(); err != nil {
+				t.Fatalf("Clear: %v", err)
+			}
+			if count, err := system.Count(); err != nil || count != 0 {
+				t.Errorf("Expected a cleared cache to count 0, got %d (%v)", count, err)
+			}
+		})
+	}
+}
+
+func TestSystem_NextRefresh(t *testing.T) {
+	for name, system := range newTestSystems(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := system.InitDB(); err != nil {
+				t.Fatalf("InitDB: %v", err)
+			}
+			defer func() {
+				_ = system.Close()
+			}()
+
+			if _, ok := system.NextRefresh(); ok {
+				t.Error("Expected a cache that was never refreshed to have no next refresh")
+			}
+
+			before := time.Now().Add(-time.Minute).Truncate(time.Second)
+			if err := system.Refresh([]flag.FeatureFlag{{Enabled: true, Details: flag.Details{Name: "flag", ID: "1"}}}, -60); err != nil {
+				t.Fatalf("Refresh: %v", err)
+			}
+			nextRefresh, ok := system.NextRefresh()
+			if !ok || nextRefresh.Before(before) || nextRefresh.After(time.Now().Add(-time.Minute)) {
+				t.Errorf("Expected the next refresh a minute ago, got %v (%v)", nextRefresh, ok)
+			}
+
+			if err := system.Clear(); err != nil {
+				t.Fatalf("Clear: %v", err)
+			}
+			if _, ok := system.NextRefresh(); ok {
+				t.Error("Expected a cleared cache to have no next refresh")
+			}
+		})
+	}
+}
+
+func TestSystem_GetFlagByID(t *testing.T) {
+	for name, system := range newTestSystems(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := system.InitDB(); err != nil {
+				t.Fatalf("InitDB: %v", err)
+			}
+			defer func() {
+				_ = system.Close()
+			}()
+
+			for _, flagName := range []string{"old-name", "new-name"} {
+				if err := system.Refresh([]flag.FeatureFlag{
+					{Enabled: true, Details: flag.Details{Name: flagName, ID: "1"}},
+					{Enabled: false, Details: flag.Details{Name: "other", ID: "2"}},
+				}, 60); err != nil {
+					t.Fatalf("Refresh: %v", err)
+				}
+
+				got, ok := system.GetFlagByID("1")
+				if !ok || got.Details.Name != flagName || got.Details.ID != "1" || !got.Enabled {
+					t.Errorf("Expected ID 1 to be %s, got %+v (%v)", flagName, got, ok)
+				}
+			}
+			if _, ok := system.GetFlagByID("3"); ok {
+				t.Error("Expected an unknown ID to be missing")
+			}
+			if _, ok := system.GetFlagByID(""); ok {
+				t.Error("Expected an empty ID to be missing")
+			}
+		})
+	}
+}
+
+func TestSQLLite_RefreshCancelled(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	system := NewSystem()
+	system.SetContext(ctx)
+	system.SetFileName(&fileName)
+	system.SetQuiet()
+	if err := system.InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer func() {
+		_ = system.Close()
+	}()
+	flags := []flag.FeatureFlag{{Enabled: true, Details: flag.Details{Name: "test-flag", ID: "1"}}}
+	if err := system.Refresh(flags, 60); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// another process holding the database locked
+	locker, err := getDBClient(nil, &fileName, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = locker.Close()
+	}()
+	conn, err := locker.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "ROLLBACK")
+		_ = conn.Close()
+	}()
+	if _, err := conn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); err != nil {
+		t.Fatalf("locking the database: %v", err)
+	}
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- system.Refresh(flags, 60)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// SQLite can't interrupt a wait on the lock, so the refresh returns once that wait ends rather than going on to
+	// the next statement, and the error is the cancellation rather than the database being locked
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected a context error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected the cancelled refresh to return within the busy timeout, took %s", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the cancelled refresh to return")
+	}
+
+	// once cancelled nothing else waits on the lock
+	start = time.Now()
+	if err := system.Refresh(flags, 60); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a context error, got %v", err)
+	}
+	if _, ok := system.Get("test-flag"); ok {
+		t.Error("Expected the read to be cancelled too")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected a refresh with a cancelled context to return straight away, took %s", elapsed)
+	}
+
+	// the cancelled refreshes leave the flags they would have replaced
+	if _, err := conn.ExecContext(context.Background(), "ROLLBACK"); err != nil {
+		t.Fatalf("unlocking the database: %v", err)
+	}
+	other := NewSystem()
+	other.SetFileName(&fileName)
+	other.SetQuiet()
+	if err := other.InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer func() {
+		_ = other.Close()
+	}()
+	if enabled, ok := other.Get("test-flag"); !ok || !enabled {
+		t.Error("Expected the cancelled refresh to keep the stored flags")
+	}
+}
+
+func TestMemory_MaxEntries(t *testing.T) {
+	system := NewSystem()
+	system.SetMemoryLimit(2)
+	system.NewMemory()
+	if err := system.InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	flags := []flag.FeatureFlag{
+		{Enabled: true, Details: flag.Details{Name: "flag-a", ID: "1"}},
+		{Enabled: true, Details: flag.Details{Name: "flag-b", ID: "2"}},
+		{Enabled: true, Details: flag.Details{Name: "flag-c", ID: "3"}},
+		{Enabled: true, Details: flag.Details{Name: "flag-d", ID: "4"}},
+	}
+	resident := func(want ...string) {
+		t.Helper()
+		// evicted flags are still listed, only reads miss them
+		if count, _ := system.Count(); count != len(flags) {
+			t.Errorf("Expected %d flags counted, got %d", len(flags), count)
+		}
+		if all, _ := system.GetAllMap(); len(all) != len(flags) {
+			t.Errorf("Expected %d flags listed, got %d", len(flags), len(all))
+		}
+		for _, name := range want {
+			if _, ok := system.Get(name); !ok {
+				t.Errorf("Expected %s to be kept", name)
+			}
+		}
+	}
+
+	// nothing has been read yet, so the first flags are kept
+	if err := system.Refresh(flags, 60); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	resident("flag-a", "flag-b")
+	for _, name := range []string{"flag-c", "flag-d"} {
+		if _, ok := system.Get(name); ok {
+			t.Errorf("Expected %s to be evicted", name)
+		}
+	}
+	if system.Evicted("flag-a") || system.Evicted("unknown") {
+		t.Error("Expected only evicted flags to be reported as evicted")
+	}
+	if f, ok := system.GetFlagByID("4"); !ok || f.Details.Name != "flag-d" {
+		t.Errorf("Expected an evicted flag to still be found by ID, got %v", f)
+	}
+
+	// flag-b was read, then flag-c was asked for, so they're the most recent
+	system.Get("flag-b")
+	if !system.Evicted("flag-c") {
+		t.Fatal("Expected flag-c to be evicted")
+	}
+	if err := system.Refresh(flags, 60); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	resident("flag-b", "flag-c")
+	if !system.Evicted("flag-a") || !system.Evicted("flag-d") {
+		t.Error("Expected the least recently read flags to be evicted")
+	}
+
+	if err := system.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if system.Evicted("flag-a") {
+		t.Error("Expected a cleared cache to have nothing evicted")
+	}
+}
+
+func TestSystem_InitDBDefaultsToSQLite(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	system := NewSystem()
+	system.SetFileName(&fileName)
+
+	if err := system.InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer func() {
+		_ = system.Close()
+	}()
+
+	if _, ok := system.CacheSystem.(*SQLLite); !ok {
+		t.Errorf("Expected SQLite backend, got %T", system.CacheSystem)
+	}
+}
+
+func TestCaching_InitThroughInterface(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	backends := map[string]Caching{
+		"memory": NewMemory(),
+		"sqlite": NewSQLLite(&fileName),
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			if err := backend.Init(); err != nil {
+				t.Fatalf("Init: %v", err)
+			}
+			defer func() {
+				_ = backend.Close()
+			}()
+
+			if err := backend.Refresh([]flag.FeatureFlag{
+				{Enabled: true, Details: flag.Details{Name: "test-flag", ID: "1"}},
+			}, 60); err != nil {
+				t.Fatalf("Refresh after Init: %v", err)
+			}
+
+			enabled, exists := backend.Get("test-flag")
+			if !enabled || !exists {
+				t.Errorf("Expected test-flag to be enabled and exist, got (%v, %v)", enabled, exists)
+			}
+		})
+	}
+}
+
+func TestSQLLite_ReadOnlyOldSchema(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	old := NewSQLLite(&fileName)
+	db, err := old.getDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE flags (
+		name TEXT PRIMARY KEY,
+		enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := NewSQLLite(&fileName)
+	backend.ReadOnly = true
+	backend.Quiet = true
+	defer func() {
+		_ = backend.Close()
+	}()
+	err = backend.Init()
+	if err == nil {
+		t.Fatal("Expected Init to reject a read only database it can't read flags from")
+	}
+	for _, column := range []string{"flags.rollout", "flags.id", "cache_metadata.key"} {
+		if !strings.Contains(err.Error(), column) {
+			t.Errorf("Expected %s to be reported missing, got %v", column, err)
+		}
+	}
+}
+
+func TestSQLLite_MigratesOldSchema(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	old := NewSQLLite(&fileName)
+	db, err := old.getDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE flags (
+		name TEXT PRIMARY KEY,
+		enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := NewSQLLite(&fileName)
+	if err := backend.Init(); err != nil {
+		t.Fatalf("Init on an old schema: %v", err)
+	}
+	defer func() {
+		_ = backend.Close()
+	}()
+
+	rollout := 25
+	activeFrom := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := backend.Refresh([]flag.FeatureFlag{
+		{Enabled: true, Details: flag.Details{Name: "rollout-flag"}, Rollout: &rollout, ActiveFrom: &activeFrom},
+	}, 60); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	got, ok := backend.GetFlag("rollout-flag")
+	if !ok {
+		t.Fatal("Expected rollout-flag to exist")
+	}
+	if got.Rollout == nil || *got.Rollout != rollout {
+		t.Errorf("Expected rollout of %d, got %v", rollout, got.Rollout)
+	}
+	if got.ActiveFrom == nil || !got.ActiveFrom.Equal(activeFrom) || got.ActiveUntil != nil {
+		t.Errorf("Expected active from %v with no end, got %v until %v", activeFrom, got.ActiveFrom, got.ActiveUntil)
+	}
+}
+
+func TestSQLLite_Encryption(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	key := bytes.Repeat([]byte("k"), 32)
+
+	backend := NewSQLLite(&fileName)
+	backend.EncryptionKey = key
+	if err := backend.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	rollout := 40
+	flags := []flag.FeatureFlag{
+		{Enabled: true, Details: flag.Details{Name: "secret-enabled-flag", ID: "1"}, Rollout: &rollout},
+		{Enabled: false, Details: flag.Details{Name: "secret-disabled-flag", ID: "2"}},
+	}
+	if err := backend.Refresh(flags, 60); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	got, ok := backend.GetFlag("secret-enabled-flag")
+	if !ok || !got.Enabled || got.Rollout == nil || *got.Rollout != rollout {
+		t.Errorf("Expected secret-enabled-flag to round trip, got %+v (%v)", got, ok)
+	}
+	if enabled, exists := backend.Get("secret-disabled-flag"); enabled || !exists {
+		t.Errorf("Expected secret-disabled-flag to exist and be disabled, got (%v, %v)", enabled, exists)
+	}
+	all, err := backend.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 flags, got %d", len(all))
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plain := range []string{"secret-enabled-flag", "secret-disabled-flag", `"enabled"`} {
+		if bytes.Contains(raw, []byte(plain)) {
+			t.Errorf("Expected %q to not be stored in plaintext", plain)
+		}
+	}
+
+	rotated := NewSQLLite(&fileName)
+	rotated.EncryptionKey = bytes.Repeat([]byte("r"), 32)
+	if err := rotated.Init(); err != nil {
+		t.Fatalf("Init with rotated key: %v", err)
+	}
+	defer func() {
+		_ = rotated.Close()
+	}()
+	if _, ok := rotated.GetFlag("secret-enabled-flag"); ok {
+		t.Error("Expected flags written with the old key to be cleared")
+	}
+	if !rotated.ShouldRefreshCache() {
+		t.Error("Expected a rotated key to force a refresh")
+	}
+}
+
+func TestSQLLite_Overrides(t *testing.T) {
+	for _, key := range [][]byte{nil, bytes.Repeat([]byte("k"), 32)} {
+		fileName := filepath.Join(t.TempDir(), "flags.db")
+		backend := NewSQLLite(&fileName)
+		backend.EncryptionKey = key
+		if err := backend.Init(); err != nil {
+			t.Fatalf("Init: %v", err)
+		}
+		if err := backend.StoreOverride("secret-override", true); err != nil {
+			t.Fatalf("StoreOverride: %v", err)
+		}
+		if err := backend.StoreOverride("other-override", false); err != nil {
+			t.Fatalf("StoreOverride: %v", err)
+		}
+
+		overrides, err := backend.Overrides()
+		if err != nil {
+			t.Fatalf("Overrides: %v", err)
+		}
+		if len(overrides) != 2 || !overrides["secret-override"] || overrides["other-override"] {
+			t.Errorf("Expected both overrides back, got %v", overrides)
+		}
+		if err := backend.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		raw, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != nil && bytes.Contains(raw, []byte("secret-override")) {
+			t.Error("Expected the override name to not be stored in plaintext")
+		}
+
+		reopened := NewSQLLite(&fileName)
+		reopened.EncryptionKey = key
+		if err := reopened.Init(); err != nil {
+			t.Fatalf("Init: %v", err)
+		}
+		if err := reopened.ClearOverrides(); err != nil {
+			t.Fatalf("ClearOverrides: %v", err)
+		}
+		if overrides, err := reopened.Overrides(); err != nil || len(overrides) != 0 {
+			t.Errorf("Expected no overrides once cleared, got %v (%v)", overrides, err)
+		}
+		if err := reopened.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSQLLite_EncryptionInvalidKey(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	backend := NewSQLLite(&fileName)
+	backend.EncryptionKey = []byte("short")
+	if err := backend.Init(); err == nil {
+		t.Error("Expected an invalid key length to fail Init")
+	}
+}
+
+func TestSQLLite_EncryptionKeyMismatchOnRead(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+
+	writer := NewSQLLite(&fileName)
+	writer.EncryptionKey = bytes.Repeat([]byte("a"), 32)
+	if err := writer.Init(); err != nil {
+		t.Fatalf("Init writer: %v", err)
+	}
+	defer func() {
+		_ = writer.Close()
+	}()
+
+	var reported []error
+	reader := NewSQLLite(&fileName)
+	reader.EncryptionKey = bytes.Repeat([]byte("b"), 32)
+	reader.ErrorHandler = func(err error) {
+		reported = append(reported, err)
+	}
+	if err := reader.Init(); err != nil {
+		t.Fatalf("Init reader: %v", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	// the writer still has the old key, so it writes flags the reader can't decrypt
+	if err := writer.Refresh([]flag.FeatureFlag{
+		{Enabled: true, Details: flag.Details{Name: "secret-flag", ID: "1"}},
+	}, 60); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if got, ok := writer.GetFlag("secret-flag"); !ok || !got.Enabled {
+		t.Fatalf("Expected the writer to read its own flag, got %+v (%v)", got, ok)
+	}
+
+	if _, ok := reader.GetFlag("secret-flag"); ok {
+		t.Error("Expected a flag written with another key to be treated as missing")
+	}
+	all, err := reader.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(all) != 0 {
+		t.Errorf("Expected the cache to read as empty, got %+v", all)
+	}
+	if !reader.ShouldRefreshCache() {
+		t.Error("Expected an unreadable flag to force a refresh")
+	}
+	if len(reported) == 0 {
+		t.Error("Expected the decrypt failure to be reported")
+	}
+
+	if err := reader.Refresh([]flag.FeatureFlag{
+		{Enabled: true, Details: flag.Details{Name: "secret-flag", ID: "1"}},
+	}, 60); err != nil {
+		t.Fatalf("Refresh with the new key: %v", err)
+	}
+	if got, ok := reader.GetFlag("secret-flag"); !ok || !got.Enabled {
+		t.Errorf("Expected the refetched flag to be readable, got %+v (%v)", got, ok)
+	}
+}
+
+func TestSQLLite_ReadConnection(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	sqlLite := NewSQLLite(&fileName)
+	if err := sqlLite.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := sqlLite.Refresh([]flag.FeatureFlag{
+		{Enabled: true, Details: flag.Details{Name: "enabled-flag", ID: "1"}},
+	}, 60); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	if enabled, ok := sqlLite.Get("enabled-flag"); !ok || !enabled {
+		t.Fatal("Expected enabled-flag to be read")
+	}
+	if sqlLite.ReadDB == nil || sqlLite.ReadDB == sqlLite.DB {
+		t.Fatal("Expected reads to use their own connection")
+	}
+	if _, err := sqlLite.ReadDB.Exec(`DELETE FROM flags`); err == nil {
+		t.Error("Expected the read connection to be read only")
+	}
+	var journalMode string
+	if err := sqlLite.DB.QueryRow(`PRAGMA journal_mode`).Scan(&journalMode); err != nil {
+		t.Fatalf("journal_mode: %v", err)
+	}
+	if journalMode != "wal" {
+		t.Errorf("Expected the database to be in WAL mode so reads don't wait on the writer, got %s", journalMode)
+	}
+
+	readDB := sqlLite.ReadDB
+	if err := sqlLite.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if sqlLite.DB != nil || sqlLite.ReadDB != nil {
+		t.Error("Expected both connections to be released")
+	}
+	if err := readDB.Ping(); err == nil {
+		t.Error("Expected the read connection to be closed")
+	}
+}
+
+func TestSQLLite_SwapOnRefresh(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	sqlLite := NewSQLLite(&fileName)
+	sqlLite.SwapOnRefresh = true
+	if err := sqlLite.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer func() {
+		_ = sqlLite.Close()
+	}()
+
+	// every flag in a set has the same value, so a read seeing a mix has seen a partial refresh
+	flagSet := func(generation int) []flag.FeatureFlag {
+		flags := make([]flag.FeatureFlag, 50)
+		for i := range flags {
+			flags[i] = flag.FeatureFlag{
+				Enabled: generation%2 == 0,
+				Details: flag.Details{Name: fmt.Sprintf("flag-%d", i), ID: fmt.Sprint(i)},
+				Value:   fmt.Sprint(generation),
+			}
+		}
+		return flags
+	}
+	if err := sqlLite.Refresh(flagSet(0), 60); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if err := sqlLite.Stick("flag-0", "user-1"); err != nil {
+		t.Fatalf("Stick: %v", err)
+	}
+
+	stop := make(chan struct{})
+	errs := make(chan error, 4)
+	for r := 0; r < 4; r++ {
+		go func() {
+			for {
+				select {
+				case <-stop:
+					errs <- nil
+					return
+				default:
+				}
+
+				flags, err := sqlLite.GetAll()
+				if err != nil {
+					errs <- fmt.Errorf("GetAll: %w", err)
+					return
+				}
+				if len(flags) != 50 {
+					errs <- fmt.Errorf("expected 50 flags, got %d", len(flags))
+					return
+				}
+				for _, f := range flags {
+					if f.Value != flags[0].Value {
+						errs <- fmt.Errorf("expected a single generation, got %s and %s", f.Value, flags[0].Value)
+						return
+					}
+				}
+				if _, ok := sqlLite.GetFlag("flag-49"); !ok {
+					errs <- fmt.Errorf("expected flag-49 to be read")
+					return
+				}
+			}
+		}()
+	}
+
+	for generation := 1; generation <= 20; generation++ {
+		if err := sqlLite.Refresh(flagSet(generation), 60); err != nil {
+			t.Fatalf("Refresh %d: %v", generation, err)
+		}
+	}
+	close(stop)
+	for r := 0; r < 4; r++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+
+	if f, ok := sqlLite.GetFlag("flag-0"); !ok || f.Value != "20" {
+		t.Errorf("Expected the last refresh to be live, got %+v", f)
+	}
+	history, err := sqlLite.History("flag-0", 0)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(history) != 21 {
+		t.Errorf("Expected the history to be carried across the swaps, got %d changes", len(history))
+	}
+	if stuck, err := sqlLite.Stuck("flag-0", "user-1"); err != nil || !stuck {
+		t.Errorf("Expected the sticky key to be carried across the swaps, got %t (%v)", stuck, err)
+	}
+	if _, err := os.Stat(fileName + ".swap"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the swap file to be renamed away, got %v", err)
+	}
+	if _, err := sqlLite.Namespace("ns_test"); err == nil {
+		t.Error("Expected a swapped cache not to be namespaced")
+	}
+}
+
+// BenchmarkSQLLite_ReadDuringRefresh reads flags while another goroutine keeps refreshing them, "writer" is reads
+// sharing the writers connection as they did before the read connection
+func BenchmarkSQLLite_ReadDuringRefresh(b *testing.B) {
+	flags := make([]flag.FeatureFlag, 200)
+	for i := range flags {
+		flags[i] = flag.FeatureFlag{Enabled: true, Details: flag.Details{Name: fmt.Sprintf("flag-%d", i), ID: fmt.Sprint(i)}}
+	}
+
+	for _, shared := range []bool{true, false} {
+		name := "read connection"
+		if shared {
+			name = "writer"
+		}
+		b.Run(name, func(b *testing.B) {
+			fileName := filepath.Join(b.TempDir(), "flags.db")
+			sqlLite := NewSQLLite(&fileName)
+			if err := sqlLite.Init(); err != nil {
+				b.Fatalf("Init: %v", err)
+			}
+			defer func() {
+				_ = sqlLite.Close()
+			}()
+			if shared {
+				sqlLite.ReadDB = sqlLite.DB
+			}
+			if err := sqlLite.Refresh(flags, 60); err != nil {
+				b.Fatalf("Refresh: %v", err)
+			}
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						_ = sqlLite.Refresh(flags, 60)
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					sqlLite.Get(fmt.Sprintf("flag-%d", i%len(flags)))
+					i++
+				}
+			})
+			b.StopTimer()
+
+			close(stop)
+			<-done
+		})
+	}
+}
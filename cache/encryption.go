@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/flags-gg/go-flags/flag"
+	"io"
+)
+
+// encryptor encrypts flags at rest with AES-GCM, names are stored as an HMAC so they can still be looked up
+type encryptor struct {
+	key   []byte
+	gcm   cipher.AEAD
+	keyID string
+	// quiet stops errors being logged
+	quiet bool
+}
+
+func newEncryptor(key []byte, quiet bool) (*encryptor, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errorf(quiet, "invalid encryption key: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errorf(quiet, "failed to create gcm: %v", err)
+	}
+
+	return &encryptor{
+		key:   key,
+		gcm:   gcm,
+		keyID: keyID(key),
+		quiet: quiet,
+	}, nil
+}
+
+// keyID identifies the key without giving it away, so a rotated key can be spotted
+func keyID(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("flags-key-id"))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+func (e *encryptor) name(name string) string {
+	mac := hmac.New(sha256.New, e.key)
+	mac.Write([]byte(name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (e *encryptor) seal(f flag.FeatureFlag) ([]byte, error) {
+	plain, err := json.Marshal(f)
+	if err != nil {
+		return nil, errorf(e.quiet, "failed to encode flag: %v", err)
+	}
+
+	nonce := make([]byte, e.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errorf(e.quiet, "failed to create nonce: %v", err)
+	}
+
+	return e.gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (e *encryptor) open(payload []byte) (flag.FeatureFlag, error) {
+	if len(payload) < e.gcm.NonceSize() {
+		return flag.FeatureFlag{}, errorf(e.quiet, "encrypted payload is too short")
+	}
+
+	nonce, sealed := payload[:e.gcm.NonceSize()], payload[e.gcm.NonceSize():]
+	plain, err := e.gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return flag.FeatureFlag{}, errorf(e.quiet, "failed to decrypt flag: %v", err)
+	}
+
+	var f flag.FeatureFlag
+	if err := json.Unmarshal(plain, &f); err != nil {
+		return flag.FeatureFlag{}, errorf(e.quiet, "failed to decode flag: %v", err)
+	}
+	return f, nil
+}
@@ -1,69 +1,355 @@
 package cache
 
 import (
+	"container/list"
 	"github.com/bugfixes/go-bugfixes/logs"
 	"github.com/flags-gg/go-flags/flag"
 	"sync"
 	"time"
 )
 
+var (
+	_ Caching   = (*Memory)(nil)
+	_ Sticker   = (*Memory)(nil)
+	_ Expirer   = (*Memory)(nil)
+	_ Indexer   = (*Memory)(nil)
+	_ Evictor   = (*Memory)(nil)
+	_ Updater   = (*Memory)(nil)
+	_ Scheduler = (*Memory)(nil)
+)
+
 type Memory struct {
-	Flags       sync.Map
+	Flags  sync.Map
+	Sticky sync.Map
+	// IDs maps each flags ID to its name
+	IDs         sync.Map
 	cacheTTL    int64
 	nextRefresh int64
-	mu          sync.Mutex
+	// refreshed is whether nextRefresh came from a refresh rather than Init or Clear
+	refreshed bool
+	// Quiet stops errors being logged, they're still returned
+	Quiet bool
+	// MaxEntries caps how many flags are kept, past it the least recently read are evicted, zero is no cap
+	MaxEntries int
+	// order is the kept flags, most recently read first, only used with MaxEntries
+	order    *list.List
+	resident map[string]*list.Element
+	// evicted are the flags from the last refresh that aren't kept for reads, they're still listed and counted
+	evicted map[string]flag.FeatureFlag
+	// updatedAt is when each flag was last refreshed
+	updatedAt map[string]time.Time
+	mu        sync.Mutex
 }
 
 func (m *Memory) Get(name string) (bool, bool) {
-	value, ok := m.Flags.Load(name)
+	featureFlag, ok := m.GetFlag(name)
 	if !ok {
 		return false, false
 	}
+	return featureFlag.Enabled, true
+}
+
+func (m *Memory) GetFlag(name string) (flag.FeatureFlag, bool) {
+	value, ok := m.Flags.Load(name)
+	if !ok {
+		return flag.FeatureFlag{}, false
+	}
 	featureFlag, ok := value.(flag.FeatureFlag)
 	if !ok {
-		return false, false
+		return flag.FeatureFlag{}, false
 	}
-	return featureFlag.Enabled, true
+	if m.MaxEntries > 0 {
+		m.touch(name)
+	}
+	return featureFlag, true
 }
 
 func (m *Memory) GetAll() ([]flag.FeatureFlag, error) {
 	var allFlags []flag.FeatureFlag
-	m.Flags.Range(func(key, value interface{}) bool {
-		allFlags = append(allFlags, flag.FeatureFlag{
-			Enabled: value.(bool),
-			Details: flag.Details{
-				Name: key.(string),
-			},
-		})
-		return true
+	m.each(func(_ string, featureFlag flag.FeatureFlag) {
+		allFlags = append(allFlags, featureFlag)
 	})
 
 	return allFlags, nil
 }
 
+func (m *Memory) GetAllMap() (map[string]flag.FeatureFlag, error) {
+	allFlags := make(map[string]flag.FeatureFlag)
+	m.each(func(name string, featureFlag flag.FeatureFlag) {
+		allFlags[name] = featureFlag
+	})
+
+	return allFlags, nil
+}
+
+func (m *Memory) GetAllUpdated() ([]UpdatedFlag, error) {
+	var allFlags []UpdatedFlag
+	m.each(func(name string, featureFlag flag.FeatureFlag) {
+		allFlags = append(allFlags, UpdatedFlag{FeatureFlag: featureFlag, UpdatedAt: m.updatedAt[name]})
+	})
+
+	return allFlags, nil
+}
+
+func (m *Memory) Count() (int, error) {
+	count := 0
+	m.each(func(_ string, _ flag.FeatureFlag) {
+		count++
+	})
+
+	return count, nil
+}
+
+// each calls fn with every flag from the last refresh, those evicted by MaxEntries included, so listing the flags
+// doesn't depend on which have been read lately
+func (m *Memory) each(fn func(name string, featureFlag flag.FeatureFlag)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Flags.Range(func(key, value interface{}) bool {
+		featureFlag, ok := value.(flag.FeatureFlag)
+		if !ok {
+			return true
+		}
+		fn(key.(string), featureFlag)
+		return true
+	})
+	for name, featureFlag := range m.evicted {
+		fn(name, featureFlag)
+	}
+}
+
 func (m *Memory) Refresh(flags []flag.FeatureFlag, intervalAllowed int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.Flags = sync.Map{}
+	// store the new set before removing the old one, so readers never see a gap
+	now := time.Now()
+	names := make(map[string]struct{}, len(flags))
+	ids := make(map[string]struct{}, len(flags))
+	updatedAt := make(map[string]time.Time, len(flags))
 	for _, f := range flags {
+		names[f.Details.Name] = struct{}{}
+		updatedAt[f.Details.Name] = now
 		m.Flags.Store(f.Details.Name, f)
+		if f.Details.ID != "" {
+			ids[f.Details.ID] = struct{}{}
+			m.IDs.Store(f.Details.ID, f.Details.Name)
+		}
 	}
+	m.Flags.Range(func(key, _ interface{}) bool {
+		if _, ok := names[key.(string)]; !ok {
+			m.Flags.Delete(key)
+		}
+		return true
+	})
+	m.IDs.Range(func(key, _ interface{}) bool {
+		if _, ok := ids[key.(string)]; !ok {
+			m.IDs.Delete(key)
+		}
+		return true
+	})
+	if m.MaxEntries > 0 {
+		m.evict(flags, names)
+	}
+	m.updatedAt = updatedAt
 	m.cacheTTL = int64(intervalAllowed)
-	m.nextRefresh = time.Now().Add(time.Duration(m.cacheTTL) * time.Second).Unix()
+	m.nextRefresh = now.Add(time.Duration(m.cacheTTL) * time.Second).Unix()
+	m.refreshed = true
 
 	return nil
 }
 
+// evict keeps the MaxEntries most recently read of the refreshed flags for reads, then those never read, the rest
+// are moved out of Flags so reading them refetches. It expects the caller to hold the mutex
+func (m *Memory) evict(flags []flag.FeatureFlag, names map[string]struct{}) {
+	order := list.New()
+	resident := make(map[string]*list.Element, m.MaxEntries)
+	if m.order != nil {
+		for e := m.order.Front(); e != nil; e = e.Next() {
+			name := e.Value.(string)
+			if _, ok := names[name]; ok {
+				resident[name] = order.PushBack(name)
+			}
+		}
+	}
+	for _, f := range flags {
+		if _, ok := resident[f.Details.Name]; !ok {
+			resident[f.Details.Name] = order.PushBack(f.Details.Name)
+		}
+	}
+
+	evicted := make(map[string]flag.FeatureFlag)
+	for order.Len() > m.MaxEntries {
+		oldest := order.Back()
+		name := order.Remove(oldest).(string)
+		delete(resident, name)
+		if value, ok := m.Flags.LoadAndDelete(name); ok {
+			evicted[name] = value.(flag.FeatureFlag)
+		}
+	}
+	m.order, m.resident, m.evicted = order, resident, evicted
+}
+
+// touch marks the flag as the most recently read
+func (m *Memory) touch(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.resident[name]; ok {
+		m.order.MoveToFront(elem)
+	}
+}
+
+// Evicted reports whether the flag was in the last refresh but evicted by MaxEntries, it's then marked as the most
+// recently read so the next refresh keeps it
+func (m *Memory) Evicted(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.evicted[name]; !ok {
+		return false
+	}
+	if elem, ok := m.resident[name]; ok {
+		m.order.MoveToFront(elem)
+	} else {
+		m.resident[name] = m.order.PushFront(name)
+	}
+	return true
+}
+
+// Metadata is when a cache is next due a refresh and how long its flags live for
+type Metadata struct {
+	NextRefresh time.Time
+	TTL         time.Duration
+	// Refreshed is false until the cache has been refreshed, NextRefresh is only a placeholder until then
+	Refreshed bool
+}
+
+// Metadata gives when the memory cache is next due a refresh and the TTL the API last asked for
+func (m *Memory) Metadata() Metadata {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return Metadata{
+		NextRefresh: time.Unix(m.nextRefresh, 0),
+		TTL:         time.Duration(m.cacheTTL) * time.Second,
+		Refreshed:   m.refreshed,
+	}
+}
+
+// NextRefresh is the NextRefresh of Metadata, ok is false if it has never been refreshed
+func (m *Memory) NextRefresh() (time.Time, bool) {
+	metadata := m.Metadata()
+	if !metadata.Refreshed {
+		// the placeholder isn't a schedule, SQLite has none either
+		return time.Time{}, false
+	}
+	return metadata.NextRefresh, true
+}
+
 func (m *Memory) ShouldRefreshCache() bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	return time.Now().Unix() > m.nextRefresh
 }
 
+func (m *Memory) Clear() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Flags.Range(func(key, _ interface{}) bool {
+		m.Flags.Delete(key)
+		return true
+	})
+	m.IDs.Range(func(key, _ interface{}) bool {
+		m.IDs.Delete(key)
+		return true
+	})
+	m.nextRefresh = 0
+	m.refreshed = false
+	m.order, m.resident, m.evicted = nil, nil, nil
+	m.updatedAt = nil
+
+	return nil
+}
+
+// GetFlagByID gives the flag with the ID, an evicted flag is still found so its name can be evaluated, which
+// refetches it
+func (m *Memory) GetFlagByID(id string) (flag.FeatureFlag, bool) {
+	name, ok := m.IDs.Load(id)
+	if !ok {
+		return flag.FeatureFlag{}, false
+	}
+	featureFlag, ok := m.GetFlag(name.(string))
+	if !ok {
+		featureFlag, ok = m.evictedFlag(name.(string))
+	}
+	if !ok || featureFlag.Details.ID != id {
+		return flag.FeatureFlag{}, false
+	}
+	return featureFlag, true
+}
+
+func (m *Memory) evictedFlag(name string) (flag.FeatureFlag, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	featureFlag, ok := m.evicted[name]
+	return featureFlag, ok
+}
+
+func (m *Memory) Stuck(name, key string) (bool, error) {
+	_, ok := m.Sticky.Load(stickyKey(name, key))
+	return ok, nil
+}
+
+func (m *Memory) Stick(name, key string) error {
+	m.Sticky.Store(stickyKey(name, key), struct{}{})
+	return nil
+}
+
+func stickyKey(name, key string) string {
+	return name + "\x00" + key
+}
+
 func (m *Memory) Init() error {
-	m.cacheTTL = 60
-	m.nextRefresh = time.Now().Add(time.Duration(-90) * time.Second).Unix()
+	return m.Seed(DefaultInterval)
+}
+
+func (m *Memory) Seed(interval time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.refreshed {
+		return nil
+	}
+	m.cacheTTL = int64(interval / time.Second)
+	// in the past, so the first read refreshes
+	m.nextRefresh = time.Now().Add(-interval).Unix()
+	return nil
+}
+
+func (m *Memory) Interval() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return time.Duration(m.cacheTTL) * time.Second
+}
+
+// Namespace gives a separate memory store, nothing is shared between namespaces
+func (m *Memory) Namespace(namespace string) (Caching, error) {
+	if !validNamespace(namespace) {
+		return nil, errorf(m.Quiet, "invalid namespace: %s", namespace)
+	}
+
+	memory := NewMemory()
+	memory.MaxEntries = m.MaxEntries
+	memory.Quiet = m.Quiet
+	return memory, nil
+}
+
+func (m *Memory) Close() error {
 	return nil
 }
 
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/flags-gg/go-flags/flag"
+)
+
+// Overrides gives the stored overrides, when encrypted each is sealed as a flag since its name is only kept hashed
+func (s *SQLLite) Overrides() (map[string]bool, error) {
+	defer s.holdReads()()
+	db, err := s.getReadDB()
+	if err != nil {
+		return nil, errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	rows, err := s.query(s.ctx(), db, fmt.Sprintf(`SELECT name, enabled, payload FROM %s`, s.table("overrides")))
+	if err != nil {
+		return nil, errorf(s.Quiet, "failed to query overrides: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.reportError(errorf(s.Quiet, "failed to close rows: %v", err))
+		}
+	}()
+
+	overrides := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var enabled bool
+		var payload []byte
+		if err := rows.Scan(&name, &enabled, &payload); err != nil {
+			return nil, errorf(s.Quiet, "failed to scan override: %v", err)
+		}
+		if s.encryptor != nil {
+			f, err := s.encryptor.open(payload)
+			if err != nil {
+				return nil, err
+			}
+			name, enabled = f.Details.Name, f.Enabled
+		}
+		overrides[name] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorf(s.Quiet, "failed to read overrides: %v", err)
+	}
+	return overrides, nil
+}
+
+// StoreOverride keeps the override, replacing any already stored for the flag
+func (s *SQLLite) StoreOverride(name string, enabled bool) error {
+	if s.ReadOnly {
+		return errorf(s.Quiet, "cannot store an override in a read only database")
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	defer s.holdReads()()
+
+	db, err := s.getDB()
+	if err != nil {
+		return errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	stored, storedEnabled := name, enabled
+	var payload []byte
+	if s.encryptor != nil {
+		payload, err = s.encryptor.seal(flag.FeatureFlag{Enabled: enabled, Details: flag.Details{Name: name}})
+		if err != nil {
+			return err
+		}
+		stored, storedEnabled = s.encryptor.name(name), false
+	}
+	if _, err := s.exec(s.ctx(), db, fmt.Sprintf(`INSERT OR REPLACE INTO %s (name, enabled, payload) VALUES ($1, $2, $3)`, s.table("overrides")), stored, storedEnabled, payload); err != nil {
+		return errorf(s.Quiet, "failed to store override: %v", err)
+	}
+	return nil
+}
+
+// ClearOverrides removes every stored override
+func (s *SQLLite) ClearOverrides() error {
+	if s.ReadOnly {
+		return errorf(s.Quiet, "cannot clear overrides in a read only database")
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	defer s.holdReads()()
+
+	db, err := s.getDB()
+	if err != nil {
+		return errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	if _, err := s.exec(s.ctx(), db, fmt.Sprintf(`DELETE FROM %s`, s.table("overrides"))); err != nil {
+		return errorf(s.Quiet, "failed to clear overrides: %v", err)
+	}
+	return nil
+}
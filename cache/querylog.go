@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// QueryLogger is called with each statement the SQLite backend runs, with its args, how long it took, and its error
+type QueryLogger func(query string, args []interface{}, d time.Duration, err error)
+
+// execer is a *sql.DB or *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// queryer is a *sql.DB or *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// exec runs the statement, telling the query logger about it if there is one
+func (s *SQLLite) exec(ctx context.Context, e execer, query string, args ...interface{}) (sql.Result, error) {
+	if s.QueryLogger == nil {
+		return e.ExecContext(ctx, query, args...)
+	}
+
+	start := time.Now()
+	result, err := e.ExecContext(ctx, query, args...)
+	s.QueryLogger(query, args, time.Since(start), err)
+	return result, err
+}
+
+// execStmt runs the prepared statement, query is what it was prepared from so the logger can be told
+func (s *SQLLite) execStmt(ctx context.Context, stmt *sql.Stmt, query string, args ...interface{}) (sql.Result, error) {
+	if s.QueryLogger == nil {
+		return stmt.ExecContext(ctx, args...)
+	}
+
+	start := time.Now()
+	result, err := stmt.ExecContext(ctx, args...)
+	s.QueryLogger(query, args, time.Since(start), err)
+	return result, err
+}
+
+func (s *SQLLite) query(ctx context.Context, q queryer, query string, args ...interface{}) (*sql.Rows, error) {
+	if s.QueryLogger == nil {
+		return q.QueryContext(ctx, query, args...)
+	}
+
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args...)
+	s.QueryLogger(query, args, time.Since(start), err)
+	return rows, err
+}
+
+// queryRow is QueryRowContext, the logger is told the error from running the query but not sql.ErrNoRows, which
+// only comes from the Scan
+func (s *SQLLite) queryRow(ctx context.Context, q queryer, query string, args ...interface{}) *sql.Row {
+	if s.QueryLogger == nil {
+		return q.QueryRowContext(ctx, query, args...)
+	}
+
+	start := time.Now()
+	row := q.QueryRowContext(ctx, query, args...)
+	s.QueryLogger(query, args, time.Since(start), row.Err())
+	return row
+}
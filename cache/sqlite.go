@@ -1,40 +1,145 @@
 package cache
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/bugfixes/go-bugfixes/logs"
 	"github.com/flags-gg/go-flags/flag"
 	_ "modernc.org/sqlite"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
-func getDBClient(db *sql.DB, fileName *string) (*sql.DB, error) {
+func getDBClient(db *sql.DB, fileName *string, readOnly, quiet bool) (*sql.DB, error) {
 	if db != nil {
 		return db, nil
 	}
 
-	if fileName != nil {
-		db, err := sql.Open("sqlite", fmt.Sprintf("%s?_pragma=busy_timeout=1000&pragma=journal_mode=WAL", *fileName))
-		if err != nil {
-			return nil, logs.Errorf("failed to open database: %v", err)
-		}
-		return db, nil
+	name := dbFileName(fileName)
+
+	// WAL lets the read connection read while a refresh is writing
+	dsn := fmt.Sprintf("%s?_pragma=busy_timeout=1000&_pragma=journal_mode(WAL)", name)
+	if readOnly {
+		dsn = fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout=1000", name)
 	}
 
-	db, err := sql.Open("sqlite", "/tmp/flags.db?_pragma=busy_timeout=1000&pragma=journal_mode=WAL")
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
-		return nil, logs.Errorf("failed to open database: %v", err)
+		return nil, errorf(quiet, "failed to open database: %v", err)
 	}
 	return db, nil
 }
 
+// dbFileName gives the file the cache is kept in, flags.db in the temp dir when it's not set
+func dbFileName(fileName *string) string {
+	if fileName != nil {
+		return *fileName
+	}
+	return filepath.Join(os.TempDir(), "flags.db")
+}
+
+var (
+	_ Caching        = (*SQLLite)(nil)
+	_ Historian      = (*SQLLite)(nil)
+	_ Sticker        = (*SQLLite)(nil)
+	_ OverrideStorer = (*SQLLite)(nil)
+	_ Expirer        = (*SQLLite)(nil)
+	_ Indexer        = (*SQLLite)(nil)
+	_ Updater        = (*SQLLite)(nil)
+	_ Scheduler      = (*SQLLite)(nil)
+	_ Dropper        = (*SQLLite)(nil)
+)
+
 type SQLLite struct {
 	Flags []flag.FeatureFlag
 
 	FileName *string
 	DB       *sql.DB
+	// ReadDB is a read only connection for the reads evaluations make, so they aren't queued behind a refresh
+	// on the writer, it's opened when first needed and is DB itself when the whole cache is read only
+	ReadDB       *sql.DB
+	ReadOnly     bool
+	ErrorHandler func(error)
+	// Quiet stops errors being logged, they're still returned and passed to the ErrorHandler
+	Quiet bool
+	// EncryptionKey encrypts the flags at rest with AES-GCM, it must be 16, 24, or 32 bytes
+	EncryptionKey []byte
+	// Context cancels a refresh or read that's stuck, e.g. waiting on a locked database
+	Context context.Context
+	// QueryLogger is told about every statement run, nil costs nothing
+	QueryLogger QueryLogger
+	// SwapOnRefresh refreshes a copy of the cache file and renames it over the live one, rather than refreshing the
+	// live file in place. It's for a file only this client uses, another process reading it keeps reading the old
+	// file, and it can't be namespaced since the namespaces would share the connections that are swapped
+	SwapOnRefresh bool
+
+	namespace string
+	sharedDB  bool
+	encryptor *encryptor
+	mu        sync.Mutex
+	// swapMu is held for reading around each read when SwapOnRefresh is set, so a swap waits for them
+	swapMu sync.RWMutex
+	// writeMu serializes the writes outside a refresh with a swap, so none are made to the file being replaced
+	writeMu sync.Mutex
+}
+
+func (s *SQLLite) reportError(err error) {
+	if err == nil || s.ErrorHandler == nil {
+		return
+	}
+	s.ErrorHandler(err)
+}
+
+// errorf builds the error, logging it unless quiet. The format can wrap with %w
+func errorf(quiet bool, format string, inputs ...interface{}) error {
+	err := fmt.Errorf(format, inputs...)
+	if quiet {
+		return err
+	}
+	// attribute the log to the caller rather than errorf, the logger can't take %w so it logs the built message
+	(&logs.BugFixes{SkipDepthOverride: 4}).Errorf("%s", err)
+	return err
+}
+
+// getDB gives the open database, opening it if needed
+func (s *SQLLite) getDB() (*sql.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := getDBClient(s.DB, s.FileName, s.ReadOnly, s.Quiet)
+	if err != nil {
+		return nil, err
+	}
+	s.DB = db
+
+	return db, nil
+}
+
+// getReadDB gives the open read only connection, opening it if needed
+func (s *SQLLite) getReadDB() (*sql.DB, error) {
+	if s.ReadOnly {
+		return s.getDB()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ReadDB == nil {
+		db, err := getDBClient(nil, s.FileName, true, s.Quiet)
+		if err != nil {
+			return nil, err
+		}
+		s.ReadDB = db
+	}
+
+	return s.ReadDB, nil
 }
 
 func NewSQLLite(filename *string) *SQLLite {
@@ -44,191 +149,945 @@ func NewSQLLite(filename *string) *SQLLite {
 	}
 }
 
+// ctx gives the context queries run with
+func (s *SQLLite) ctx() context.Context {
+	if s.Context == nil {
+		return context.Background()
+	}
+	return s.Context
+}
+
+// table gives the name of the table within the namespace
+func (s *SQLLite) table(name string) string {
+	if s.namespace == "" {
+		return name
+	}
+	return fmt.Sprintf("%s_%s", name, s.namespace)
+}
+
+// Namespace gives a backend that shares the database connection but keeps its flags in their own tables
+func (s *SQLLite) Namespace(namespace string) (Caching, error) {
+	if !validNamespace(namespace) {
+		return nil, errorf(s.Quiet, "invalid namespace: %s", namespace)
+	}
+	if s.SwapOnRefresh {
+		return nil, errorf(s.Quiet, "a cache swapped on refresh can't be namespaced")
+	}
+
+	db, err := s.getDB()
+	if err != nil {
+		return nil, errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+	readDB, err := s.getReadDB()
+	if err != nil {
+		return nil, errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	return &SQLLite{
+		Flags:         []flag.FeatureFlag{},
+		FileName:      s.FileName,
+		DB:            db,
+		ReadDB:        readDB,
+		ReadOnly:      s.ReadOnly,
+		ErrorHandler:  s.ErrorHandler,
+		EncryptionKey: s.EncryptionKey,
+		Quiet:         s.Quiet,
+		Context:       s.Context,
+		QueryLogger:   s.QueryLogger,
+		namespace:     namespace,
+		sharedDB:      true,
+	}, nil
+}
+
 func (s *SQLLite) Init() error {
-	db, err := getDBClient(s.DB, s.FileName)
+	if s.EncryptionKey != nil {
+		enc, err := newEncryptor(s.EncryptionKey, s.Quiet)
+		if err != nil {
+			return err
+		}
+		s.encryptor = enc
+	}
+
+	db, err := s.getDB()
 	if err != nil {
-		return logs.Errorf("failed to get database client: %v", err)
+		return errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	if s.ReadOnly {
+		// the tables are expected to be baked in already
+		if err := db.Ping(); err != nil {
+			return errorf(s.Quiet, "failed to open read only database: %v", err)
+		}
+		return s.checkSchema(db)
 	}
-	s.DB = db
 
-	if _, err := db.Exec(`PRAGMA foreign_keys = ON`); err != nil {
+	if _, err := s.exec(context.Background(), db, `PRAGMA foreign_keys = ON`); err != nil {
 		if err := db.Close(); err != nil {
-			return logs.Errorf("failed to close database: %v", err)
+			return errorf(s.Quiet, "failed to close database: %v", err)
 		}
-		return logs.Errorf("failed to enable foreign keys: %v", err)
+		return errorf(s.Quiet, "failed to enable foreign keys: %v", err)
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		return logs.Errorf("failed to begin transaction: %v", err)
+		return errorf(s.Quiet, "failed to begin transaction: %v", err)
 	}
 	defer func() {
 		if err := tx.Rollback(); err != nil {
 			if !errors.Is(err, sql.ErrTxDone) {
-				_ = logs.Errorf("failed to rollback transaction: %v", err)
+				s.reportError(errorf(s.Quiet, "failed to rollback transaction: %v", err))
 			}
 		}
 	}()
 
-	if _, err := tx.Exec(`
-    CREATE TABLE IF NOT EXISTS flags (
+	if _, err := s.exec(context.Background(), tx, `
+    CREATE TABLE IF NOT EXISTS `+s.table("flags")+` (
         name TEXT PRIMARY KEY,
         enabled BOOLEAN NOT NULL DEFAULT FALSE,
         updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
     )`); err != nil {
-		return logs.Errorf("failed to create flags table: %v", err)
+		return errorf(s.Quiet, "failed to create flags table: %v", err)
 	}
 
-	if _, err := tx.Exec(`
-	CREATE TABLE IF NOT EXISTS cache_metadata (
+	if _, err := s.exec(context.Background(), tx, `
+	CREATE TABLE IF NOT EXISTS `+s.table("cache_metadata")+` (
 		key TEXT PRIMARY KEY,
 		value TEXT
 	)`); err != nil {
-		return logs.Errorf("failed to create cache_metadata table: %v", err)
+		return errorf(s.Quiet, "failed to create cache_metadata table: %v", err)
+	}
+
+	if _, err := s.exec(context.Background(), tx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_%s_updated ON %s(updated_at)`, s.table("flags"), s.table("flags"))); err != nil {
+		return errorf(s.Quiet, "failed to create index: %v", err)
+	}
+
+	if _, err := s.exec(context.Background(), tx, `
+	CREATE TABLE IF NOT EXISTS `+s.table("flag_history")+` (
+		name TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		payload BLOB,
+		changed_at INTEGER NOT NULL
+	)`); err != nil {
+		return errorf(s.Quiet, "failed to create flag_history table: %v", err)
+	}
+	if _, err := s.exec(context.Background(), tx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_%s_name ON %s(name, changed_at)`, s.table("flag_history"), s.table("flag_history"))); err != nil {
+		return errorf(s.Quiet, "failed to create history index: %v", err)
+	}
+
+	if _, err := s.exec(context.Background(), tx, `
+	CREATE TABLE IF NOT EXISTS `+s.table("sticky")+` (
+		name TEXT NOT NULL,
+		key TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (name, key)
+	)`); err != nil {
+		return errorf(s.Quiet, "failed to create sticky table: %v", err)
+	}
+
+	if _, err := s.exec(context.Background(), tx, `
+	CREATE TABLE IF NOT EXISTS `+s.table("overrides")+` (
+		name TEXT PRIMARY KEY,
+		enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		payload BLOB
+	)`); err != nil {
+		return errorf(s.Quiet, "failed to create overrides table: %v", err)
+	}
+
+	if err := s.addColumn(tx, s.table("flags"), "rollout", "INTEGER"); err != nil {
+		return err
+	}
+	if err := s.addColumn(tx, s.table("flags"), "payload", "BLOB"); err != nil {
+		return err
+	}
+	if err := s.addColumn(tx, s.table("flags"), "active_from", "INTEGER"); err != nil {
+		return err
+	}
+	if err := s.addColumn(tx, s.table("flags"), "active_until", "INTEGER"); err != nil {
+		return err
+	}
+	if err := s.addColumn(tx, s.table("flags"), "tags", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn(tx, s.table("flags"), "value", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumn(tx, s.table("flags"), "id", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := s.exec(context.Background(), tx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_%s_id ON %s(id)`, s.table("flags"), s.table("flags"))); err != nil {
+		return errorf(s.Quiet, "failed to create id index: %v", err)
 	}
 
-	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_flags_updated ON flags(updated_at)`); err != nil {
-		return logs.Errorf("failed to create index: %v", err)
+	if err := s.checkKeyID(tx); err != nil {
+		return err
 	}
 
 	return tx.Commit()
 }
 
-func (s *SQLLite) deleteAllFlags() error {
-	db, err := getDBClient(s.DB, s.FileName)
-	if err != nil {
-		return logs.Errorf("failed to get database client: %v", err)
+// checkSchema errors when the tables flags are read from are missing a column, a read only database can't be
+// migrated so one baked by an older version would fail every read
+func (s *SQLLite) checkSchema(db *sql.DB) error {
+	var missing []string
+	for table, columns := range map[string]string{
+		s.table("flags"):          flagColumns + ", updated_at",
+		s.table("cache_metadata"): "key, value",
+	} {
+		rows, err := s.query(context.Background(), db, fmt.Sprintf(`SELECT name FROM pragma_table_info('%s')`, table))
+		if err != nil {
+			return errorf(s.Quiet, "failed to get table info: %v", err)
+		}
+
+		found := make(map[string]bool)
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				_ = rows.Close()
+				return errorf(s.Quiet, "failed to scan table info: %v", err)
+			}
+			found[name] = true
+		}
+		if err := rows.Close(); err != nil {
+			return errorf(s.Quiet, "failed to close table info: %v", err)
+		}
+
+		for _, column := range strings.Split(columns, ", ") {
+			if !found[column] {
+				missing = append(missing, table+"."+column)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errorf(s.Quiet, "read only database is missing %s, it needs baking with this version", strings.Join(missing, ", "))
 	}
-	s.DB = db
 
-	tx, err := db.Begin()
+	return nil
+}
+
+// checkKeyID clears the flags, their history, and the sticky keys when they were written with a different encryption key (or none),
+// they can't be read anymore so the next read refetches them
+func (s *SQLLite) checkKeyID(tx *sql.Tx) error {
+	current := ""
+	if s.encryptor != nil {
+		current = s.encryptor.keyID
+	}
+
+	var stored string
+	if err := s.queryRow(context.Background(), tx, fmt.Sprintf(`SELECT value FROM %s WHERE key = 'encryption_key_id'`, s.table("cache_metadata"))).Scan(&stored); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return errorf(s.Quiet, "failed to get encryption key id: %v", err)
+	}
+	if stored == current {
+		return nil
+	}
+
+	if _, err := s.exec(context.Background(), tx, fmt.Sprintf(`DELETE FROM %s`, s.table("flags"))); err != nil {
+		return errorf(s.Quiet, "failed to clear flags for new encryption key: %v", err)
+	}
+	if _, err := s.exec(context.Background(), tx, fmt.Sprintf(`DELETE FROM %s`, s.table("flag_history"))); err != nil {
+		return errorf(s.Quiet, "failed to clear history for new encryption key: %v", err)
+	}
+	if _, err := s.exec(context.Background(), tx, fmt.Sprintf(`DELETE FROM %s`, s.table("sticky"))); err != nil {
+		return errorf(s.Quiet, "failed to clear sticky keys for new encryption key: %v", err)
+	}
+	if _, err := s.exec(context.Background(), tx, fmt.Sprintf(`DELETE FROM %s`, s.table("overrides"))); err != nil {
+		return errorf(s.Quiet, "failed to clear overrides for new encryption key: %v", err)
+	}
+	if _, err := s.exec(context.Background(), tx, fmt.Sprintf(`DELETE FROM %s WHERE key = 'next_refresh_time'`, s.table("cache_metadata"))); err != nil {
+		return errorf(s.Quiet, "failed to reset refresh time: %v", err)
+	}
+	if _, err := s.exec(context.Background(), tx, fmt.Sprintf(`INSERT OR REPLACE INTO %s(key, value) VALUES('encryption_key_id', ?)`, s.table("cache_metadata")), current); err != nil {
+		return errorf(s.Quiet, "failed to store encryption key id: %v", err)
+	}
+
+	return nil
+}
+
+// addColumn adds the column to a table created by an older version, if it isn't there already
+func (s *SQLLite) addColumn(tx *sql.Tx, table, column, definition string) error {
+	rows, err := s.query(context.Background(), tx, fmt.Sprintf(`SELECT name FROM pragma_table_info('%s')`, table))
 	if err != nil {
-		return logs.Errorf("failed to begin transaction: %v", err)
+		return errorf(s.Quiet, "failed to get table info: %v", err)
 	}
-	defer func() {
-		if err := tx.Rollback(); err != nil {
-			if !errors.Is(err, sql.ErrTxDone) {
-				_ = logs.Errorf("failed to rollback transaction: %v", err)
-			}
+
+	exists := false
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return errorf(s.Quiet, "failed to scan table info: %v", err)
+		}
+		if name == column {
+			exists = true
 		}
-	}()
-	if _, err := tx.Exec(`DELETE FROM flags`); err != nil {
-		return logs.Errorf("failed to delete flags: %v", err)
+	}
+	if err := rows.Close(); err != nil {
+		return errorf(s.Quiet, "failed to close table info: %v", err)
+	}
+	if exists {
+		return nil
 	}
 
-	return tx.Commit()
+	if _, err := s.exec(context.Background(), tx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return errorf(s.Quiet, "failed to add %s column: %v", column, err)
+	}
+	return nil
 }
 
 func (s *SQLLite) Get(name string) (bool, bool) {
-	db, err := getDBClient(s.DB, s.FileName)
-	if err != nil {
+	featureFlag, ok := s.GetFlag(name)
+	if !ok {
 		return false, false
 	}
-	s.DB = db
+	return featureFlag.Enabled, true
+}
+
+func (s *SQLLite) GetFlag(name string) (flag.FeatureFlag, bool) {
+	return s.getFlag("name", name)
+}
+
+func (s *SQLLite) GetFlagByID(id string) (flag.FeatureFlag, bool) {
+	return s.getFlag("id", id)
+}
+
+// getFlag gives the flag where the column is the value, when encrypted the column holds the hash of the value
+func (s *SQLLite) getFlag(column, value string) (flag.FeatureFlag, bool) {
+	defer s.holdReads()()
+	db, err := s.getReadDB()
+	if err != nil {
+		return flag.FeatureFlag{}, false
+	}
 
-	var enabled bool
-	if err := db.QueryRow(`SELECT enabled FROM flags WHERE name = $1 AND updated_at > (SELECT CAST(value AS INTEGER) FROM cache_metadata WHERE key = 'cache_ttl')`, name).Scan(&enabled); err != nil {
+	lookup := value
+	if s.encryptor != nil {
+		lookup = s.encryptor.name(value)
+	}
+
+	var row flagRow
+	if err := row.scan(s.queryRow(s.ctx(), db, fmt.Sprintf(`SELECT %s FROM %s WHERE %s = $1 AND updated_at > (SELECT CAST(value AS INTEGER) FROM %s WHERE key = 'cache_ttl')`, flagColumns, s.table("flags"), column, s.table("cache_metadata")), lookup)); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return false, false
+			return flag.FeatureFlag{}, false
 		}
-		return false, false
+		return flag.FeatureFlag{}, false
+	}
+
+	featureFlag, err := s.fromRow(row)
+	if err != nil {
+		s.unreadable(err)
+		return flag.FeatureFlag{}, false
 	}
-	return enabled, true
+	return featureFlag, true
 }
 
 func (s *SQLLite) GetAll() ([]flag.FeatureFlag, error) {
-	db, err := getDBClient(s.DB, s.FileName)
+	var flags []flag.FeatureFlag
+	if err := s.eachFlag(func(f flag.FeatureFlag, _ time.Time) {
+		flags = append(flags, f)
+	}); err != nil {
+		return nil, err
+	}
+
+	return flags, nil
+}
+
+func (s *SQLLite) GetAllMap() (map[string]flag.FeatureFlag, error) {
+	flags := make(map[string]flag.FeatureFlag)
+	if err := s.eachFlag(func(f flag.FeatureFlag, _ time.Time) {
+		flags[f.Details.Name] = f
+	}); err != nil {
+		return nil, err
+	}
+
+	return flags, nil
+}
+
+func (s *SQLLite) GetAllUpdated() ([]UpdatedFlag, error) {
+	var flags []UpdatedFlag
+	if err := s.eachFlag(func(f flag.FeatureFlag, updatedAt time.Time) {
+		flags = append(flags, UpdatedFlag{FeatureFlag: f, UpdatedAt: updatedAt})
+	}); err != nil {
+		return nil, err
+	}
+
+	return flags, nil
+}
+
+func (s *SQLLite) Count() (int, error) {
+	defer s.holdReads()()
+	db, err := s.getReadDB()
 	if err != nil {
-		return nil, logs.Errorf("failed to get database client: %v", err)
+		return 0, errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	var count int
+	if err := s.queryRow(s.ctx(), db, fmt.Sprintf(`SELECT COUNT(*) FROM %s`, s.table("flags"))).Scan(&count); err != nil {
+		return 0, errorf(s.Quiet, "failed to count flags: %v", err)
+	}
+	return count, nil
+}
+
+// eachFlag calls fn with every stored flag and when it was refreshed, zero for a row from an older version that
+// stored the time as text
+func (s *SQLLite) eachFlag(fn func(flag.FeatureFlag, time.Time)) error {
+	defer s.holdReads()()
+	db, err := s.getReadDB()
+	if err != nil {
+		return errorf(s.Quiet, "failed to get database client: %v", err)
 	}
-	s.DB = db
 
+	// only handled once the rows are closed, so the read doesn't block resetting the refresh time
+	var unreadable error
 	defer func() {
-		if err := db.Close(); err != nil {
-			_ = logs.Errorf("failed to close database: %v", err)
+		if unreadable != nil {
+			s.unreadable(unreadable)
 		}
 	}()
 
-	var flags []flag.FeatureFlag
-	rows, err := db.Query(`SELECT name, enabled FROM flags`)
+	rows, err := s.query(s.ctx(), db, fmt.Sprintf(`SELECT %s, updated_at FROM %s`, flagColumns, s.table("flags")))
 	if err != nil {
-		return nil, logs.Errorf("failed to query database: %v", err)
+		return errorf(s.Quiet, "failed to query database: %w", err)
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
-			_ = logs.Errorf("failed to close database rows: %v", err)
+			s.reportError(errorf(s.Quiet, "failed to close database rows: %v", err))
 		}
 	}()
 
 	for rows.Next() {
-		var name string
-		var enabled bool
-		if err := rows.Scan(&name, &enabled); err != nil {
-			return nil, logs.Errorf("failed to scan database rows: %v", err)
+		var row flagRow
+		var updated interface{}
+		if err := rows.Scan(append(row.dest(), &updated)...); err != nil {
+			return errorf(s.Quiet, "failed to scan database rows: %v", err)
+		}
+		var updatedAt time.Time
+		if unix, ok := updated.(int64); ok {
+			updatedAt = time.Unix(unix, 0)
 		}
 
-		flags = append(flags, flag.FeatureFlag{
-			Enabled: enabled,
-			Details: flag.Details{
-				Name: name,
-			},
-		})
+		featureFlag, err := s.fromRow(row)
+		if err != nil {
+			unreadable = err
+			continue
+		}
+		fn(featureFlag, updatedAt)
 	}
 
-	return flags, nil
+	return nil
 }
 
-func (s *SQLLite) Refresh(flags []flag.FeatureFlag, intervalAllowed int) error {
-	if len(flags) >= 1 { // only delete all flags if there are new flags
-		if err := s.deleteAllFlags(); err != nil {
-			return err
+// unreadable handles a flag that can't be decrypted (e.g. it was written with another key), it's treated
+// as missing and the cache is made due a refresh so the next read refetches it
+func (s *SQLLite) unreadable(err error) {
+	s.reportError(err)
+	if s.ReadOnly {
+		return
+	}
+
+	db, dbErr := s.getDB()
+	if dbErr != nil {
+		return
+	}
+	if _, err := s.exec(s.ctx(), db, fmt.Sprintf(`DELETE FROM %s WHERE key = 'next_refresh_time'`, s.table("cache_metadata"))); err != nil {
+		s.reportError(errorf(s.Quiet, "failed to reset refresh time: %v", err))
+	}
+}
+
+// flagColumns are the columns a flag is stored in, in the order flagRow uses them
+const flagColumns = "name, id, enabled, rollout, active_from, active_until, tags, value, payload"
+
+// flagRow is a flag as it's stored
+type flagRow struct {
+	name        string
+	id          sql.NullString
+	enabled     bool
+	rollout     sql.NullInt64
+	activeFrom  sql.NullInt64
+	activeUntil sql.NullInt64
+	tags        sql.NullString
+	value       sql.NullString
+	payload     []byte
+}
+
+// rowScanner is either a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (r *flagRow) scan(scanner rowScanner) error {
+	return scanner.Scan(r.dest()...)
+}
+
+// dest gives where each of flagColumns is scanned to
+func (r *flagRow) dest() []interface{} {
+	return []interface{}{&r.name, &r.id, &r.enabled, &r.rollout, &r.activeFrom, &r.activeUntil, &r.tags, &r.value, &r.payload}
+}
+
+func (r *flagRow) values() []interface{} {
+	return []interface{}{r.name, r.id, r.enabled, r.rollout, r.activeFrom, r.activeUntil, r.tags, r.value, r.payload}
+}
+
+// toRow gives the row the flag is stored as, when encrypted only the sealed payload says anything about the flag
+func (s *SQLLite) toRow(f flag.FeatureFlag) (flagRow, error) {
+	if s.encryptor != nil {
+		sealed, err := s.encryptor.seal(f)
+		if err != nil {
+			return flagRow{}, err
+		}
+		row := flagRow{
+			name:    s.encryptor.name(f.Details.Name),
+			payload: sealed,
+		}
+		if f.Details.ID != "" {
+			row.id = sql.NullString{String: s.encryptor.name(f.Details.ID), Valid: true}
+		}
+		return row, nil
+	}
+
+	row := flagRow{
+		name:        f.Details.Name,
+		enabled:     f.Enabled,
+		rollout:     nullInt(f.Rollout),
+		activeFrom:  nullUnix(f.ActiveFrom),
+		activeUntil: nullUnix(f.ActiveUntil),
+	}
+	if f.Details.ID != "" {
+		row.id = sql.NullString{String: f.Details.ID, Valid: true}
+	}
+	if f.Value != "" {
+		row.value = sql.NullString{String: f.Value, Valid: true}
+	}
+	if len(f.Tags) > 0 {
+		tags, err := json.Marshal(f.Tags)
+		if err != nil {
+			return flagRow{}, errorf(s.Quiet, "failed to encode tags: %v", err)
+		}
+		row.tags = sql.NullString{String: string(tags), Valid: true}
+	}
+	return row, nil
+}
+
+func (s *SQLLite) fromRow(r flagRow) (flag.FeatureFlag, error) {
+	if s.encryptor != nil {
+		return s.encryptor.open(r.payload)
+	}
+
+	featureFlag := flag.FeatureFlag{
+		Enabled: r.enabled,
+		Details: flag.Details{
+			Name: r.name,
+			ID:   r.id.String,
+		},
+		Rollout:     nullIntPtr(r.rollout),
+		ActiveFrom:  nullTimePtr(r.activeFrom),
+		ActiveUntil: nullTimePtr(r.activeUntil),
+		Value:       r.value.String,
+	}
+	if r.tags.Valid {
+		if err := json.Unmarshal([]byte(r.tags.String), &featureFlag.Tags); err != nil {
+			return flag.FeatureFlag{}, errorf(s.Quiet, "failed to decode tags: %v", err)
 		}
 	}
+	return featureFlag, nil
+}
+
+func nullInt(i *int) sql.NullInt64 {
+	if i == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*i), Valid: true}
+}
+
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	i := int(n.Int64)
+	return &i
+}
+
+func nullUnix(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.Unix(), Valid: true}
+}
+
+func nullTimePtr(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := time.Unix(n.Int64, 0).UTC()
+	return &t
+}
+
+func (s *SQLLite) Refresh(flags []flag.FeatureFlag, intervalAllowed int) error {
+	if s.ReadOnly {
+		return errorf(s.Quiet, "cannot refresh a read only database")
+	}
+	if s.SwapOnRefresh {
+		return s.refreshSwap(flags, intervalAllowed)
+	}
 
-	db, err := getDBClient(s.DB, s.FileName)
+	db, err := s.getDB()
 	if err != nil {
-		return logs.Errorf("failed to get database client: %v", err)
+		return errorf(s.Quiet, "failed to get database client: %v", err)
 	}
-	s.DB = db
+	return s.refreshDB(db, flags, intervalAllowed)
+}
 
-	tx, err := db.Begin()
+// refreshDB replaces the flags in the database in one transaction, recording the changes in the history
+func (s *SQLLite) refreshDB(db *sql.DB, flags []flag.FeatureFlag, intervalAllowed int) error {
+	tx, err := db.BeginTx(s.ctx(), nil)
+	if err != nil {
+		return errorf(s.Quiet, "failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if !errors.Is(err, sql.ErrTxDone) {
+				s.reportError(errorf(s.Quiet, "failed to rollback transaction: %v", err))
+			}
+		}
+	}()
+	// an empty set clears the flags like it does in memory, in the same transaction so a refresh that's cancelled
+	// part way leaves the old flags in place
+	if _, err := s.exec(s.ctx(), tx, fmt.Sprintf(`DELETE FROM %s`, s.table("flags"))); err != nil {
+		return errorf(s.Quiet, "failed to delete flags: %w", err)
+	}
+	// diffed against the history rather than the flags, so a Clear in between doesn't make every flag a change
+	previous, err := s.lastChanges(tx)
 	if err != nil {
-		return logs.Errorf("failed to begin transaction: %v", err)
+		return err
 	}
-	stmt, err := tx.Prepare(`INSERT INTO flags (name, enabled, updated_at) VALUES ($1, $2, $3)`)
+	insertFlag := fmt.Sprintf(`INSERT INTO %s (%s, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`, s.table("flags"), flagColumns)
+	stmt, err := tx.PrepareContext(s.ctx(), insertFlag)
 	if err != nil {
-		return logs.Errorf("failed to prepare statement: %v", err)
-
+		return errorf(s.Quiet, "failed to prepare statement: %w", err)
+	}
+	insertHistory := fmt.Sprintf(`INSERT INTO %s (name, enabled, payload, changed_at) VALUES ($1, $2, $3, $4)`, s.table("flag_history"))
+	history, err := tx.PrepareContext(s.ctx(), insertHistory)
+	if err != nil {
+		return errorf(s.Quiet, "failed to prepare history statement: %w", err)
 	}
 
 	now := time.Now().Unix()
 	for _, f := range flags {
-		if _, err := stmt.Exec(f.Details.Name, f.Enabled, now); err != nil {
-			return logs.Errorf("failed to insert flag: %v", err)
+		row, err := s.toRow(f)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.execStmt(s.ctx(), stmt, insertFlag, append(row.values(), now)...); err != nil {
+			return errorf(s.Quiet, "failed to insert flag: %w", err)
+		}
+
+		// only record actual changes, a flag seen for the first time counts as one
+		if enabled, ok := previous[row.name]; ok && enabled == f.Enabled {
+			continue
+		}
+		if _, err := s.execStmt(s.ctx(), history, insertHistory, row.name, row.enabled, row.payload, now); err != nil {
+			return errorf(s.Quiet, "failed to insert flag history: %w", err)
 		}
 	}
-	if _, err := tx.Exec(`INSERT OR REPLACE INTO cache_metadata(key, value) VALUES('next_refresh_time', ?), ('cache_ttl', ?)`, time.Now().Add(time.Duration(intervalAllowed)*time.Second).Unix(), intervalAllowed); err != nil {
-		return logs.Errorf("failed to insert cache metadata: %v", err)
+	if _, err := s.exec(s.ctx(), tx, fmt.Sprintf(`INSERT OR REPLACE INTO %s(key, value) VALUES('next_refresh_time', ?), ('cache_ttl', ?)`, s.table("cache_metadata")), time.Now().Add(time.Duration(intervalAllowed)*time.Second).Unix(), intervalAllowed); err != nil {
+		return errorf(s.Quiet, "failed to insert cache metadata: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return logs.Errorf("failed to commit transaction: %v", err)
+		return errorf(s.Quiet, "failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
+// lastChanges gives the value each flag was last recorded changing to, keyed by the name it's stored as
+func (s *SQLLite) lastChanges(tx *sql.Tx) (map[string]bool, error) {
+	rows, err := s.query(s.ctx(), tx, fmt.Sprintf(`SELECT name, enabled, payload FROM %s WHERE rowid IN (SELECT MAX(rowid) FROM %s GROUP BY name)`, s.table("flag_history"), s.table("flag_history")))
+	if err != nil {
+		return nil, errorf(s.Quiet, "failed to query history: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.reportError(errorf(s.Quiet, "failed to close history rows: %v", err))
+		}
+	}()
+
+	changes := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var enabled bool
+		var payload []byte
+		if err := rows.Scan(&name, &enabled, &payload); err != nil {
+			return nil, errorf(s.Quiet, "failed to scan history rows: %v", err)
+		}
+
+		if s.encryptor != nil {
+			featureFlag, err := s.encryptor.open(payload)
+			if err != nil {
+				// it can't be compared, so the flag is recorded again
+				continue
+			}
+			enabled = featureFlag.Enabled
+		}
+		changes[name] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorf(s.Quiet, "failed to read history rows: %w", err)
+	}
+
+	return changes, nil
+}
+
+// History gives the most recent value changes of the flag, newest first, a limit of 0 or less gives them all
+func (s *SQLLite) History(name string, limit int) ([]Change, error) {
+	defer s.holdReads()()
+	db, err := s.getDB()
+	if err != nil {
+		return nil, errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	lookup := name
+	if s.encryptor != nil {
+		lookup = s.encryptor.name(name)
+	}
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+
+	rows, err := s.query(s.ctx(), db, fmt.Sprintf(`SELECT enabled, payload, changed_at FROM %s WHERE name = $1 ORDER BY changed_at DESC, rowid DESC LIMIT $2`, s.table("flag_history")), lookup, limit)
+	if err != nil {
+		return nil, errorf(s.Quiet, "failed to query history: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.reportError(errorf(s.Quiet, "failed to close history rows: %v", err))
+		}
+	}()
+
+	var changes []Change
+	for rows.Next() {
+		var enabled bool
+		var payload []byte
+		var changedAt int64
+		if err := rows.Scan(&enabled, &payload, &changedAt); err != nil {
+			return nil, errorf(s.Quiet, "failed to scan history rows: %v", err)
+		}
+
+		if s.encryptor != nil {
+			featureFlag, err := s.encryptor.open(payload)
+			if err != nil {
+				return nil, err
+			}
+			enabled = featureFlag.Enabled
+		}
+
+		changes = append(changes, Change{
+			Name:      name,
+			Enabled:   enabled,
+			ChangedAt: time.Unix(changedAt, 0),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errorf(s.Quiet, "failed to read history rows: %v", err)
+	}
+
+	return changes, nil
+}
+
+// stickyNames gives what the flag and key are stored as, when encrypted they're both hashed
+func (s *SQLLite) stickyNames(name, key string) (string, string) {
+	if s.encryptor == nil {
+		return name, key
+	}
+	return s.encryptor.name(name), s.encryptor.name(key)
+}
+
+func (s *SQLLite) Stuck(name, key string) (bool, error) {
+	defer s.holdReads()()
+	db, err := s.getDB()
+	if err != nil {
+		return false, errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	name, key = s.stickyNames(name, key)
+	var found int
+	if err := s.queryRow(s.ctx(), db, fmt.Sprintf(`SELECT 1 FROM %s WHERE name = $1 AND key = $2`, s.table("sticky")), name, key).Scan(&found); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, errorf(s.Quiet, "failed to query sticky key: %v", err)
+	}
+	return true, nil
+}
+
+func (s *SQLLite) Stick(name, key string) error {
+	if s.ReadOnly {
+		return errorf(s.Quiet, "cannot stick a key in a read only database")
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	defer s.holdReads()()
+
+	db, err := s.getDB()
+	if err != nil {
+		return errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	name, key = s.stickyNames(name, key)
+	if _, err := s.exec(s.ctx(), db, fmt.Sprintf(`INSERT OR IGNORE INTO %s (name, key, created_at) VALUES ($1, $2, $3)`, s.table("sticky")), name, key, time.Now().Unix()); err != nil {
+		return errorf(s.Quiet, "failed to insert sticky key: %v", err)
+	}
+	return nil
+}
+
 func (s *SQLLite) ShouldRefreshCache() bool {
-	db, err := getDBClient(s.DB, s.FileName)
+	if s.ReadOnly {
+		return false
+	}
+	defer s.holdReads()()
+
+	db, err := s.getReadDB()
 	if err != nil {
 		return true
 	}
-	s.DB = db
 
 	var nextRefreshTime int64
-	if err := db.QueryRow(`SELECT CAST(value AS INTEGER) FROM cache_metadata WHERE key = 'next_refresh_time'`).Scan(&nextRefreshTime); err != nil {
+	if err := s.queryRow(s.ctx(), db, fmt.Sprintf(`SELECT CAST(value AS INTEGER) FROM %s WHERE key = 'next_refresh_time'`, s.table("cache_metadata"))).Scan(&nextRefreshTime); err != nil {
 		return true
 	}
 
 	return time.Now().Unix() > nextRefreshTime
 }
+
+// Seed only stores the interval, the cache stays due a refresh until it has no next_refresh_time
+func (s *SQLLite) Seed(interval time.Duration) error {
+	if s.ReadOnly {
+		return nil
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	db, err := s.getDB()
+	if err != nil {
+		return errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+	if _, err := s.exec(s.ctx(), db, fmt.Sprintf(`INSERT OR IGNORE INTO %s(key, value) VALUES('cache_ttl', ?)`, s.table("cache_metadata")), int64(interval/time.Second)); err != nil {
+		return errorf(s.Quiet, "failed to seed the cache interval: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLLite) Interval() time.Duration {
+	defer s.holdReads()()
+	db, err := s.getReadDB()
+	if err != nil {
+		return 0
+	}
+
+	var ttl int64
+	if err := s.queryRow(s.ctx(), db, fmt.Sprintf(`SELECT CAST(value AS INTEGER) FROM %s WHERE key = 'cache_ttl'`, s.table("cache_metadata"))).Scan(&ttl); err != nil {
+		return 0
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+func (s *SQLLite) NextRefresh() (time.Time, bool) {
+	defer s.holdReads()()
+	db, err := s.getReadDB()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var nextRefreshTime int64
+	if err := s.queryRow(s.ctx(), db, fmt.Sprintf(`SELECT CAST(value AS INTEGER) FROM %s WHERE key = 'next_refresh_time'`, s.table("cache_metadata"))).Scan(&nextRefreshTime); err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(nextRefreshTime, 0), true
+}
+
+func (s *SQLLite) Clear() error {
+	if s.ReadOnly {
+		return errorf(s.Quiet, "cannot clear a read only database")
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	defer s.holdReads()()
+
+	db, err := s.getDB()
+	if err != nil {
+		return errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	tx, err := db.BeginTx(s.ctx(), nil)
+	if err != nil {
+		return errorf(s.Quiet, "failed to begin transaction: %v", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if !errors.Is(err, sql.ErrTxDone) {
+				s.reportError(errorf(s.Quiet, "failed to rollback transaction: %v", err))
+			}
+		}
+	}()
+	if _, err := s.exec(s.ctx(), tx, fmt.Sprintf(`DELETE FROM %s`, s.table("flags"))); err != nil {
+		return errorf(s.Quiet, "failed to delete flags: %v", err)
+	}
+	if _, err := s.exec(s.ctx(), tx, fmt.Sprintf(`DELETE FROM %s WHERE key = 'next_refresh_time'`, s.table("cache_metadata"))); err != nil {
+		return errorf(s.Quiet, "failed to reset refresh time: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLLite) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sharedDB {
+		// the connections belong to the parent
+		s.DB = nil
+		s.ReadDB = nil
+		return nil
+	}
+
+	if s.ReadDB != nil && s.ReadDB != s.DB {
+		if err := s.ReadDB.Close(); err != nil {
+			return errorf(s.Quiet, "failed to close read database: %v", err)
+		}
+	}
+	s.ReadDB = nil
+
+	if s.DB == nil {
+		return nil
+	}
+	if err := s.DB.Close(); err != nil {
+		return errorf(s.Quiet, "failed to close database: %v", err)
+	}
+	s.DB = nil
+
+	return nil
+}
+
+// Drop removes the tables of a namespace, the tables of the root namespace are never dropped
+func (s *SQLLite) Drop() error {
+	if s.namespace == "" {
+		return errorf(s.Quiet, "cannot drop the root namespace")
+	}
+	if s.ReadOnly {
+		return errorf(s.Quiet, "cannot drop a namespace in a read only database")
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	defer s.holdReads()()
+
+	db, err := s.getDB()
+	if err != nil {
+		return errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+	for _, name := range []string{"flags", "cache_metadata", "flag_history", "sticky", "overrides"} {
+		if _, err := s.exec(s.ctx(), db, fmt.Sprintf(`DROP TABLE IF EXISTS %s`, s.table(name))); err != nil {
+			return errorf(s.Quiet, "failed to drop %s: %v", s.table(name), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"errors"
+	"github.com/flags-gg/go-flags/flag"
+	"os"
+)
+
+// holdReads keeps a swap from closing the connections while the caller reads, it's a no-op unless SwapOnRefresh
+// is set. The unlock it gives has to be called once the read is done
+func (s *SQLLite) holdReads() func() {
+	if !s.SwapOnRefresh {
+		return func() {}
+	}
+	s.swapMu.RLock()
+	return s.swapMu.RUnlock
+}
+
+// refreshSwap refreshes a copy of the cache file, then renames it over the live one and drops the connections to
+// the old file so they're reopened on the new one. The history and sticky keys are carried over in the copy, writes
+// wait for the swap so none are lost to it
+func (s *SQLLite) refreshSwap(flags []flag.FeatureFlag, intervalAllowed int) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	db, err := s.getDB()
+	if err != nil {
+		return errorf(s.Quiet, "failed to get database client: %v", err)
+	}
+
+	live := dbFileName(s.FileName)
+	next := live + ".swap"
+	if err := removeDBFiles(next); err != nil {
+		return errorf(s.Quiet, "failed to remove old swap file: %v", err)
+	}
+	if _, err := s.exec(s.ctx(), db, `VACUUM INTO ?`, next); err != nil {
+		return errorf(s.Quiet, "failed to copy the cache: %w", err)
+	}
+
+	nextDB, err := getDBClient(nil, &next, false, s.Quiet)
+	if err != nil {
+		_ = removeDBFiles(next)
+		return err
+	}
+	if err := s.refreshDB(nextDB, flags, intervalAllowed); err != nil {
+		_ = nextDB.Close()
+		_ = removeDBFiles(next)
+		return err
+	}
+	// closing checkpoints the WAL, so the copy is a single complete file
+	if err := nextDB.Close(); err != nil {
+		_ = removeDBFiles(next)
+		return errorf(s.Quiet, "failed to close the refreshed copy: %v", err)
+	}
+
+	s.swapMu.Lock()
+	defer s.swapMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ReadDB != nil && s.ReadDB != s.DB {
+		if err := s.ReadDB.Close(); err != nil {
+			s.reportError(errorf(s.Quiet, "failed to close read database: %v", err))
+		}
+	}
+	s.ReadDB = nil
+	if err := s.DB.Close(); err != nil {
+		s.reportError(errorf(s.Quiet, "failed to close database: %v", err))
+	}
+	s.DB = nil
+
+	// a WAL left next to the new file would be replayed into it
+	for _, file := range []string{live + "-wal", live + "-shm"} {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errorf(s.Quiet, "failed to remove the old wal: %v", err)
+		}
+	}
+	if err := os.Rename(next, live); err != nil {
+		return errorf(s.Quiet, "failed to swap in the refreshed cache: %v", err)
+	}
+	return nil
+}
+
+// removeDBFiles removes the database file and its WAL and shared memory files, those that don't exist are skipped
+func removeDBFiles(name string) error {
+	for _, file := range []string{name, name + "-wal", name + "-shm"} {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
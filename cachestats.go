@@ -0,0 +1,39 @@
+package flags
+
+import (
+	"time"
+)
+
+// CacheStats is the state of the flag cache, read under the refetch lock so it's never from part way through a
+// refresh
+type CacheStats struct {
+	// Flags is how many flags are cached
+	Flags int
+	// NextRefresh is when the cache goes stale, zero if it has never been refreshed
+	NextRefresh time.Time
+	// Interval is how long the flags live for, the WithDefaultInterval until the first fetch
+	Interval time.Duration
+	// Stale is whether evaluations would refetch before reading the cache
+	Stale bool
+	// Memory is whether the cache is in memory rather than SQLite
+	Memory bool
+}
+
+// CacheStats gives the state of the flag cache, it waits for a refresh in flight to finish
+func (c *Client) CacheStats() (CacheStats, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	count, err := c.Cache.Count()
+	if err != nil {
+		return CacheStats{}, c.errorf("%w: failed to count flags: %w", ErrCacheUnavailable, err)
+	}
+	nextRefresh, _ := c.Cache.NextRefresh()
+	return CacheStats{
+		Flags:       count,
+		NextRefresh: nextRefresh,
+		Interval:    c.Cache.Interval(),
+		Stale:       c.stale(),
+		Memory:      c.Cache.IsMemory,
+	}, nil
+}
@@ -0,0 +1,82 @@
+package flags
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// WithPayloadCapture writes the raw JSON body of each successful fetch to path, replacing the last one, so the flag
+// set the client saw can be replayed with NewClientFromPayload when debugging
+func WithPayloadCapture(path string) Option {
+	return func(c *Client) {
+		c.payloadCapture = path
+	}
+}
+
+// capturePayload writes to a temp file next to the capture and renames it over, so a reader never sees half a payload
+func (c *Client) capturePayload(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.payloadCapture), filepath.Base(c.payloadCapture)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.payloadCapture)
+}
+
+// payloadTransport serves a captured payload instead of calling the API
+type payloadTransport struct {
+	apiResp ApiResponse
+}
+
+func (t *payloadTransport) Fetch(ctx context.Context) (*ApiResponse, error) {
+	apiResp := t.apiResp
+	apiResp.Flags = append(apiResp.Flags[:0:0], t.apiResp.Flags...)
+	return &apiResp, nil
+}
+
+func (t *payloadTransport) bind(c *Client) transport {
+	return t
+}
+
+func (t *payloadTransport) source() string {
+	return "payload"
+}
+
+// NewClientFromPayload gives a memory client seeded from a payload written by WithPayloadCapture, it never calls the
+// API so a captured flag set can be replayed in tests
+func NewClientFromPayload(path string, opts ...Option) *Client {
+	payload := &payloadTransport{}
+	client := NewClient(append(opts, WithMemory(), func(c *Client) {
+		c.transport = payload
+	})...)
+	if client == nil {
+		return nil
+	}
+
+	var apiResp *ApiResponse
+	data, err := os.ReadFile(path)
+	if err == nil {
+		apiResp, err = decodeResponse(data)
+	}
+	if err == nil {
+		payload.apiResp = *apiResp
+		err = client.refetch()
+	}
+	if err != nil {
+		client.reportError(client.startupErrorf("failed to load payload %s: %w", path, err))
+		_ = client.Close()
+		return nil
+	}
+	return client
+}
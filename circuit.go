@@ -0,0 +1,28 @@
+package flags
+
+// TripCircuit opens the circuit breaker until ResetCircuit, e.g. to stop hammering a degraded API during an
+// incident. Evaluations serve the cache as they would with the circuit open after failures
+func (c *Client) TripCircuit() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.circuitState.isOpen = true
+	c.circuitState.tripped = true
+}
+
+// ResetCircuit closes the circuit breaker, however it was opened, so the next stale read fetches straight away
+func (c *Client) ResetCircuit() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.circuitState = CircuitState{}
+}
+
+// CircuitOpen reports whether refetches are currently being skipped by the circuit breaker
+func (c *Client) CircuitOpen() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	r := c.retrier()
+	return r.circuit.tripped || (r.circuit.isOpen && r.now().Sub(r.circuit.lastFailure) < r.openFor(r.circuit.trips))
+}
@@ -0,0 +1,40 @@
+package flags
+
+import (
+	"context"
+	"strings"
+
+	"github.com/flags-gg/go-flags/flag"
+)
+
+// Diff fetches the flags and gives how they differ from the cached ones, without caching them, e.g. to preview what
+// the next refresh will change. Only flags that are new, changed, or gone are in it, with Old and New set. The flag set
+// goes through the same checks as a refresh, a set the response validator rejects is an error wrapping ErrRejected
+// and an empty one kept out by WithRejectEmptyFlagSet changes nothing. It always fetches from /flags
+func (c *Client) Diff(ctx context.Context) ([]FlagChange, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	apiResp, err := c.timedFetch(ctx)
+	if err != nil {
+		return nil, c.errorf("failed to fetch flags: %w", err)
+	}
+	apiResp, err = c.accept(apiResp)
+	if err != nil {
+		return nil, err
+	}
+
+	// the flags a refresh would drop aren't a change
+	_ = apiResp.validate()
+	flags := make([]flag.FeatureFlag, 0, len(apiResp.Flags))
+	for _, f := range apiResp.Flags {
+		f.Details.Name = strings.ToLower(f.Details.Name)
+		flags = append(flags, f)
+	}
+
+	previous, err := c.Cache.GetAllMap()
+	if err != nil {
+		return nil, c.errorf("%w: failed to read the cached flags: %w", ErrCacheUnavailable, err)
+	}
+	return flagChanges(previous, flags, c.now()), nil
+}
@@ -0,0 +1,27 @@
+package flags
+
+import (
+	"errors"
+)
+
+// The errors the client returns and reports wrap one of these, so they can be told apart with errors.Is
+var (
+	// ErrAuthMissing is the project, agent, or environment ID not being set
+	ErrAuthMissing = errors.New("auth missing")
+	// ErrUpstream is the flags API being unreachable or failing the request, including rejecting the auth
+	ErrUpstream = errors.New("upstream error")
+	// ErrDecode is a response from the flags API that can't be decoded
+	ErrDecode = errors.New("decode error")
+	// ErrCacheUnavailable is the cache backend failing to read or store the flags
+	ErrCacheUnavailable = errors.New("cache unavailable")
+	// ErrRejected is a response the WithResponseValidator hook rejected, the cache is kept as it was
+	ErrRejected = errors.New("response rejected")
+	// ErrInvalidOverride is a local override Validate found a mistake in, one that isn't true or false or is for a
+	// flag that doesn't exist
+	ErrInvalidOverride = errors.New("invalid override")
+	// ErrCircuitOpen is reported when repeated failures open the circuit breaker, nothing is fetched until it closes
+	ErrCircuitOpen = errors.New("circuit open")
+)
+
+// ErrClientClosed is returned by Close when the client has already been closed
+var ErrClientClosed = errors.New("client is closed")
@@ -0,0 +1,88 @@
+package flags
+
+import (
+	"container/list"
+	"sync"
+)
+
+// evalCache memoizes the final evaluation of a flag between cache refreshes, bounded by size with LRU eviction
+type evalCache struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	mu      sync.Mutex
+}
+
+type evalEntry struct {
+	name    string
+	enabled bool
+}
+
+func newEvalCache(size int) *evalCache {
+	return &evalCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+func (e *evalCache) get(name string) (bool, bool) {
+	if e == nil {
+		return false, false
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	elem, ok := e.entries[name]
+	if !ok {
+		return false, false
+	}
+	e.order.MoveToFront(elem)
+	return elem.Value.(*evalEntry).enabled, true
+}
+
+func (e *evalCache) put(name string, enabled bool) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if elem, ok := e.entries[name]; ok {
+		elem.Value.(*evalEntry).enabled = enabled
+		e.order.MoveToFront(elem)
+		return
+	}
+
+	e.entries[name] = e.order.PushFront(&evalEntry{name: name, enabled: enabled})
+	for e.order.Len() > e.size {
+		oldest := e.order.Back()
+		e.order.Remove(oldest)
+		delete(e.entries, oldest.Value.(*evalEntry).name)
+	}
+}
+
+func (e *evalCache) purge() {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.entries = make(map[string]*list.Element, e.size)
+	e.order.Init()
+}
+
+func (e *evalCache) len() int {
+	if e == nil {
+		return 0
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.order.Len()
+}
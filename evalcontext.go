@@ -0,0 +1,138 @@
+package flags
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// defaultMaxContexts is how many evaluation contexts are kept unless WithMaxEvaluationContexts says otherwise
+const defaultMaxContexts = 128
+
+// contexts are the clients IsWithContext has derived, one per distinct evaluation context, the most recently used
+// first
+type contexts struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List
+	clients map[string]*list.Element
+}
+
+// contextClient is a derived client in the contexts order
+type contextClient struct {
+	hash   string
+	client *Client
+}
+
+// WithMaxEvaluationContexts is how many evaluation contexts IsWithContext keeps a cached flag set for, 128 unless
+// it's set. Past it the least recently used one is closed and its cache dropped, evaluating it again fetches
+func WithMaxEvaluationContexts(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.contexts.max = n
+		}
+	}
+}
+
+// IsWithContext gets the flag as the server evaluates it for evalCtx, e.g. {"userId": "42", "plan": "pro"}, so its
+// targeting rules apply. The context is sent as JSON in the context query param of the fetch, or the x-flags-context
+// metadata over gRPC.
+//
+// Each distinct context gets its own cached flag set, fetched and refreshed on its own interval, keyed by a hash of
+// the JSON (map keys are sorted, so the order they were set in doesn't matter). A WebSocket push carries no context,
+// so it doesn't refresh them. The context should only hold what the targeting rules use, a per-request value like a
+// request ID means a fetch per evaluation and churns the WithMaxEvaluationContexts most recent contexts that are
+// kept. The flag is for evaluating now, once its context is evicted it evaluates as off.
+//
+// An empty context evaluates from this client. A context that can't be encoded or cached is an error, rather than
+// evaluating without the context that was asked for
+func (c *Client) IsWithContext(evalCtx map[string]any, name string) (*Flag, error) {
+	client, err := c.withContext(evalCtx)
+	if err != nil {
+		c.reportError(err)
+		return nil, err
+	}
+	return client.Is(name), nil
+}
+
+// withContext gives the client for the evaluation context, deriving it the first time the context is seen
+func (c *Client) withContext(evalCtx map[string]any) (*Client, error) {
+	if len(evalCtx) == 0 {
+		return c, nil
+	}
+	encoded, err := json.Marshal(evalCtx)
+	if err != nil {
+		return nil, c.errorf("failed to encode evaluation context: %w", err)
+	}
+	hash := c.contextHash(encoded)
+
+	cs := c.contexts
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if elem, ok := cs.clients[hash]; ok {
+		cs.order.MoveToFront(elem)
+		return elem.Value.(*contextClient).client, nil
+	}
+
+	client := c.derive(c.auth, "ctx_"+hash)
+	if client == nil {
+		return nil, c.errorf("%w: failed to create the cache for the evaluation context", ErrCacheUnavailable)
+	}
+	client.evalContext = string(encoded)
+	// pushed flag sets aren't for the context
+	client.webSocketURL = ""
+	// SetOverride on this client still applies
+	client.overrides = c.overrides
+	client.start()
+
+	if cs.clients == nil {
+		cs.order = list.New()
+		cs.clients = make(map[string]*list.Element)
+	}
+	cs.clients[hash] = cs.order.PushFront(&contextClient{hash: hash, client: client})
+	limit := cs.max
+	if limit <= 0 {
+		limit = defaultMaxContexts
+	}
+	for cs.order.Len() > limit {
+		oldest := cs.order.Remove(cs.order.Back()).(*contextClient)
+		delete(cs.clients, oldest.hash)
+		c.dropContext(oldest.client)
+	}
+	return client, nil
+}
+
+// contextHash is a stable identifier for the context under this clients auth and context, safe to use in table names
+func (c *Client) contextHash(encoded []byte) string {
+	sum := sha256.Sum256(append([]byte(c.auth.hash()+"|"+c.evalContext+"|"), encoded...))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// dropContext drops the cache of a client IsWithContext derived and closes it, the cache is dropped under its mutex
+// so a refresh in flight finishes first
+func (c *Client) dropContext(client *Client) {
+	client.mutex.Lock()
+	err := client.Cache.Drop()
+	client.mutex.Unlock()
+	if err != nil {
+		c.reportError(c.errorf("%w: failed to drop the cache for an evaluation context: %w", ErrCacheUnavailable, err))
+	}
+	if err := client.Close(); err != nil && !errors.Is(err, ErrClientClosed) {
+		c.reportError(c.errorf("failed to close the client for an evaluation context: %w", err))
+	}
+}
+
+// closeContexts drops and closes the clients IsWithContext derived, their caches don't outlive the client
+func (c *Client) closeContexts() {
+	cs := c.contexts
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, elem := range cs.clients {
+		c.dropContext(elem.Value.(*contextClient).client)
+	}
+	cs.order, cs.clients = nil, nil
+}
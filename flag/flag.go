@@ -1,5 +1,9 @@
 package flag
 
+import (
+	"time"
+)
+
 type Details struct {
 	Name string `json:"name"`
 	ID   string `json:"id"`
@@ -8,4 +12,39 @@ type Details struct {
 type FeatureFlag struct {
 	Enabled bool    `json:"enabled"`
 	Details Details `json:"details"`
+	// Rollout is the percentage (0-100) of evaluation keys the flag is enabled for, nil means it's enabled for everyone
+	Rollout *int `json:"rollout,omitempty"`
+	// ActiveFrom and ActiveUntil limit when an enabled flag is on, either can be nil to leave that end of the window open
+	ActiveFrom  *time.Time `json:"activeFrom,omitempty"`
+	ActiveUntil *time.Time `json:"activeUntil,omitempty"`
+	// Tags group flags, e.g. team:payments or type:killswitch
+	Tags []string `json:"tags,omitempty"`
+	// Value is the flags string value, for flags that carry more than whether they're on
+	Value string `json:"value,omitempty"`
+}
+
+// HasTag reports whether the flag is tagged with tag
+func (f FeatureFlag) HasTag(tag string) bool {
+	for _, t := range f.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether t is inside the flags window, ActiveFrom is inclusive and ActiveUntil is exclusive
+func (f FeatureFlag) Active(t time.Time) bool {
+	if f.ActiveFrom != nil && t.Before(*f.ActiveFrom) {
+		return false
+	}
+	if f.ActiveUntil != nil && !t.Before(*f.ActiveUntil) {
+		return false
+	}
+	return true
+}
+
+// Scheduled reports whether the flag has a window, so whether it's on depends on when it's asked
+func (f FeatureFlag) Scheduled() bool {
+	return f.ActiveFrom != nil || f.ActiveUntil != nil
 }
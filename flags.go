@@ -1,16 +1,28 @@
 package flags
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/bugfixes/go-bugfixes/logs"
 	"github.com/flags-gg/go-flags/cache"
 	"github.com/flags-gg/go-flags/flag"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/http/httptrace"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,6 +31,13 @@ const (
 	maxRetries = 3
 )
 
+// regions maps the known region codes to their regional endpoints
+var regions = map[string]string{
+	"us": "https://us.api.flags.gg",
+	"eu": "https://eu.api.flags.gg",
+	"ap": "https://ap.api.flags.gg",
+}
+
 type Auth struct {
 	ProjectID     string
 	AgentID       string
@@ -28,42 +47,213 @@ type Auth struct {
 type Flag struct {
 	Name   string
 	Client *Client
+	// id is set when the flag was got by IsID, the name is then looked up each time it's evaluated
+	id string
 }
 
 type Client struct {
-	baseURL      string
-	httpClient   *http.Client
+	baseURL    string
+	baseURLSet bool
+	region     string
+	httpClient *http.Client
+	// Cache is the flag cache, calling it directly isn't coordinated with a refresh so can read or clear it part way
+	// through one
+	//
+	// Deprecated: use CacheStats and Reset, they wait for a refresh in flight
 	Cache        *cache.System
 	maxRetries   int
 	mutex        *sync.RWMutex
 	circuitState CircuitState
 	auth         Auth
+	transport    transport
+	evalCache    *evalCache
+	stats        *fetchStats
+	latency      *fetchLatency
+	killSwitch   string
+	readOnly     bool
+	overrideDir  string
+	overrides    *overrides
+	usage        *usageTracker
+	watchers     *watchers
+	subscribers  *subscribers
+	groups       *groups
+	misses       *misses
+	background   *background
+	contexts     *contexts
+	cancel       context.CancelFunc
+	errorHandler func(error)
+	// refreshHandler is told the outcome of every refresh
+	refreshHandler func(RefreshEvent)
+
+	maxRetryDuration  time.Duration
+	maxEvaluationTime time.Duration
+	refreshInterval   time.Duration
+	evaluationKey     func(context.Context) string
+	now               func() time.Time
+	logLevel          LogLevel
+	stickyRollouts    bool
+	clientVersion     string
+	pingOnStart       bool
+	softTTL           time.Duration
+	allowStaleOnError bool
+	offlineFirst      bool
+	refetchOnMiss     bool
+	persistOverrides  bool
+	rejectEmpty       bool
+	localKeyStyle     LocalKeyStyle
+	// staticFlags are the flags fixed by WithStaticFlag
+	staticFlags map[string]bool
+	// refetchLimit caps the fetches in flight across the process, nil is no cap
+	refetchLimit *limiter
+	// regionSet picks the base URL for each fetch when WithRegions is used
+	regionSet        *regionSet
+	noCircuitBreaker bool
+	webSocketURL     string
+	payloadCapture   string
+	// pushInterval is the interval of the last WebSocket snapshot, deltas keep the cache for as long
+	pushInterval int
+	aliases      *aliases
+	// interpolation replaces ${VAR} in flag values with env vars
+	interpolation bool
+	refreshJitter float64
+	// intervalUnit is the unit intervalAllowed is in, zero is seconds
+	intervalUnit time.Duration
+	// defaultInterval is from WithDefaultInterval, zero if it isn't set
+	defaultInterval time.Duration
+	// retryAt is when a fetch is tried again after one failed with nothing fetched yet, in unix nanoseconds
+	retryAt *atomic.Int64
+	// jittered is whether the first refresh has been jittered
+	jittered bool
+	// sample is a random number in [0, 1), for the soft TTL sample and the refresh jitter
+	sample func() float64
+
+	requestIDGenerator func() string
+	requestModifier    func(*http.Request) error
+	// basicAuth is the Authorization header value from WithBasicAuth
+	basicAuth string
+	// pathResolver gives the dir the SQLite cache is kept in when SetFileName isn't used
+	pathResolver func() (string, error)
+	// restrictCacheFile is set by WithCacheDir, the cache file is created readable by its owner alone
+	restrictCacheFile bool
+	forceHTTP2        bool
+	// netDialer is what the transport dials with once it's been tuned, see dialer
+	netDialer *net.Dialer
+	// bootstrapURL is fetched from instead of /flags by the first refresh, it's cleared under the mutex once it has
+	// been tried
+	bootstrapURL string
+	// evalContext is the JSON evaluation context sent with every fetch, only set on the clients IsWithContext derives
+	evalContext       string
+	tracer            trace.Tracer
+	responseValidator func(*ApiResponse) error
+	bucketer          Bucketer
 }
 
 type CircuitState struct {
 	isOpen       bool
 	failureCount int
 	lastFailure  time.Time
+	// trips is how many times in a row the circuit has opened, each one doubles how long it stays open
+	trips int
+	// tripped is set by TripCircuit, the circuit then stays open until ResetCircuit
+	tripped bool
 }
 
 type ApiResponse struct {
+	// IntervalAllowed is how long the flags can be cached for, in seconds unless WithIntervalUnit says otherwise
 	IntervalAllowed int                `json:"intervalAllowed"`
 	Flags           []flag.FeatureFlag `json:"flags"`
 }
+
+// validate drops the flags that can't be cached, those without a name (a null entry decodes as one), out of range
+// rollouts, and repeats of a name since the cache holds one flag per name. The error lists each one so a malformed
+// response isn't silently half cached
+func (r *ApiResponse) validate() error {
+	var invalid []string
+	seen := make(map[string]bool, len(r.Flags))
+	flags := make([]flag.FeatureFlag, 0, len(r.Flags))
+	for i, f := range r.Flags {
+		switch {
+		case strings.TrimSpace(f.Details.Name) == "":
+			invalid = append(invalid, fmt.Sprintf("flag %d has no name", i))
+		case f.Rollout != nil && (*f.Rollout < 0 || *f.Rollout > 100):
+			invalid = append(invalid, fmt.Sprintf("flag %d (%s) has a rollout of %d", i, f.Details.Name, *f.Rollout))
+		case seen[strings.ToLower(f.Details.Name)]:
+			invalid = append(invalid, fmt.Sprintf("flag %d (%s) is a duplicate", i, f.Details.Name))
+		default:
+			seen[strings.ToLower(f.Details.Name)] = true
+			flags = append(flags, f)
+		}
+	}
+	r.Flags = flags
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("skipped %d flags: %s", len(invalid), strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
 type Option func(*Client)
 
+// Backend is the cache backend used to store the flags
+type Backend int
+
+const (
+	BackendSQLite Backend = iota
+	BackendMemory
+)
+
+func (b Backend) String() string {
+	switch b {
+	case BackendSQLite:
+		return "sqlite"
+	case BackendMemory:
+		return "memory"
+	default:
+		return fmt.Sprintf("Backend(%d)", int(b))
+	}
+}
+
+// ParseBackend gives the backend for its name, e.g. from config. There's no client to take a log level from, so the
+// error is only returned
+func ParseBackend(name string) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "sqlite":
+		return BackendSQLite, nil
+	case "memory":
+		return BackendMemory, nil
+	default:
+		return BackendSQLite, fmt.Errorf("unknown cache backend: %s", name)
+	}
+}
+
 func NewClient(opts ...Option) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
 	c := cache.NewSystem()
-	c.SetContext(context.Background())
+	c.SetContext(ctx)
 
 	client := &Client{
 		baseURL: baseURL,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		Cache:      c,
-		maxRetries: maxRetries,
-		mutex:      &sync.RWMutex{},
+		Cache:       c,
+		maxRetries:  maxRetries,
+		mutex:       &sync.RWMutex{},
+		stats:       &fetchStats{},
+		latency:     &fetchLatency{},
+		retryAt:     &atomic.Int64{},
+		overrides:   &overrides{},
+		usage:       &usageTracker{},
+		watchers:    &watchers{},
+		subscribers: &subscribers{},
+		groups:      &groups{},
+		misses:      &misses{},
+		background:  &background{},
+		contexts:    &contexts{},
+		cancel:      cancel,
+		now:         time.Now,
+		sample:      rand.Float64,
+		bucketer:    SHA256Bucketer{},
 		circuitState: CircuitState{
 			isOpen:       false,
 			failureCount: 0,
@@ -73,21 +263,92 @@ func NewClient(opts ...Option) *Client {
 	for _, opt := range opts {
 		opt(client)
 	}
-	if !c.IsMemory {
-		c.CacheSystem = cache.NewSQLLite(c.FileName)
+
+	if client.region != "" {
+		regionURL, ok := regions[strings.ToLower(client.region)]
+		if !ok {
+			client.reportError(client.startupErrorf("unknown region: %s", client.region))
+			cancel()
+			return nil
+		}
+		if !client.baseURLSet {
+			client.baseURL = regionURL
+		}
 	}
 
-	if err := c.CacheSystem.Init(); err != nil {
-		_ = logs.Errorf("failed to initialize database: %v", err)
+	if client.transport == nil {
+		client.transport = &httpTransport{client: client}
+	}
+	if _, ok := client.transport.(*grpcTransport); ok && (client.bootstrapURL != "" || client.regionSet != nil) {
+		client.reportError(client.startupErrorf("WithBootstrapURL and WithRegions fetch over HTTP, they can't be used with WithGRPC"))
+		cancel()
 		return nil
 	}
 
+	if c.FileName == nil && c.CacheSystem == nil {
+		fileName, err := client.cacheFileName()
+		if err != nil {
+			client.reportError(client.startupErrorf("%w: %w", ErrCacheUnavailable, err))
+			cancel()
+			return nil
+		}
+		if client.restrictCacheFile {
+			if err := restrictFile(fileName); err != nil {
+				client.reportError(client.startupErrorf("%w: %w", ErrCacheUnavailable, err))
+				cancel()
+				return nil
+			}
+		}
+		c.SetFileName(&fileName)
+	}
+
+	if err := c.InitDB(); err != nil {
+		client.reportError(client.startupErrorf("%w: failed to initialize database: %w", ErrCacheUnavailable, err))
+		cancel()
+		return nil
+	}
+
+	if client.persistOverrides {
+		if err := client.loadOverrides(); err != nil {
+			client.reportError(client.startupErrorf("%w: failed to load overrides: %w", ErrCacheUnavailable, err))
+			cancel()
+			return nil
+		}
+	}
+
+	if client.pingOnStart && !client.readOnly {
+		if err := client.Ping(c.Context); err != nil {
+			client.reportError(client.startupErrorf("failed to ping the flags api: %v", err))
+			cancel()
+			return nil
+		}
+	}
+
+	client.start()
+
 	return client
 }
 
 func WithBaseURL(baseURL string) Option {
 	return func(c *Client) {
 		c.baseURL = baseURL
+		c.baseURLSet = true
+	}
+}
+
+// WithBootstrapURL fetches the first flag set from url, e.g. a full snapshot cached by a CDN, every fetch after
+// that goes to /flags. If the bootstrap fetch fails the retry goes to /flags. Only a refresh fetches from it, Ping and
+// Diff always go to /flags
+func WithBootstrapURL(url string) Option {
+	return func(c *Client) {
+		c.bootstrapURL = url
+	}
+}
+
+// WithRegion uses the regional endpoint for the given region (us, eu, ap), an explicit WithBaseURL always wins
+func WithRegion(region string) Option {
+	return func(c *Client) {
+		c.region = region
 	}
 }
 func WithMaxRetries(maxRetries int) Option {
@@ -95,22 +356,380 @@ func WithMaxRetries(maxRetries int) Option {
 		c.maxRetries = maxRetries
 	}
 }
+
+// WithoutCircuitBreaker never stops fetching after repeated failures, every stale read retries per the normal policy.
+// It suits an API behind a reliable proxy, where a blip shouldn't lock fetching out
+func WithoutCircuitBreaker() Option {
+	return func(c *Client) {
+		c.noCircuitBreaker = true
+	}
+}
+
+// WithDisableCircuitBreaker is WithoutCircuitBreaker, every refetch makes its retries and returns the real error
+func WithDisableCircuitBreaker() Option {
+	return WithoutCircuitBreaker()
+}
+
+// WithMaxRetryDuration caps the total time a refetch can spend retrying, regardless of the attempts left
+func WithMaxRetryDuration(d time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetryDuration = d
+	}
+}
+
+// WithMaxEvaluationTime caps how long a single evaluation can take, including refetching a stale cache,
+// once it's spent the evaluation fails closed (returns false) while the refetch finishes in the background
+func WithMaxEvaluationTime(d time.Duration) Option {
+	return func(c *Client) {
+		c.maxEvaluationTime = d
+	}
+}
+
+// WithDialTimeout caps how long connecting to the API can take, separately from the overall client timeout
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.dialer().Timeout = d
+	}
+}
+
+// TransportTuning tunes how connections to the API are kept, for refetching often. A zero field leaves the default
+type TransportTuning struct {
+	// MaxIdleConnsPerHost is how many idle connections to the API are kept for reuse, the default is 2
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept, the default is 90 seconds
+	IdleConnTimeout time.Duration
+	// KeepAlive is the TCP keep-alive period of the connections, the default is 30 seconds
+	KeepAlive time.Duration
+	// ForceAttemptHTTP2 tries HTTP/2 even with a custom dialer or TLS config, see WithForceHTTP2 to require it
+	ForceAttemptHTTP2 bool
+}
+
+// WithTransportTuning tunes the clients http.Transport, so the connection to the API is reused across refetches
+func WithTransportTuning(tuning TransportTuning) Option {
+	return func(c *Client) {
+		t := c.roundTripper()
+		if tuning.MaxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = tuning.MaxIdleConnsPerHost
+		}
+		if tuning.IdleConnTimeout > 0 {
+			t.IdleConnTimeout = tuning.IdleConnTimeout
+		}
+		if tuning.KeepAlive != 0 {
+			c.dialer().KeepAlive = tuning.KeepAlive
+		}
+		if tuning.ForceAttemptHTTP2 {
+			t.ForceAttemptHTTP2 = true
+		}
+	}
+}
+
+// dialer gives the net.Dialer the clients transport connects with, it's set on the transport the first time it's
+// tuned, with the same defaults as http.DefaultTransport
+func (c *Client) dialer() *net.Dialer {
+	if c.netDialer == nil {
+		c.netDialer = &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		c.roundTripper().DialContext = c.netDialer.DialContext
+	}
+	return c.netDialer
+}
+
+// WithResponseHeaderTimeout caps how long the API can take to send the response headers once the request is written,
+// reading the body is still only limited by the overall client timeout
+func WithResponseHeaderTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.roundTripper().ResponseHeaderTimeout = d
+	}
+}
+
+// WithForceHTTP2 has the flags requests use HTTP/2, a fetch fails if the API (or a proxy in front of it) only
+// negotiates HTTP/1.1. The default transport already prefers HTTP/2 over TLS, the protocol each fetch used is in
+// Stats().LastProtocol
+func WithForceHTTP2() Option {
+	return func(c *Client) {
+		c.roundTripper().ForceAttemptHTTP2 = true
+		c.forceHTTP2 = true
+	}
+}
+
+// WithRequestModifier is called with each flags request once the standard headers are set, just before it's sent,
+// e.g. to sign it or add a token. If it errors the fetch fails. It must not remove the X-Project-ID, X-Agent-ID, or
+// X-Environment-ID headers, the API rejects requests without them
+func WithRequestModifier(fn func(*http.Request) error) Option {
+	return func(c *Client) {
+		c.requestModifier = fn
+	}
+}
+
+// WithBasicAuth sends an Authorization: Basic header with the flags requests and the WebSocket handshake, for a proxy
+// in front of the API that requires it. The API itself authenticates with the X-Project-ID, X-Agent-ID, and
+// X-Environment-ID headers, so the two don't clash. If a request modifier sets its own Authorization header, e.g. a
+// bearer token for the upstream, that one is kept and the basic auth moves to Proxy-Authorization
+func WithBasicAuth(user, pass string) Option {
+	return func(c *Client) {
+		c.basicAuth = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+}
+
+// WithRejectEmptyFlagSet treats an empty flag set as a likely bad deploy while flags are cached, rather than turning
+// every flag off. The cached flags are kept for the interval the empty set came with and a warning is logged. An
+// empty set is still cached when there's nothing cached already. Without it an empty set clears the cache
+func WithRejectEmptyFlagSet() Option {
+	return func(c *Client) {
+		c.rejectEmpty = true
+	}
+}
+
+// WithResponseValidator is called with each flag set once it's decoded, before it replaces the cached flags, e.g. to
+// reject one that disables most flags after a bad deploy. If it errors the flags already cached are kept, and the
+// error is returned and reported wrapped in ErrRejected. It's called with the refetch lock held
+func WithResponseValidator(fn func(*ApiResponse) error) Option {
+	return func(c *Client) {
+		c.responseValidator = fn
+	}
+}
+
+// roundTripper gives the clients own http.Transport, cloned from the default the first time a timeout is set on it
+func (c *Client) roundTripper() *http.Transport {
+	if t, ok := c.httpClient.Transport.(*http.Transport); ok {
+		return t
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	c.httpClient.Transport = t
+	return t
+}
+
 func WithAuth(auth Auth) Option {
 	return func(c *Client) {
 		c.auth = auth
 	}
 }
+
+// SetFileName sets where the SQLite cache is kept, by default it is flags-{hash of the auth}.db in the dir from the
+// path resolver, see WithPathResolver
 func SetFileName(fileName *string) Option {
 	return func(c *Client) {
 		c.Cache.SetFileName(fileName)
 	}
 }
+
+// WithEvalCacheSize memoizes up to size flag evaluations between cache refreshes,
+// they're forgotten when the cache refreshes or an env var or override file changes
+func WithEvalCacheSize(size int) Option {
+	return func(c *Client) {
+		if size <= 0 {
+			c.evalCache = nil
+			return
+		}
+		c.evalCache = newEvalCache(size)
+	}
+}
+
+// WithKillSwitch names a master flag, while it resolves to false every other flag is disabled
+func WithKillSwitch(flagName string) Option {
+	return func(c *Client) {
+		c.killSwitch = strings.ToLower(flagName)
+	}
+}
+
+// WithReadOnly serves only the flags already in the SQLite file, the file is opened read only and never refreshed
+func WithReadOnly() Option {
+	return func(c *Client) {
+		c.readOnly = true
+		c.Cache.SetReadOnly()
+	}
+}
+
+// WithFileOverrideDir reads overrides from a directory with one file per flag (e.g. a mounted ConfigMap),
+// the precedence is override files, then FLAGS_ env vars, then the cache. The directory is only read again when its
+// mtime changes, so a file should be replaced (as Kubernetes does) rather than edited in place
+func WithFileOverrideDir(dir string) Option {
+	return func(c *Client) {
+		c.overrideDir = dir
+	}
+}
+
+// WithBackend picks the cache backend, SQLite is used if this isn't set
+func WithBackend(b Backend) Option {
+	return func(c *Client) {
+		switch b {
+		case BackendMemory:
+			c.Cache.NewMemory()
+		default:
+			c.Cache.UseSQLLite()
+		}
+	}
+}
+
+// WithCache uses backend as the cache, e.g. one kept in BoltDB or DynamoDB. The client calls its Init and Close,
+// the optional interfaces in the cache package (Historian, Sticker, Namespacer...) light up what depends on them,
+// WithAuth needs a Namespacer. The options for the built in backends, like WithCacheDir, don't apply to it
+func WithCache(backend cache.Caching) Option {
+	return func(c *Client) {
+		c.Cache.UseBackend(backend)
+	}
+}
+
+// WithErrorHandler is called with every error the client would otherwise only log, e.g. to route them into alerting
+func WithErrorHandler(fn func(error)) Option {
+	return func(c *Client) {
+		c.errorHandler = fn
+		c.Cache.SetErrorHandler(fn)
+	}
+}
+
+// WithCacheEncryption encrypts the flags in the SQLite cache with AES-GCM, the key must be 16, 24, or 32 bytes,
+// changing the key clears the cache so it's refetched
+func WithCacheEncryption(key []byte) Option {
+	return func(c *Client) {
+		c.Cache.SetEncryptionKey(key)
+	}
+}
+
+// WithQueryLogger is called with every statement the SQLite cache runs, its args, how long it took, and its error,
+// for seeing what the cache is doing. It's called on the goroutine running the statement, so it should be quick
+func WithQueryLogger(fn func(query string, args []interface{}, d time.Duration, err error)) Option {
+	return func(c *Client) {
+		c.Cache.SetQueryLogger(fn)
+	}
+}
+
+// WithSwapOnRefresh has the SQLite cache refresh a copy of its file and rename it over the live one, rather than
+// refreshing it in place, so the file on disk is always a complete flag set. It's for a cache file only this client
+// uses, and the client can't be namespaced with WithAuth
+func WithSwapOnRefresh() Option {
+	return func(c *Client) {
+		c.Cache.SetSwapOnRefresh()
+	}
+}
+
 func WithMemory() Option {
+	return WithBackend(BackendMemory)
+}
+
+// WithMemoryLimit uses the memory backend keeping at most n flags, past it the least recently evaluated are evicted.
+// Evaluating an evicted flag refetches, keeping it as the most recent, so n should cover the flags a service uses
+func WithMemoryLimit(n int) Option {
 	return func(c *Client) {
+		c.Cache.SetMemoryLimit(n)
 		c.Cache.NewMemory()
 	}
 }
 
+// WithAuth gives a copy of the client for another project or environment,
+// it shares the http client and cache connection but has its own auth and cache namespace
+func (c *Client) WithAuth(auth Auth) *Client {
+	client := c.derive(auth, auth.namespace())
+	if client == nil {
+		return nil
+	}
+	if client.persistOverrides {
+		// the namespace keeps its own overrides
+		if err := client.loadOverrides(); err != nil {
+			c.reportError(c.startupErrorf("%w: failed to load overrides: %w", ErrCacheUnavailable, err))
+			return nil
+		}
+	}
+	client.start()
+
+	return client
+}
+
+// derive gives a copy of the client with the auth and its own cache namespace, it's started by the caller
+func (c *Client) derive(auth Auth, namespace string) *Client {
+	namespaced, err := c.Cache.Namespaced(namespace)
+	if err != nil {
+		c.reportError(c.startupErrorf("failed to create cache namespace: %v", err))
+		return nil
+	}
+
+	c.mutex.RLock()
+	client := *c
+	c.mutex.RUnlock()
+	client.auth = auth
+	client.Cache = namespaced
+	// the snapshot and evaluation context are the parents
+	client.bootstrapURL = ""
+	client.evalContext = ""
+	client.mutex = &sync.RWMutex{}
+	client.circuitState = CircuitState{}
+	client.stats = &fetchStats{}
+	client.latency = &fetchLatency{}
+	client.retryAt = &atomic.Int64{}
+	client.overrides = &overrides{}
+	client.usage = &usageTracker{}
+	client.watchers = &watchers{}
+	client.subscribers = &subscribers{}
+	client.groups = c.groups.clone()
+	client.misses = &misses{}
+	client.background = &background{}
+	client.contexts = &contexts{max: c.contexts.max}
+	ctx, cancel := context.WithCancel(c.Cache.Context)
+	client.Cache.SetContext(ctx)
+	client.cancel = cancel
+	client.transport = c.transport.bind(&client)
+	if c.evalCache != nil {
+		client.evalCache = newEvalCache(c.evalCache.size)
+	}
+
+	return &client
+}
+
+// SetAuth rotates the auth of a long-lived client, e.g. for a blue/green cutover. When the auth changes the cached
+// flags are cleared and refetched for the new environment, the error is from that refetch
+func (c *Client) SetAuth(auth Auth) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if auth == c.auth {
+		return nil
+	}
+	c.auth = auth
+	if c.readOnly {
+		return nil
+	}
+
+	if err := c.Cache.Clear(); err != nil {
+		return c.errorf("%w: failed to clear cache: %w", ErrCacheUnavailable, err)
+	}
+	c.evalCache.purge()
+	c.circuitState = CircuitState{}
+
+	if err := c.doRefetch(); err != nil {
+		c.watchers.notify(c) // the old environments flags are gone either way
+		return err
+	}
+	return nil
+}
+
+// Reset clears the cached flags so the next evaluation refetches them, e.g. between tests. It waits for a refetch
+// that's in progress, and closes the circuit breaker so the refetch isn't skipped
+func (c *Client) Reset() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.Cache.Clear(); err != nil {
+		return c.errorf("%w: failed to clear cache: %w", ErrCacheUnavailable, err)
+	}
+	c.evalCache.purge()
+	c.circuitState = CircuitState{}
+	c.retryAt.Store(0)
+
+	return nil
+}
+
+// namespace is a stable identifier for the auth, safe to use in table names
+func (a Auth) namespace() string {
+	return "ns_" + a.hash()
+}
+
+func (a Auth) hash() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{a.ProjectID, a.AgentID, a.EnvironmentID}, "|")))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
 func (c *Client) Is(name string) *Flag {
 	return &Flag{
 		Name:   name,
@@ -118,147 +737,618 @@ func (c *Client) Is(name string) *Flag {
 	}
 }
 
+// IsID gets the flag by its ID rather than its name, the ID stays the same when the flag is renamed
+func (c *Client) IsID(id string) *Flag {
+	return &Flag{
+		Client: c,
+		id:     id,
+	}
+}
+
+// ID gives the flags stable ID, empty for a flag got by name that isn't cached
+func (f *Flag) ID() string {
+	if f.id != "" {
+		return f.id
+	}
+
+	c := f.Client
+	c.ensureFresh()
+	featureFlag, ok := c.Cache.GetFlag(c.canonical(f.Name))
+	if !ok {
+		return ""
+	}
+	return featureFlag.Details.ID
+}
+
+// name gives the flags name, for a flag got by ID it's whatever the ID is called in the cache, refetching first if
+// the cache is stale so a rename is followed. An unknown ID has no name, so evaluates as an unknown flag
+func (f *Flag) name() string {
+	if f.id == "" {
+		return f.Name
+	}
+
+	c := f.Client
+	c.ensureFresh()
+	featureFlag, ok := c.Cache.GetFlagByID(f.id)
+	if !ok {
+		return ""
+	}
+	return featureFlag.Details.Name
+}
+
+// ensureFresh refetches a stale cache before it's read directly rather than through an evaluation, the error is
+// reported rather than returned
+func (c *Client) ensureFresh() {
+	if err := c.refreshWithinBudget(); err != nil {
+		c.reportError(c.errorf("failed to refetch flags: %w", err))
+	}
+}
+
 // List get all flags rather than just the one for the flag itself
 func (c *Client) List() ([]flag.FeatureFlag, error) {
-	flags, err := c.Cache.CacheSystem.GetAll()
+	flags, err := c.Cache.GetAll()
 	if err != nil {
-		return nil, err
+		return nil, c.errorf("%w: failed to list flags: %w", ErrCacheUnavailable, err)
 	}
 
 	return flags, nil
 }
 
+// Count gives how many flags are cached, it's cheaper than taking the len of List
+func (c *Client) Count() (int, error) {
+	count, err := c.Cache.Count()
+	if err != nil {
+		return 0, c.errorf("%w: failed to count flags: %w", ErrCacheUnavailable, err)
+	}
+	return count, nil
+}
+
+// ListByTag gives the cached flags tagged with tag, e.g. so a team only sees its own flags
+func (c *Client) ListByTag(tag string) ([]flag.FeatureFlag, error) {
+	return c.Find(func(f flag.FeatureFlag) bool {
+		return f.HasTag(tag)
+	})
+}
+
+// Find gives the cached flags pred matches, pred sees the whole flag so it can filter on anything the flag carries
+func (c *Client) Find(pred func(flag.FeatureFlag) bool) ([]flag.FeatureFlag, error) {
+	flags, err := c.Cache.GetAll()
+	if err != nil {
+		return nil, c.errorf("%w: failed to list flags: %w", ErrCacheUnavailable, err)
+	}
+
+	var found []flag.FeatureFlag
+	for _, f := range flags {
+		if pred(f) {
+			found = append(found, f)
+		}
+	}
+	return found, nil
+}
+
+// Close cancels anything in flight, waits for the background goroutines to exit, and releases the cache backend.
+// Closing an already closed client gives ErrClientClosed
+func (c *Client) Close() error {
+	// while the context the derived clients drop their caches with is still live
+	c.closeContexts()
+	if !c.background.stop(c.cancel) {
+		return ErrClientClosed
+	}
+
+	c.watchers.close()
+	c.subscribers.close()
+	return c.Cache.Close()
+}
+
 // Enabled flag specific
 func (f *Flag) Enabled() bool {
-	return f.Client.isEnabled(f.Name)
+	return f.Client.isEnabled(f.name())
 }
 
 func (c *Client) isEnabled(name string) bool {
-	name = strings.ToLower(name) // force to lowercase
+	return c.isEnabledFor(name, "")
+}
 
-	if c.Cache.CacheSystem.ShouldRefreshCache() {
-		if err := c.refetch(); err != nil {
-			_ = logs.Errorf("failed to refetch flags: %v", err)
-			return false
-		}
+// isEnabledFor evaluates the flag for the evaluation key, which buckets flags that are being rolled out
+func (c *Client) isEnabledFor(name, key string) bool {
+	return c.isEnabledAt(context.Background(), name, key, time.Time{})
+}
+
+// isEnabledAt is isEnabledFor with the active window checked at the time, a zero time is now. The flags.evaluate span
+// is a child of the span in ctx
+func (c *Client) isEnabledAt(ctx context.Context, name, key string, at time.Time) bool {
+	if c.tracer == nil {
+		enabled, _, _ := c.evaluateAt(name, key, at)
+		return enabled
 	}
 
-	// check local
-	localFlags := buildLocal()
-	for lname, enabled := range localFlags {
-		if lname == name {
-			return enabled
-		}
+	_, span := c.tracer.Start(ctx, "flags.evaluate")
+	defer span.End()
+	enabled, fallback, err := c.evaluateAt(name, key, at)
+	span.SetAttributes(evaluationAttributes(c.canonical(name), enabled, fallback)...)
+	if err != nil {
+		recordError(span, err)
 	}
+	return enabled
+}
 
-	// check cache
-	enabled, exists := c.Cache.CacheSystem.Get(name)
-	if !exists {
+// evaluateAt does the work of isEnabledAt, it also gives whether the flag was evaluated without the cache, from the
+// local overrides alone, and why the flags couldn't be refetched, which has already been reported
+func (c *Client) evaluateAt(name, key string, at time.Time) (bool, bool, error) {
+	name = c.canonical(name) // lowercased, and renamed if it is an alias
+	if enabled, ok := c.static(name); ok {
+		return enabled, false, nil
+	}
+	c.usage.record(name)
+
+	err := c.refreshWithinBudget()
+	if err == nil {
+		err = c.refetchEvicted(name)
+	}
+	if err == nil {
+		err = c.refetchMissing(name)
+	}
+	if err != nil {
+		c.reportError(c.errorf("failed to refetch flags: %w", err))
+	}
+	if (err != nil || c.stale()) && !c.allowStaleOnError {
+		return c.localValue(name), true, err
+	}
+
+	return c.valueAt(name, key, at), false, err
+}
+
+// value evaluates the already lowercased flag against what's cached, without refreshing it first
+func (c *Client) value(name, key string) bool {
+	return c.valueAt(name, key, time.Time{})
+}
+
+// valueAt is value with the active window checked at the time, a zero time is now. Only evaluations now go through
+// the eval cache
+func (c *Client) valueAt(name, key string, at time.Time) bool {
+	if c.killSwitch != "" && name != c.killSwitch && !c.lookup(c.killSwitch) {
 		return false
 	}
+
+	if key == "" && at.IsZero() {
+		return c.lookup(name)
+	}
+
+	enabled, _, _ := c.resolveAt(name, key, at)
 	return enabled
 }
 
-func (c *Client) fetchFlags() (*ApiResponse, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/flags", c.baseURL), nil)
-	if err != nil {
-		return nil, logs.Errorf("failed to build request %v", err)
+// lookup evaluates the flag through the eval cache, flags with an active window aren't memoized since they change with time
+func (c *Client) lookup(name string) bool {
+	if c.evalCache != nil {
+		c.localFlags() // purges the eval cache if the overrides have changed
 	}
-	req.Header.Set("User-Agent", "Flags-Go")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
+	if enabled, ok := c.evalCache.get(name); ok {
+		return enabled
+	}
+
+	enabled, _, scheduled := c.resolve(name, "")
+	if !scheduled {
+		c.evalCache.put(name, enabled)
+	}
+	return enabled
+}
+
+// resolve gives the value of the flag for the evaluation key, whether it's known at all, and whether it has an active window
+func (c *Client) resolve(name, key string) (bool, bool, bool) {
+	return c.resolveAt(name, key, time.Time{})
+}
+
+// resolveAt is resolve with the active window checked at the time, a zero time is now
+func (c *Client) resolveAt(name, key string, at time.Time) (bool, bool, bool) {
+	if at.IsZero() {
+		at = c.now()
+	}
+
+	if enabled, ok := c.local(name); ok {
+		return enabled, true, false
+	}
+
+	// check cache
+	featureFlag, exists := c.Cache.GetFlag(name)
+	if !exists {
+		return false, false, false
+	}
+	return featureFlag.Active(at) && c.rollout(featureFlag, key), true, featureFlag.Scheduled()
+}
+
+// local gives the value of the flag from the override files, env vars, or SetOverride, whichever has it first
+func (c *Client) local(name string) (bool, bool) {
+	files, env := c.localFlags()
+
+	// check override files, these win over env vars since they can change while running
+	if enabled, ok := c.lookupLocal(files, name); ok {
+		return enabled, true
+	}
+
+	// check local
+	if enabled, ok := c.lookupLocal(env, name); ok {
+		return enabled, true
+	}
+
+	return c.overrides.setOverride(name)
+}
+
+// reportError passes the error on to the error handler if there is one
+func (c *Client) reportError(err error) {
+	if err == nil || c.errorHandler == nil {
+		return
+	}
+	c.errorHandler(err)
+}
 
+func (c *Client) checkAuth() error {
 	if c.auth.ProjectID == "" {
-		return nil, logs.Error("project ID is required")
+		return c.errorf("%w: project ID is required", ErrAuthMissing)
 	}
 	if c.auth.AgentID == "" {
-		return nil, logs.Error("agent ID is required")
+		return c.errorf("%w: agent ID is required", ErrAuthMissing)
 	}
 	if c.auth.EnvironmentID == "" {
-		return nil, logs.Error("environment ID is required")
+		return c.errorf("%w: environment ID is required", ErrAuthMissing)
+	}
+
+	return nil
+}
+
+// bootstrapKey holds the bootstrap URL in the context of the one fetch that's to use it
+type bootstrapKey struct{}
+
+// fetchFlags expects the caller to hold the mutex, so the auth can't be rotated by SetAuth mid request
+func (c *Client) fetchFlags(ctx context.Context) (*ApiResponse, error) {
+	// every error carries the request ID so the failure can be found in the server logs
+	requestID := c.requestID()
+	errorf := func(format string, args ...interface{}) error {
+		return c.errorf("request %s: "+format, append([]interface{}{requestID}, args...)...)
+	}
+
+	url, region := fmt.Sprintf("%s/flags", c.baseURL), -1
+	bootstrap, _ := ctx.Value(bootstrapKey{}).(string)
+	switch {
+	case bootstrap != "":
+		url = bootstrap
+	case c.regionSet != nil:
+		region = c.regionSet.pick(time.Now())
+		url = fmt.Sprintf("%s/flags", c.regionSet.baseURL(region))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errorf("failed to build request %v", err)
+	}
+	if c.evalContext != "" {
+		// the server applies its targeting rules for the context
+		query := req.URL.Query()
+		query.Set("context", c.evalContext)
+		req.URL.RawQuery = query.Encode()
+	}
+	req.Header.Set("User-Agent", "Flags-Go")
+	req.Header.Set("X-Request-ID", requestID)
+	req.Header.Set("X-Flags-Client-Version", c.reportedVersion())
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := c.checkAuth(); err != nil {
+		return nil, err
 	}
 
 	req.Header.Set("X-Project-ID", c.auth.ProjectID)
 	req.Header.Set("X-Agent-ID", c.auth.AgentID)
 	req.Header.Set("X-Environment-ID", c.auth.EnvironmentID)
 
+	if c.basicAuth != "" {
+		req.Header.Set("Authorization", c.basicAuth)
+	}
+
+	if c.requestModifier != nil {
+		if err := c.requestModifier(req); err != nil {
+			return nil, errorf("request modifier failed: %w", err)
+		}
+		// the modifier set the upstreams own auth, so the proxy gets its basic auth from the proxy header
+		if c.basicAuth != "" && req.Header.Get("Authorization") != c.basicAuth {
+			req.Header.Set("Proxy-Authorization", c.basicAuth)
+		}
+	}
+
+	// whether the connection was kept alive from an earlier request
+	var reused bool
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = info.Reused
+		},
+	}))
+
+	sent := time.Now()
 	resp, err := c.httpClient.Do(req)
+	if region != -1 {
+		c.regionSet.record(region, time.Since(sent), err != nil || resp.StatusCode >= http.StatusInternalServerError, time.Now())
+	}
 	if err != nil {
-		return nil, logs.Errorf("failed to execute request: %v", err)
+		return nil, errorf("%w: failed to execute request: %w", ErrUpstream, err)
 	}
 	defer func() {
 		if resp != nil && resp.Body != nil {
 			if err := resp.Body.Close(); err != nil {
-				_ = logs.Errorf("error closing response body: %v", err)
+				c.reportError(errorf("error closing response body: %v", err))
 			}
 		}
 	}()
+	c.stats.recordConnection(resp.Proto, reused)
+
+	if c.forceHTTP2 && resp.ProtoMajor != 2 {
+		return nil, errorf("%w: the api negotiated %s rather than HTTP/2", ErrUpstream, resp.Proto)
+	}
 
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, errorf("%w: unauthorized, check the project, agent, and environment IDs: status code %d", ErrUpstream, resp.StatusCode)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, logs.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, errorf("%w: unexpected status code: %d", ErrUpstream, resp.StatusCode)
+	}
+
+	wire := &countingReader{reader: resp.Body}
+	var body io.Reader = wire
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(wire)
+		if err != nil {
+			return nil, errorf("%w: failed to decompress body %w", ErrDecode, err)
+		}
+		defer func() {
+			if err := gz.Close(); err != nil {
+				c.reportError(errorf("error closing gzip reader: %v", err))
+			}
+		}()
+		body = gz
 	}
 
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, errorf("%w: failed to read body %w", ErrUpstream, err)
+	}
+	c.stats.record(wire.count, int64(len(data)))
+
+	apiResp, err := decodeResponse(data)
+	if err != nil {
+		return nil, errorf("%w: failed to decode body %w", ErrDecode, err)
+	}
+	if maxAge, ok := cacheMaxAge(resp.Header); ok {
+		// max-age is always seconds, intervalAllowed is in the APIs unit
+		apiResp.IntervalAllowed = int(time.Duration(maxAge) * time.Second / c.unit())
+	}
+	if c.payloadCapture != "" {
+		if err := c.capturePayload(data); err != nil {
+			c.reportError(errorf("failed to capture payload: %v", err))
+		}
+	}
+	return apiResp, nil
+}
+
+// cacheMaxAge gives the max-age of the responses Cache-Control header, when it has one it's the refresh interval
+// rather than intervalAllowed, so a proxy or CDN in front of the API can set it. A max-age of 0 (or a missing or
+// malformed one) leaves intervalAllowed, refetching for every evaluation would hammer the API
+func cacheMaxAge(header http.Header) (int, bool) {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, val, ok := strings.Cut(strings.TrimSpace(directive), "=")
+		if !ok || !strings.EqualFold(name, "max-age") {
+			continue
+		}
+
+		maxAge, err := strconv.Atoi(strings.Trim(val, `"`))
+		if err != nil || maxAge <= 0 {
+			return 0, false
+		}
+		return maxAge, true
+	}
+	return 0, false
+}
+
+// decodeResponse decodes a flag set, one without a flags list (e.g. an error body from a proxy) is as malformed as
+// a truncated one, caching it would wipe the flags. An empty list is fine
+func decodeResponse(data []byte) (*ApiResponse, error) {
 	var apiResp ApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, logs.Errorf("failed to decode body %v", err)
+	if err := json.Unmarshal(data, &apiResp); err != nil {
+		return nil, err
+	}
+	if apiResp.Flags == nil {
+		return nil, errors.New("no flags in response")
 	}
 	return &apiResp, nil
 }
 
+// refreshIfStale refetches the flags when the cache is stale, concurrent callers wait on the one refetch
+func (c *Client) refreshIfStale() error {
+	if c.readOnly || !c.Cache.ShouldRefreshCache() || c.backingOff() {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.Cache.ShouldRefreshCache() {
+		return nil
+	}
+
+	if err := c.doRefetch(); err != nil {
+		return err
+	}
+	c.misses.reset()
+	return nil
+}
+
+// refreshWithinBudget is refreshIfStale capped at the max evaluation time, when the budget runs out the refetch
+// carries on in the background and the evaluation fails closed. Within the soft TTL only a sample of calls refetch,
+// offline first never waits on the refetch
+func (c *Client) refreshWithinBudget() error {
+	if c.readOnly || !c.Cache.ShouldRefreshCache() || c.skipRefetch() || c.backingOff() {
+		return nil
+	}
+	if c.offlineFirst {
+		c.revalidate()
+		return nil
+	}
+	if c.maxEvaluationTime <= 0 {
+		return c.refreshIfStale()
+	}
+
+	done := make(chan error, 1)
+	if !c.background.goroutine(func() {
+		done <- c.refreshIfStale()
+	}) {
+		return ErrClientClosed
+	}
+
+	timer := time.NewTimer(c.maxEvaluationTime)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return c.errorf("evaluation time of %s exceeded", c.maxEvaluationTime)
+	}
+}
+
+// refetchEvicted refetches when the memory limit has evicted the flag, concurrent callers wait on the one refetch
+func (c *Client) refetchEvicted(name string) error {
+	if c.readOnly || !c.Cache.Evicted(name) {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.Cache.Evicted(name) {
+		return nil
+	}
+
+	return c.doRefetch()
+}
+
 func (c *Client) refetch() error {
-	if c.circuitState.isOpen {
-		if time.Since(c.circuitState.lastFailure) < 10*time.Second {
-			return nil
-		}
-		c.circuitState.isOpen = false
-		c.circuitState.failureCount = 0
+	if c.readOnly {
+		return nil
 	}
 
-	var apiResp *ApiResponse
-	var err error
-	for retry := 0; retry < c.maxRetries; retry++ {
-		apiResp, err = c.fetchFlags()
-		if err == nil {
-			c.circuitState.failureCount = 0
-			break
-		}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-		c.circuitState.failureCount++
-		if c.circuitState.failureCount >= c.maxRetries {
-			c.circuitState.isOpen = true
-			c.circuitState.lastFailure = time.Now()
-			return nil
+	return c.doRefetch()
+}
+
+// doRefetch expects the caller to hold the mutex
+func (c *Client) doRefetch() (err error) {
+	start := time.Now()
+	defer func() {
+		c.stats.lastRetryDuration.Store(int64(time.Since(start)))
+	}()
+
+	ctx, span := c.startSpan(c.Cache.Context, "flags.fetch")
+	attempts := 0
+	defer func() {
+		span.SetAttributes(
+			attribute.String("flags.source", c.transport.source()),
+			attribute.Int("flags.retries", max(attempts-1, 0)),
+		)
+		if err != nil {
+			recordError(span, err)
 		}
+		span.End()
+	}()
 
-		time.Sleep(time.Duration(retry+1) * time.Second)
+	apiResp, err := c.retrier().do(ctx, func(ctx context.Context) (*ApiResponse, error) {
+		attempts++
+		if c.bootstrapURL != "" {
+			// only tried once, the retry goes to /flags
+			ctx = context.WithValue(ctx, bootstrapKey{}, c.bootstrapURL)
+			c.bootstrapURL = ""
+		}
+		return c.timedFetch(ctx)
+	})
+	if errors.Is(err, ErrCircuitOpen) {
+		c.reportRefresh(RefreshEvent{Source: c.transport.source(), Duration: time.Since(start), Err: err})
+		return nil
 	}
-
 	if err != nil || apiResp == nil {
-		return logs.Errorf("failed to fetch flags: %v", err)
+		c.backOff()
+		err = c.errorf("failed to fetch flags: %w", err)
+		c.reportRefresh(RefreshEvent{Source: c.transport.source(), Duration: time.Since(start), Err: err})
+		return err
+	}
+
+	return c.apply(apiResp, c.transport.source(), start)
+}
+
+// apply replaces the cached flags with the flag set from the source, reporting the refresh that started at start.
+// It expects the caller to hold the mutex
+func (c *Client) apply(apiResp *ApiResponse, source string, start time.Time) error {
+	accepted, err := c.accept(apiResp)
+	if err != nil {
+		c.reportRefresh(RefreshEvent{Source: source, Duration: time.Since(start), Err: err})
+		return err
+	}
+	if len(apiResp.Flags) == 0 && len(accepted.Flags) > 0 {
+		c.warnf("the flag set from %s is empty, keeping the %d cached flags", source, len(accepted.Flags))
+	}
+	apiResp = accepted
+	if err := apiResp.validate(); err != nil {
+		c.reportError(c.errorf("invalid flags in response: %v", err))
 	}
 
 	var flags []flag.FeatureFlag
 	for _, f := range apiResp.Flags {
-		ff := flag.FeatureFlag{
-			Enabled: f.Enabled,
-			Details: flag.Details{
-				Name: strings.ToLower(f.Details.Name),
-				ID:   f.Details.ID,
-			},
-		}
-		flags = append(flags, ff)
+		f.Details.Name = strings.ToLower(f.Details.Name)
+		flags = append(flags, f)
+	}
+
+	// only diffed for the refresh handler and subscribers, reading the whole cache isn't free
+	var previous map[string]flag.FeatureFlag
+	diffed := c.refreshHandler != nil || c.subscribers.any()
+	if diffed {
+		previous, _ = c.Cache.GetAllMap()
 	}
 
-	if err := c.Cache.CacheSystem.Refresh(flags, apiResp.IntervalAllowed); err != nil {
-		return logs.Errorf("failed to set cache: %v", err)
+	if err := c.Cache.Refresh(flags, c.jitter(c.intervalSeconds(apiResp.IntervalAllowed))); err != nil {
+		err = c.errorf("%w: failed to set cache: %w", ErrCacheUnavailable, err)
+		c.reportRefresh(RefreshEvent{Source: source, Duration: time.Since(start), Err: err})
+		return err
+	}
+	c.retryAt.Store(0)
+	c.evalCache.purge()
+	c.watchers.notify(c)
+	var changes []FlagChange
+	if diffed {
+		changes = flagChanges(previous, flags, c.now())
+		c.stats.droppedChanges.Add(c.subscribers.publish(changes))
 	}
+	c.reportRefresh(RefreshEvent{Source: source, Flags: len(flags), Changed: len(changes), Duration: time.Since(start)})
 
 	return nil
 }
 
-func buildLocal() map[string]bool {
-	col := make(map[string]bool, len(os.Environ()))
-	for _, e := range os.Environ() {
+// accept runs the flag set through the response validator and WithRejectEmptyFlagSet, giving the set a refresh
+// caches. It expects the caller to hold the mutex
+func (c *Client) accept(apiResp *ApiResponse) (*ApiResponse, error) {
+	if c.responseValidator != nil {
+		if err := c.responseValidator(apiResp); err != nil {
+			return nil, c.errorf("%w: %w", ErrRejected, err)
+		}
+	}
+	if c.rejectEmpty && len(apiResp.Flags) == 0 {
+		if previous, err := c.Cache.GetAll(); err == nil && len(previous) > 0 {
+			// cached again with the new interval, so they're served and not refetched until then
+			return &ApiResponse{IntervalAllowed: apiResp.IntervalAllowed, Flags: previous}, nil
+		}
+	}
+	return apiResp, nil
+}
+
+// buildLocal parses the FLAGS_ env vars into overrides
+func buildLocal(env []string) map[string]bool {
+	col := make(map[string]bool, len(env))
+	for _, e := range env {
 		pair := strings.SplitN(e, "=", 2)
 		if len(pair) != 2 {
 			continue
@@ -269,13 +1359,41 @@ func buildLocal() map[string]bool {
 			continue
 		}
 
-		value := val == "true"
+		addLocal(col, strings.TrimPrefix(key, "FLAGS_"), val)
+	}
+
+	return col
+}
+
+// buildFileLocal reads the overrides from a directory, one file per flag containing true or false
+func (c *Client) buildFileLocal(dir string) map[string]bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.reportError(c.errorf("failed to read override dir: %v", err))
+		}
+		return nil
+	}
+
+	col := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		// skip hidden files, kubernetes keeps its ..data links there
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
 
-		colKey := strings.ToLower(strings.TrimPrefix(key, "FLAGS_"))
-		col[colKey] = value
-		col[strings.ReplaceAll(colKey, "_", "-")] = value
-		col[strings.ReplaceAll(colKey, "_", " ")] = value
+		val, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			c.reportError(c.errorf("failed to read override file: %v", err))
+			continue
+		}
+		addLocal(col, entry.Name(), strings.TrimSpace(string(val)))
 	}
 
 	return col
 }
+
+// addLocal adds the override under its lowercased name, lookupLocal finds it for the other forms of the name
+func addLocal(col map[string]bool, key, val string) {
+	col[strings.ToLower(key)] = val == "true"
+}
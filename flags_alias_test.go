@@ -0,0 +1,61 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithAlias(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "new-checkout", "id": "1"}},
+				{"enabled": false, "details": {"name": "new-search", "id": "2"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithAlias("Old-Checkout", "new-checkout"), WithAlias("old-search", "new-search"))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	var enabled, searchEnabled bool
+	out := captureOutput(t, func() {
+		for i := 0; i < 3; i++ {
+			enabled = client.Is("old-checkout").Enabled()
+		}
+		searchEnabled = client.Is("OLD-SEARCH").Enabled()
+	})
+
+	if !enabled {
+		t.Error("Expected old-checkout to give the value of new-checkout")
+	}
+	if searchEnabled {
+		t.Error("Expected old-search to give the value of new-search")
+	}
+	if got := strings.Count(out, "old-checkout is deprecated"); got != 1 {
+		t.Errorf("Expected a single deprecation warning for old-checkout, got %d in %q", got, out)
+	}
+	if got := strings.Count(out, "old-search is deprecated"); got != 1 {
+		t.Errorf("Expected a single deprecation warning for old-search, got %d in %q", got, out)
+	}
+
+	if status, err := client.Status("old-checkout"); err != nil || status != StatusEnabled {
+		t.Errorf("Expected the alias to be enabled in Status, got %s (%v)", status, err)
+	}
+	if !client.Is("new-checkout").Enabled() {
+		t.Error("Expected the new name to keep working")
+	}
+}
@@ -0,0 +1,57 @@
+package flags
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_CloseStopsBackgroundRefresh(t *testing.T) {
+	var requests atomic.Int32
+	inFlight := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case inFlight <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done() // hang until the client gives up
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), withBackgroundRefresh(10*time.Millisecond))
+
+	select {
+	case <-inFlight:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the background refresher to fetch")
+	}
+
+	start := time.Now()
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Close to cancel the in flight fetch, took %s", elapsed)
+	}
+
+	seen := requests.Load()
+	time.Sleep(50 * time.Millisecond)
+	if requests.Load() != seen {
+		t.Error("Expected no fetches once Close has returned")
+	}
+	if client.background.goroutine(func() {}) {
+		t.Error("Expected no goroutines to start once closed")
+	}
+
+	if err := client.Close(); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed from a second Close, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/flags-gg/go-flags/flag"
+)
+
+// mapCache is the least a backend has to do
+type mapCache struct {
+	mu        sync.Mutex
+	flags     map[string]flag.FeatureFlag
+	refreshed int
+	inited    bool
+	closed    bool
+}
+
+func (m *mapCache) Get(name string) (bool, bool) {
+	f, ok := m.GetFlag(name)
+	return f.Enabled, ok
+}
+
+func (m *mapCache) GetFlag(name string) (flag.FeatureFlag, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.flags[name]
+	return f, ok
+}
+
+func (m *mapCache) GetAll() ([]flag.FeatureFlag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var flags []flag.FeatureFlag
+	for _, f := range m.flags {
+		flags = append(flags, f)
+	}
+	return flags, nil
+}
+
+func (m *mapCache) GetAllMap() (map[string]flag.FeatureFlag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	flags := make(map[string]flag.FeatureFlag, len(m.flags))
+	for name, f := range m.flags {
+		flags[name] = f
+	}
+	return flags, nil
+}
+
+func (m *mapCache) Count() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.flags), nil
+}
+
+func (m *mapCache) Refresh(flags []flag.FeatureFlag, _ int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flags = make(map[string]flag.FeatureFlag, len(flags))
+	for _, f := range flags {
+		m.flags[f.Details.Name] = f
+	}
+	m.refreshed++
+	return nil
+}
+
+func (m *mapCache) ShouldRefreshCache() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.refreshed == 0
+}
+
+func (m *mapCache) Clear() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flags, m.refreshed = nil, 0
+	return nil
+}
+
+func (m *mapCache) Init() error {
+	m.inited = true
+	return nil
+}
+
+func (m *mapCache) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestWithCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	backend := &mapCache{}
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithCache(backend))
+	if client == nil {
+		t.Fatal("NewClient failed")
+	}
+	if !backend.inited {
+		t.Error("Expected the backend initialized")
+	}
+	if client.Cache.FileName != nil {
+		t.Errorf("Expected no cache file for a custom backend, got %s", *client.Cache.FileName)
+	}
+
+	if !client.Is("test-flag").Enabled() {
+		t.Error("Expected test-flag enabled")
+	}
+	if backend.refreshed != 1 {
+		t.Errorf("Expected the backend refreshed once, got %d", backend.refreshed)
+	}
+	if enabled, ok := backend.Get("test-flag"); !ok || !enabled {
+		t.Error("Expected test-flag cached in the backend")
+	}
+
+	// evaluations read the backend rather than fetching again
+	if err := backend.Refresh([]flag.FeatureFlag{{Enabled: false, Details: flag.Details{Name: "test-flag"}}}, 60); err != nil {
+		t.Fatal(err)
+	}
+	if client.Is("test-flag").Enabled() {
+		t.Error("Expected test-flag read from the backend")
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !backend.closed {
+		t.Error("Expected the backend closed")
+	}
+}
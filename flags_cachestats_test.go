@@ -0,0 +1,97 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_CacheStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}, {"enabled": false, "details": {"name": "other-flag", "id": "2"}}]}`)
+	}))
+	defer server.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	t.Run("stats and reset", func(t *testing.T) {
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory())
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+
+		stats, err := client.CacheStats()
+		if err != nil {
+			t.Fatalf("CacheStats: %v", err)
+		}
+		if stats.Flags != 2 || stats.Stale || !stats.Memory {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+		if until := time.Until(stats.NextRefresh); until <= 0 || until > time.Minute {
+			t.Errorf("Expected the next refresh within the minute, got %v", stats.NextRefresh)
+		}
+
+		if err := client.Reset(); err != nil {
+			t.Fatalf("Reset: %v", err)
+		}
+		stats, err = client.CacheStats()
+		if err != nil {
+			t.Fatalf("CacheStats: %v", err)
+		}
+		if stats.Flags != 0 || !stats.Stale {
+			t.Errorf("Expected an empty stale cache after Reset, got %+v", stats)
+		}
+
+		if !client.Is("test-flag").Enabled() {
+			t.Error("Expected the evaluation after Reset to refetch")
+		}
+	})
+
+	// run with -race, the controlled API is used alongside refreshes
+	t.Run("concurrent with refreshes", func(t *testing.T) {
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory())
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					if err := client.refetch(); err != nil {
+						t.Errorf("refetch: %v", err)
+					}
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					stats, err := client.CacheStats()
+					if err != nil {
+						t.Errorf("CacheStats: %v", err)
+					}
+					// a refresh is all or nothing
+					if stats.Flags != 0 && stats.Flags != 2 {
+						t.Errorf("Expected 0 or 2 flags, got %d", stats.Flags)
+					}
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					if err := client.Reset(); err != nil {
+						t.Errorf("Reset: %v", err)
+					}
+					client.Is("test-flag").Enabled()
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
@@ -0,0 +1,152 @@
+package flags
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/flags-gg/go-flags/flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWithPayloadCapture(t *testing.T) {
+	response := `{
+		"intervalAllowed": 60,
+		"flags": [
+			{"enabled": true, "details": {"name": "Enabled-Flag", "id": "1"}},
+			{"enabled": false, "details": {"name": "disabled-flag", "id": "2"}},
+			{"enabled": true, "value": "blue", "tags": ["ui"], "details": {"name": "colour", "id": "3"}}
+		]
+	}`
+
+	tests := []struct {
+		name string
+		gzip bool
+	}{
+		{name: "plain"},
+		{name: "gzip", gzip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if tt.gzip {
+					w.Header().Set("Content-Encoding", "gzip")
+					gz := gzip.NewWriter(w)
+					_, _ = fmt.Fprintln(gz, response)
+					_ = gz.Close()
+					return
+				}
+				_, _ = fmt.Fprintln(w, response)
+			}))
+			defer server.Close()
+
+			path := filepath.Join(t.TempDir(), "payload.json")
+			client := NewClient(WithBaseURL(server.URL), WithMemory(), WithPayloadCapture(path), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}))
+			defer func() {
+				_ = client.Close()
+			}()
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Expected the payload to be captured: %v", err)
+			}
+			if string(data) != response+"\n" {
+				t.Errorf("Expected the raw body to be captured, got %q", data)
+			}
+
+			replay := NewClientFromPayload(path)
+			if replay == nil {
+				t.Fatal("Expected a client from the captured payload")
+			}
+			defer func() {
+				_ = replay.Close()
+			}()
+
+			want, err := client.List()
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			got, err := replay.List()
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			byName := func(flags []flag.FeatureFlag) {
+				sort.Slice(flags, func(i, j int) bool {
+					return flags[i].Details.Name < flags[j].Details.Name
+				})
+			}
+			byName(want)
+			byName(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected the replayed flags %+v, got %+v", want, got)
+			}
+			if !replay.Is("enabled-flag").Enabled() || replay.Is("disabled-flag").Enabled() {
+				t.Error("Expected the replayed flags to evaluate the same")
+			}
+			if got := replay.Is("colour").String(); got != "blue" {
+				t.Errorf("Expected the replayed value, got %q", got)
+			}
+		})
+	}
+}
+
+func TestWithPayloadCapture_FailedFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "payload.json")
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithMaxRetries(1), WithPayloadCapture(path), WithQuiet(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+	_ = client.refetch()
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be captured from a failed fetch, got %v", err)
+	}
+}
+
+func TestNewClientFromPayload_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"flags": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, path := range map[string]string{
+		"missing": filepath.Join(dir, "missing.json"),
+		"invalid": invalid,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var errs []error
+			client := NewClientFromPayload(path, WithLogLevel(LogLevelNone), WithErrorHandler(func(err error) {
+				errs = append(errs, err)
+			}))
+			if client != nil {
+				t.Error("Expected no client")
+			}
+			if len(errs) != 1 {
+				t.Errorf("Expected the failure to be reported, got %v", errs)
+			}
+		})
+	}
+}
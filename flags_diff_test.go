@@ -0,0 +1,105 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_Diff(t *testing.T) {
+	var changed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{"intervalAllowed": 60, "flags": [
+			{"enabled": true, "details": {"name": "same-flag", "id": "1"}},
+			{"enabled": false, "details": {"name": "toggled-flag", "id": "2"}},
+			{"enabled": true, "details": {"name": "removed-flag", "id": "3"}}
+		]}`
+		if changed.Load() {
+			response = `{"intervalAllowed": 60, "flags": [
+				{"enabled": true, "details": {"name": "Same-Flag", "id": "1"}},
+				{"enabled": true, "details": {"name": "toggled-flag", "id": "2"}},
+				{"enabled": true, "details": {"name": "added-flag", "id": "4"}}
+			]}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+
+	changes, err := client.Diff(context.Background())
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes against the same flags, got %v", describeChanges(changes))
+	}
+
+	changed.Store(true)
+	changes, err = client.Diff(context.Background())
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	want := []string{"added-flag -->true", "removed-flag true->-", "toggled-flag false->true"}
+	if got := describeChanges(changes); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// nothing is cached by a diff
+	if client.Is("toggled-flag").Enabled() || !client.Is("removed-flag").Enabled() {
+		t.Error("Expected the cached flags to be unchanged by Diff")
+	}
+}
+
+func TestClient_Diff_RefreshChecks(t *testing.T) {
+	var response atomic.Value
+	response.Store(`{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response.Load())
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithRejectEmptyFlagSet(), WithResponseValidator(func(resp *ApiResponse) error {
+		if len(resp.Flags) > 1 {
+			return errors.New("too many flags")
+		}
+		return nil
+	}), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+
+	// a refresh would keep the cached flags
+	response.Store(`{"intervalAllowed": 60, "flags": []}`)
+	changes, err := client.Diff(context.Background())
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes for a rejected empty set, got %v", describeChanges(changes))
+	}
+
+	// a refresh would reject it
+	response.Store(`{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}, {"enabled": true, "details": {"name": "other-flag", "id": "2"}}]}`)
+	if _, err := client.Diff(context.Background()); !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected ErrRejected, got %v", err)
+	}
+}
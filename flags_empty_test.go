@@ -0,0 +1,84 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithRejectEmptyFlagSet(t *testing.T) {
+	var empty atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if empty.Load() {
+			_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": []}`)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+	refetchEmpty := func(t *testing.T, client *Client) {
+		t.Helper()
+		empty.Store(false)
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+		empty.Store(true)
+		defer empty.Store(false)
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+	}
+
+	backends := map[string]func(t *testing.T) Option{
+		"memory": func(*testing.T) Option { return WithMemory() },
+		"sqlite": func(t *testing.T) Option { return WithCacheDir(t.TempDir()) },
+	}
+	for backend, storage := range backends {
+		t.Run(backend, func(t *testing.T) {
+			t.Run("keeps the cached flags", func(t *testing.T) {
+				client := NewClient(WithBaseURL(server.URL), auth, storage(t), WithRejectEmptyFlagSet(), WithLogLevel(LogLevelNone))
+				refetchEmpty(t, client)
+
+				if !client.Is("test-flag").Enabled() {
+					t.Error("Expected test-flag to still be enabled after an empty flag set")
+				}
+				if client.stale() {
+					t.Error("Expected the kept flags to be cached for the new interval")
+				}
+			})
+
+			t.Run("allows an empty set with nothing cached", func(t *testing.T) {
+				empty.Store(true)
+				defer empty.Store(false)
+				client := NewClient(WithBaseURL(server.URL), auth, storage(t), WithRejectEmptyFlagSet(), WithLogLevel(LogLevelNone))
+				if err := client.refetch(); err != nil {
+					t.Fatalf("refetch: %v", err)
+				}
+				if count, err := client.Count(); err != nil || count != 0 {
+					t.Errorf("Expected no flags, got %d (%v)", count, err)
+				}
+			})
+
+			t.Run("clears the cache without it", func(t *testing.T) {
+				client := NewClient(WithBaseURL(server.URL), auth, storage(t))
+				refetchEmpty(t, client)
+
+				if client.Is("test-flag").Enabled() {
+					t.Error("Expected an empty flag set to turn test-flag off")
+				}
+				if count, err := client.Count(); err != nil || count != 0 {
+					t.Errorf("Expected the cache to be empty, got %d (%v)", count, err)
+				}
+			})
+		})
+	}
+}
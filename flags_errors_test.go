@@ -0,0 +1,131 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrors_Is(t *testing.T) {
+	testAuth := Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}
+
+	tests := []struct {
+		name    string
+		auth    Auth
+		handler http.HandlerFunc
+		want    error
+	}{
+		{
+			name: "auth missing",
+			want: ErrAuthMissing,
+		},
+		{
+			name: "server error",
+			auth: testAuth,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			want: ErrUpstream,
+		},
+		{
+			name: "unauthorized",
+			auth: testAuth,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+			want: ErrUpstream,
+		},
+		{
+			name: "malformed body",
+			auth: testAuth,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprintln(w, `{"flags": [`)
+			},
+			want: ErrDecode,
+		},
+		{
+			name: "bad gzip",
+			auth: testAuth,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				_, _ = fmt.Fprintln(w, `not gzip`)
+			},
+			want: ErrDecode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.handler
+			if handler == nil {
+				handler = func(w http.ResponseWriter, r *http.Request) {}
+			}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			client := NewClient(WithBaseURL(server.URL), WithAuth(tt.auth), WithMemory(), WithQuiet())
+			defer func() {
+				_ = client.Close()
+			}()
+
+			err := client.Ping(context.Background())
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected errors.Is(%v, %v)", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrors_CircuitOpen(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var errs []error
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithMaxRetries(1), WithQuiet(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	client.Is("test-flag").Enabled()
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %v", errs)
+	}
+	if !errors.Is(errs[0], ErrCircuitOpen) {
+		t.Errorf("Expected the failure that opens the circuit to match ErrCircuitOpen, got %v", errs[0])
+	}
+	if !errors.Is(errs[0], ErrUpstream) {
+		t.Errorf("Expected it to still match the failure, got %v", errs[0])
+	}
+}
+
+func TestErrors_CacheUnavailable(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "missing", "flags.db")
+
+	var errs []error
+	client := NewClient(SetFileName(&fileName), WithReadOnly(), WithLogLevel(LogLevelNone), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	if client != nil {
+		t.Fatal("Expected a cache that can't be opened to fail the client")
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrCacheUnavailable) {
+		t.Errorf("Expected ErrCacheUnavailable, got %v", errs)
+	}
+}
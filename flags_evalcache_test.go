@@ -0,0 +1,231 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"github.com/flags-gg/go-flags/cache"
+	"github.com/flags-gg/go-flags/flag"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func newToggleServer(enabled *atomic.Bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := fmt.Sprintf(`{
+			"intervalAllowed": 60,
+			"flags": [{"enabled": %t, "details": {"name": "test-flag", "id": "1"}}]
+		}`, enabled.Load())
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+}
+
+func TestEvalCache_InvalidatedOnRefresh(t *testing.T) {
+	var enabled atomic.Bool
+	enabled.Store(true)
+	server := newToggleServer(&enabled)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithEvalCacheSize(10))
+
+	if !client.Is("test-flag").Enabled() {
+		t.Fatal("Expected test-flag to be enabled")
+	}
+	if client.evalCache.len() != 1 {
+		t.Errorf("Expected 1 memoized evaluation, got %d", client.evalCache.len())
+	}
+
+	enabled.Store(false)
+	if !client.Is("test-flag").Enabled() {
+		t.Error("Expected memoized value before the cache refreshes")
+	}
+
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+	if client.evalCache.len() != 0 {
+		t.Errorf("Expected eval cache to be purged on refresh, got %d entries", client.evalCache.len())
+	}
+	if client.Is("test-flag").Enabled() {
+		t.Error("Expected test-flag to be disabled after refresh")
+	}
+}
+
+func TestEvalCache_InvalidatedOnOverrideChange(t *testing.T) {
+	var enabled atomic.Bool
+	enabled.Store(true)
+	server := newToggleServer(&enabled)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithEvalCacheSize(10))
+
+	if !client.Is("test-flag").Enabled() {
+		t.Fatal("Expected test-flag to be enabled")
+	}
+
+	t.Setenv("FLAGS_TEST_FLAG", "false")
+	if client.Is("test-flag").Enabled() {
+		t.Error("Expected the env override to be picked up over the memoized value")
+	}
+
+	t.Setenv("FLAGS_TEST_FLAG", "true")
+	if !client.Is("test-flag").Enabled() {
+		t.Error("Expected the changed env override to be picked up")
+	}
+}
+
+func TestEvalCache_Eviction(t *testing.T) {
+	cache := newEvalCache(2)
+	cache.put("a", true)
+	cache.put("b", true)
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+	cache.put("c", true)
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("Expected least recently used entry b to be evicted")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Error("Expected recently used entry a to be retained")
+	}
+	if _, ok := cache.get("c"); !ok {
+		t.Error("Expected newest entry c to be retained")
+	}
+}
+
+func TestEvalCache_ConcurrentAccess(t *testing.T) {
+	var enabled atomic.Bool
+	enabled.Store(true)
+	server := newToggleServer(&enabled)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithEvalCacheSize(2))
+
+	concurrentRequests := 10
+	done := make(chan bool)
+	for i := 0; i < concurrentRequests; i++ {
+		go func(i int) {
+			client.Is(fmt.Sprintf("flag-%d", i%4)).Enabled()
+			client.Is("test-flag").Enabled()
+			done <- true
+		}(i)
+	}
+	for i := 0; i < concurrentRequests; i++ {
+		<-done
+	}
+
+	if client.evalCache.len() > 2 {
+		t.Errorf("Expected eval cache to be bounded to 2, got %d", client.evalCache.len())
+	}
+}
+
+func benchmarkEnabled(b *testing.B, opts ...Option) {
+	var enabled atomic.Bool
+	enabled.Store(true)
+	server := newToggleServer(&enabled)
+	defer server.Close()
+
+	client := NewClient(append([]Option{WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory()}, opts...)...)
+	client.Is("test-flag").Enabled()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client.Is("test-flag").Enabled()
+	}
+}
+
+func BenchmarkEnabled(b *testing.B) {
+	benchmarkEnabled(b)
+}
+
+func BenchmarkEnabled_EvalCache(b *testing.B) {
+	benchmarkEnabled(b, WithEvalCacheSize(100))
+}
+
+// countingCache counts the flag reads that reach the backend
+type countingCache struct {
+	cache.Caching
+	reads atomic.Int32
+}
+
+func (c *countingCache) GetFlag(name string) (flag.FeatureFlag, bool) {
+	c.reads.Add(1)
+	return c.Caching.GetFlag(name)
+}
+
+func TestRequestCache(t *testing.T) {
+	var enabled atomic.Bool
+	enabled.Store(true)
+	server := newToggleServer(&enabled)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory())
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+	counting := &countingCache{Caching: client.Cache.CacheSystem}
+	client.Cache.CacheSystem = counting
+
+	ctx := client.WithRequestCache(context.Background())
+	for i := 0; i < 3; i++ {
+		if !client.Is("test-flag").EnabledCtx(ctx) {
+			t.Fatal("Expected test-flag to be enabled")
+		}
+		if client.Is("unknown-flag").EnabledCtx(ctx) {
+			t.Fatal("Expected unknown-flag to be disabled")
+		}
+	}
+	if got := counting.reads.Load(); got != 2 {
+		t.Errorf("Expected one backend read per flag in the request, got %d", got)
+	}
+
+	// the values are fixed for the request, even when the flags change under it
+	enabled.Store(false)
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+	if !client.Is("test-flag").EnabledCtx(ctx) {
+		t.Error("Expected the request to keep its value")
+	}
+
+	counting.reads.Store(0)
+	next := client.WithRequestCache(context.Background())
+	if client.Is("test-flag").EnabledCtx(next) {
+		t.Error("Expected a new request to see the refreshed value")
+	}
+	client.Is("test-flag").EnabledCtx(next)
+	if got := counting.reads.Load(); got != 1 {
+		t.Errorf("Expected one backend read in the new request, got %d", got)
+	}
+
+	// without a request cache every check reads the backend
+	counting.reads.Store(0)
+	client.Is("test-flag").EnabledCtx(context.Background())
+	client.Is("test-flag").EnabledCtx(context.Background())
+	if got := counting.reads.Load(); got != 2 {
+		t.Errorf("Expected every check to read the backend, got %d", got)
+	}
+}
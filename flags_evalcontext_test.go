@@ -0,0 +1,166 @@
+package flags
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_IsWithContext(t *testing.T) {
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		evalCtx := r.URL.Query().Get("context")
+		mu.Lock()
+		fetches[evalCtx]++
+		mu.Unlock()
+
+		var attrs map[string]any
+		if evalCtx != "" {
+			if err := json.Unmarshal([]byte(evalCtx), &attrs); err != nil {
+				t.Errorf("Expected a JSON context, got %q", evalCtx)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"intervalAllowed": 60, "flags": [{"enabled": %t, "details": {"name": "pro-feature", "id": "1"}}]}`, attrs["plan"] == "pro")
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory())
+	defer func() {
+		_ = client.Close()
+	}()
+
+	enabled := func(evalCtx map[string]any) bool {
+		t.Helper()
+		f, err := client.IsWithContext(evalCtx, "pro-feature")
+		if err != nil {
+			t.Fatalf("IsWithContext: %v", err)
+		}
+		return f.Enabled()
+	}
+
+	pro := map[string]any{"userId": "42", "plan": "pro"}
+	free := map[string]any{"userId": "43", "plan": "free"}
+	for i := 0; i < 3; i++ {
+		if !enabled(pro) {
+			t.Error("Expected pro-feature enabled for the pro context")
+		}
+		if enabled(free) {
+			t.Error("Expected pro-feature disabled for the free context")
+		}
+		if client.Is("pro-feature").Enabled() {
+			t.Error("Expected pro-feature disabled without a context")
+		}
+	}
+	// the same attributes in a new map are the same context
+	if !enabled(map[string]any{"plan": "pro", "userId": "42"}) {
+		t.Error("Expected pro-feature enabled for an equal context")
+	}
+	if enabled(nil) {
+		t.Error("Expected an empty context to evaluate without one")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(fetches) != 3 {
+		t.Errorf("Expected fetches for 2 contexts and none, got %v", fetches)
+	}
+	for evalCtx, n := range fetches {
+		if n != 1 {
+			t.Errorf("Expected each context cached after one fetch, %q was fetched %d times", evalCtx, n)
+		}
+	}
+}
+
+func TestClient_IsWithContext_Bounded(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	client := NewClient(WithBaseURL(server.URL), SetFileName(&fileName), WithMaxEvaluationContexts(2), WithWebSocket("ws://127.0.0.1:1/ws"),
+		WithLogLevel(LogLevelNone), WithAuth(Auth{
+			ProjectID:     "test-project",
+			AgentID:       "test-agent",
+			EnvironmentID: "test-environment",
+		}))
+
+	contextTables := func() int {
+		t.Helper()
+		db, err := sql.Open("sqlite", fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = db.Close()
+		}()
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name LIKE '%ctx_%'`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	for _, user := range []string{"1", "2", "3", "1"} {
+		f, err := client.IsWithContext(map[string]any{"userId": user}, "test-flag")
+		if err != nil {
+			t.Fatalf("IsWithContext: %v", err)
+		}
+		if !f.Enabled() {
+			t.Errorf("Expected test-flag enabled for user %s", user)
+		}
+		if f.Client.webSocketURL != "" {
+			t.Error("Expected no WebSocket watcher for a context")
+		}
+	}
+	// user 1 was evicted by user 3, so it's fetched again
+	if got := requests.Load(); got != 4 {
+		t.Errorf("Expected 4 fetches, got %d", got)
+	}
+	if got := contextTables(); got != 2*5 {
+		t.Errorf("Expected the tables of 2 contexts, got %d tables", got)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := contextTables(); got != 0 {
+		t.Errorf("Expected the context tables dropped on Close, got %d", got)
+	}
+}
+
+func TestClient_IsWithContext_Error(t *testing.T) {
+	client := NewClient(WithCache(&mapCache{}), WithLogLevel(LogLevelNone), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	// the backend can't be namespaced, so the context can't be cached
+	f, err := client.IsWithContext(map[string]any{"userId": "42"}, "test-flag")
+	if !errors.Is(err, ErrCacheUnavailable) || f != nil {
+		t.Errorf("Expected ErrCacheUnavailable and no flag, got %v, %v", f, err)
+	}
+	if _, err := client.IsWithContext(map[string]any{"bad": make(chan int)}, "test-flag"); err == nil {
+		t.Error("Expected a context that can't be encoded to error")
+	}
+}
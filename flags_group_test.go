@@ -0,0 +1,64 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Group(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "checkout-ui", "id": "1"}},
+				{"enabled": true, "details": {"name": "checkout-api", "id": "2"}},
+				{"enabled": false, "details": {"name": "checkout-payments", "id": "3"}},
+				{"enabled": false, "details": {"name": "search-ui", "id": "4"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	client.Group("Checkout-Frontend", "checkout-ui", "checkout-api")
+	client.Group("checkout", "checkout-ui", "checkout-api", "checkout-payments")
+	client.Group("search", "search-ui", "search-missing")
+	client.Group("empty")
+
+	tests := []struct {
+		group      string
+		all, anyOn bool
+	}{
+		{group: "checkout-frontend", all: true, anyOn: true},
+		{group: "checkout", all: false, anyOn: true},
+		{group: "search", all: false, anyOn: false},
+		{group: "empty", all: false, anyOn: false},
+		{group: "unregistered", all: false, anyOn: false},
+	}
+	for _, tt := range tests {
+		if got := client.GroupEnabled(tt.group); got != tt.all {
+			t.Errorf("GroupEnabled(%q) = %t, expected %t", tt.group, got, tt.all)
+		}
+		if got := client.GroupAnyEnabled(tt.group); got != tt.anyOn {
+			t.Errorf("GroupAnyEnabled(%q) = %t, expected %t", tt.group, got, tt.anyOn)
+		}
+	}
+
+	// registering again replaces the members
+	client.Group("checkout", "checkout-ui", "checkout-api")
+	if !client.GroupEnabled("checkout") {
+		t.Error("Expected checkout to be enabled once checkout-payments isn't a member")
+	}
+}
@@ -0,0 +1,185 @@
+package flags
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"net"
+	"testing"
+)
+
+type fakeFlagService interface {
+	GetFlags(ctx context.Context, in *emptypb.Empty) (*structpb.Struct, error)
+}
+
+type flagService struct {
+	projectID   string
+	evalContext string
+	response    map[string]interface{}
+}
+
+func (s *flagService) GetFlags(ctx context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get("x-project-id"); len(ids) == 1 {
+			s.projectID = ids[0]
+		}
+		if evalCtx := md.Get("x-flags-context"); len(evalCtx) == 1 {
+			s.evalContext = evalCtx[0]
+		}
+	}
+	return structpb.NewStruct(s.response)
+}
+
+var flagServiceDesc = grpc.ServiceDesc{
+	ServiceName: "flags.v1.FlagService",
+	HandlerType: (*fakeFlagService)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetFlags",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(emptypb.Empty)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return srv.(fakeFlagService).GetFlags(ctx, in)
+			},
+		},
+	},
+}
+
+func testFlagService() *flagService {
+	return &flagService{
+		response: map[string]interface{}{
+			"intervalAllowed": 60,
+			"flags": []interface{}{
+				map[string]interface{}{"enabled": true, "details": map[string]interface{}{"name": "Enabled-Flag", "id": "1"}},
+				map[string]interface{}{"enabled": false, "details": map[string]interface{}{"name": "disabled-flag", "id": "2"}},
+			},
+		},
+	}
+}
+
+func dialFlagService(t *testing.T, service *flagService) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	server.RegisterService(&flagServiceDesc, service)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial bufconn: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+func TestFeatureFlags_GRPC(t *testing.T) {
+	service := testFlagService()
+	conn := dialFlagService(t, service)
+
+	client := NewClient(WithGRPC(conn), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory())
+
+	tests := []struct {
+		name     string
+		flagName string
+		want     bool
+	}{
+		{
+			name:     "enabled flag returns true",
+			flagName: "enabled-flag",
+			want:     true,
+		},
+		{
+			name:     "disabled flag returns false",
+			flagName: "disabled-flag",
+			want:     false,
+		},
+		{
+			name:     "non-existent flag returns false",
+			flagName: "non-existent",
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := client.Is(tt.flagName).Enabled()
+			if got != tt.want {
+				t.Errorf("Flag %s: got %v, want %v", tt.flagName, got, tt.want)
+			}
+		})
+	}
+
+	if service.projectID != "test-project" {
+		t.Errorf("Expected project ID metadata to be test-project, got %s", service.projectID)
+	}
+}
+
+func TestFeatureFlags_GRPC_EvaluationContext(t *testing.T) {
+	service := testFlagService()
+	conn := dialFlagService(t, service)
+
+	client := NewClient(WithGRPC(conn), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory())
+	defer client.Close()
+
+	f, err := client.IsWithContext(map[string]any{"plan": "pro"}, "enabled-flag")
+	if err != nil {
+		t.Fatalf("IsWithContext: %v", err)
+	}
+	if !f.Enabled() {
+		t.Error("Expected enabled-flag to be enabled")
+	}
+	if service.evalContext != `{"plan":"pro"}` {
+		t.Errorf("Expected x-flags-context metadata to be the evaluation context, got %q", service.evalContext)
+	}
+}
+
+func TestFeatureFlags_GRPC_HTTPOnlyOptions(t *testing.T) {
+	conn := dialFlagService(t, testFlagService())
+
+	tests := []struct {
+		name   string
+		option Option
+	}{
+		{
+			name:   "bootstrap url",
+			option: WithBootstrapURL("https://cdn.example.com/snapshot.json"),
+		},
+		{
+			name:   "regions",
+			option: WithRegions([]Region{{Name: "eu", BaseURL: "https://eu.example.com"}}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if client := NewClient(WithGRPC(conn), tt.option, WithMemory()); client != nil {
+				client.Close()
+				t.Error("Expected NewClient to fail for an HTTP only option with WithGRPC")
+			}
+		})
+	}
+}
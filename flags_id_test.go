@@ -0,0 +1,195 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIsID(t *testing.T) {
+	var renamed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := "checkout-v2"
+		if renamed.Load() {
+			name = "New-Checkout"
+		}
+		response := fmt.Sprintf(`{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "value": "blue", "details": {"name": %q, "id": "flag-1"}},
+				{"enabled": false, "details": {"name": "disabled-flag", "id": "flag-2"}},
+				{"enabled": true, "rollout": 50, "details": {"name": "half-rollout", "id": "flag-3"}}
+			]
+		}`, name)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	backends := map[string]func(t *testing.T) []Option{
+		"memory": func(t *testing.T) []Option {
+			return []Option{WithMemory()}
+		},
+		"sqlite": func(t *testing.T) []Option {
+			fileName := filepath.Join(t.TempDir(), "flags.db")
+			return []Option{SetFileName(&fileName)}
+		},
+		"sqlite encrypted": func(t *testing.T) []Option {
+			fileName := filepath.Join(t.TempDir(), "flags.db")
+			return []Option{SetFileName(&fileName), WithCacheEncryption([]byte("0123456789abcdef"))}
+		},
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			renamed.Store(false)
+			client := NewClient(append(backend(t), WithBaseURL(server.URL), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}))...)
+			defer func() {
+				_ = client.Close()
+			}()
+
+			byID := client.IsID("flag-1")
+			tests := []struct {
+				name string
+				id   string
+			}{
+				{name: "checkout-v2", id: "flag-1"},
+				{name: "disabled-flag", id: "flag-2"},
+				{name: "half-rollout", id: "flag-3"},
+			}
+			for _, tt := range tests {
+				if got, want := client.IsID(tt.id).Enabled(), client.Is(tt.name).Enabled(); got != want {
+					t.Errorf("%s: by ID got %v, by name %v", tt.name, got, want)
+				}
+				for _, key := range []string{"user-1", "user-2", "user-3", "user-4"} {
+					if got, want := client.IsID(tt.id).EnabledFor(key), client.Is(tt.name).EnabledFor(key); got != want {
+						t.Errorf("%s for %s: by ID got %v, by name %v", tt.name, key, got, want)
+					}
+				}
+			}
+			if !byID.Enabled() {
+				t.Error("Expected flag-1 to be enabled")
+			}
+			if got := byID.String(); got != "blue" {
+				t.Errorf("Expected the value by ID, got %q", got)
+			}
+			if client.IsID("unknown-id").Enabled() {
+				t.Error("Expected an unknown ID to be off")
+			}
+
+			renamed.Store(true)
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+			if client.Is("checkout-v2").Enabled() {
+				t.Error("Expected the old name to be gone after the rename")
+			}
+			if got, want := byID.Enabled(), client.Is("new-checkout").Enabled(); !got || got != want {
+				t.Errorf("Expected the ID to follow the rename, by ID got %v, by name %v", got, want)
+			}
+			if got := byID.String(); got != "blue" {
+				t.Errorf("Expected the value by ID after the rename, got %q", got)
+			}
+		})
+	}
+}
+
+func TestFlag_ID(t *testing.T) {
+	var swapped atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// swapping the names of two flags, each ID keeps its own value
+		first, second := "primary", "secondary"
+		if swapped.Load() {
+			first, second = second, first
+		}
+		response := fmt.Sprintf(`{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": %q, "id": "flag-1"}},
+				{"enabled": false, "details": {"name": %q, "id": "flag-2"}}
+			]
+		}`, first, second)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	for _, memory := range []bool{true, false} {
+		t.Run(fmt.Sprintf("memory %t", memory), func(t *testing.T) {
+			swapped.Store(false)
+			fileName := filepath.Join(t.TempDir(), "flags.db")
+			backend := WithMemory()
+			if !memory {
+				backend = SetFileName(&fileName)
+			}
+			client := NewClient(WithBaseURL(server.URL), backend, WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}))
+			defer func() {
+				_ = client.Close()
+			}()
+
+			flag1, flag2 := client.IsID("flag-1"), client.IsID("flag-2")
+			tests := []struct {
+				swapped        bool
+				primaryID      string
+				secondaryID    string
+				primaryEnabled bool
+			}{
+				{swapped: false, primaryID: "flag-1", secondaryID: "flag-2", primaryEnabled: true},
+				{swapped: true, primaryID: "flag-2", secondaryID: "flag-1", primaryEnabled: false},
+			}
+			for _, tt := range tests {
+				swapped.Store(tt.swapped)
+				if err := client.refetch(); err != nil {
+					t.Fatalf("refetch: %v", err)
+				}
+
+				if got := client.Is("primary").ID(); got != tt.primaryID {
+					t.Errorf("swapped %t: expected primary to be %s, got %s", tt.swapped, tt.primaryID, got)
+				}
+				if got := client.Is("Secondary").ID(); got != tt.secondaryID {
+					t.Errorf("swapped %t: expected secondary to be %s, got %s", tt.swapped, tt.secondaryID, got)
+				}
+				if got := client.Is("primary").Enabled(); got != tt.primaryEnabled {
+					t.Errorf("swapped %t: expected primary to be %v, got %v", tt.swapped, tt.primaryEnabled, got)
+				}
+				if flag1.ID() != "flag-1" || !flag1.Enabled() {
+					t.Errorf("swapped %t: expected flag-1 to stay enabled under any name", tt.swapped)
+				}
+				if flag2.ID() != "flag-2" || flag2.Enabled() {
+					t.Errorf("swapped %t: expected flag-2 to stay disabled under any name", tt.swapped)
+				}
+			}
+
+			if got := client.Is("unknown").ID(); got != "" {
+				t.Errorf("Expected an unknown flag to have no ID, got %s", got)
+			}
+
+			if memory {
+				return
+			}
+			// the IDs are persisted, another client on the same file resolves them without fetching
+			reopened := NewClient(SetFileName(&fileName), WithBaseURL("http://127.0.0.1:0"), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}))
+			defer func() {
+				_ = reopened.Close()
+			}()
+			if !reopened.IsID("flag-1").Enabled() || reopened.Is("secondary").ID() != "flag-1" {
+				t.Error("Expected the persisted IDs to resolve in another client")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("FLAGS_TEST_REGION", "eu-west-1")
+	t.Setenv("FLAGS_TEST_EMPTY", "")
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "no placeholders", value: "plain value", want: "plain value"},
+		{name: "substitution", value: "https://${FLAGS_TEST_REGION}.example.com", want: "https://eu-west-1.example.com"},
+		{name: "repeated", value: "${FLAGS_TEST_REGION}/${FLAGS_TEST_REGION}", want: "eu-west-1/eu-west-1"},
+		{name: "set but empty", value: "a${FLAGS_TEST_EMPTY}b", want: "ab"},
+		{name: "missing var left intact", value: "https://${FLAGS_TEST_MISSING}.example.com", want: "https://${FLAGS_TEST_MISSING}.example.com"},
+		{name: "escaped", value: "$${FLAGS_TEST_REGION}", want: "${FLAGS_TEST_REGION}"},
+		{name: "escaped next to a placeholder", value: "$${FLAGS_TEST_REGION}=${FLAGS_TEST_REGION}", want: "${FLAGS_TEST_REGION}=eu-west-1"},
+		{name: "unterminated", value: "${FLAGS_TEST_REGION", want: "${FLAGS_TEST_REGION"},
+		{name: "empty name", value: "${}", want: "${}"},
+		{name: "lone dollar", value: "$5 and $FLAGS_TEST_REGION", want: "$5 and $FLAGS_TEST_REGION"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interpolate(tt.value); got != tt.want {
+				t.Errorf("interpolate(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlag_String(t *testing.T) {
+	t.Setenv("FLAGS_TEST_REGION", "eu-west-1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "value": "https://${FLAGS_TEST_REGION}.example.com", "details": {"name": "endpoint", "id": "1"}},
+				{"enabled": false, "value": "unused", "details": {"name": "disabled-endpoint", "id": "2"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	backends := map[string]Option{
+		"memory": WithMemory(),
+		"sqlite": SetFileName(&fileName),
+	}
+
+	for name, backend := range backends {
+		for _, interpolation := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s interpolation %t", name, interpolation), func(t *testing.T) {
+				opts := []Option{WithBaseURL(server.URL), backend, WithAuth(Auth{
+					ProjectID:     "test-project",
+					AgentID:       "test-agent",
+					EnvironmentID: "test-environment",
+				})}
+				want := "https://${FLAGS_TEST_REGION}.example.com"
+				if interpolation {
+					opts = append(opts, WithInterpolation())
+					want = "https://eu-west-1.example.com"
+				}
+
+				client := NewClient(opts...)
+				defer func() {
+					_ = client.Close()
+				}()
+
+				if got := client.Is("endpoint").String(); got != want {
+					t.Errorf("Expected %q, got %q", want, got)
+				}
+				if got := client.Is("disabled-endpoint").String(); got != "" {
+					t.Errorf("Expected a disabled flag to have no value, got %q", got)
+				}
+				if got := client.Is("unknown").String(); got != "" {
+					t.Errorf("Expected an unknown flag to have no value, got %q", got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithIntervalUnit(t *testing.T) {
+	maxAge := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maxAge != "" {
+			w.Header().Set("Cache-Control", "max-age="+maxAge)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 90000, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	nextRefresh := func(t *testing.T, opts ...Option) time.Duration {
+		t.Helper()
+		filename := filepath.Join(t.TempDir(), "flags.db")
+		client := NewClient(append(opts, WithBaseURL(server.URL), SetFileName(&filename), WithAuth(Auth{
+			ProjectID:     "test-project",
+			AgentID:       "test-agent",
+			EnvironmentID: "test-environment",
+		}))...)
+		defer func() {
+			_ = client.Close()
+		}()
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+		next, ok := client.Cache.NextRefresh()
+		if !ok {
+			t.Fatal("Expected the cache to have a next refresh")
+		}
+		return time.Until(next)
+	}
+	// next_refresh_time is kept in whole seconds
+	within := func(got, want time.Duration) bool {
+		return got > want-2*time.Second && got <= want+time.Second
+	}
+
+	t.Run("seconds by default", func(t *testing.T) {
+		if got := nextRefresh(t); !within(got, 90000*time.Second) {
+			t.Errorf("Expected the next refresh in 25h, got %v", got)
+		}
+	})
+
+	t.Run("milliseconds", func(t *testing.T) {
+		if got := nextRefresh(t, WithIntervalUnit(time.Millisecond)); !within(got, 90*time.Second) {
+			t.Errorf("Expected the next refresh in 90s, got %v", got)
+		}
+	})
+
+	t.Run("part of a second rounds up", func(t *testing.T) {
+		client := NewClient(WithMemory(), WithIntervalUnit(time.Millisecond))
+		if got := client.intervalSeconds(1500); got != 2 {
+			t.Errorf("Expected 1500ms to be 2s, got %d", got)
+		}
+		if got := client.intervalSeconds(0); got != 0 {
+			t.Errorf("Expected no interval to stay none, got %d", got)
+		}
+	})
+
+	t.Run("max-age is still seconds", func(t *testing.T) {
+		maxAge = "30"
+		defer func() {
+			maxAge = ""
+		}()
+		if got := nextRefresh(t, WithIntervalUnit(time.Millisecond)); !within(got, 30*time.Second) {
+			t.Errorf("Expected the next refresh in 30s, got %v", got)
+		}
+	})
+}
+
+func TestWithDefaultInterval(t *testing.T) {
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	storage := map[string]func(t *testing.T) Option{
+		"memory": func(*testing.T) Option { return WithMemory() },
+		"sqlite": func(t *testing.T) Option { return WithCacheDir(t.TempDir()) },
+	}
+	tests := []struct {
+		name     string
+		opts     []Option
+		interval time.Duration
+	}{
+		{"default", nil, time.Minute},
+		{"set", []Option{WithDefaultInterval(5 * time.Minute)}, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		// both backends have to schedule the first refresh the same
+		for backend, option := range storage {
+			t.Run(tt.name+" "+backend, func(t *testing.T) {
+				client := NewClient(append(tt.opts, option(t), auth)...)
+				if client == nil {
+					t.Fatal("NewClient failed")
+				}
+				defer func() {
+					_ = client.Close()
+				}()
+
+				stats, err := client.CacheStats()
+				if err != nil {
+					t.Fatalf("CacheStats: %v", err)
+				}
+				if stats.Interval != tt.interval || !stats.NextRefresh.IsZero() || !stats.Stale {
+					t.Errorf("Expected the first refresh due now with a %v interval, got %+v", tt.interval, stats)
+				}
+			})
+		}
+	}
+}
+
+func TestWithDefaultInterval_FailedFirstFetch(t *testing.T) {
+	var failing atomic.Bool
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	storage := map[string]func(t *testing.T) Option{
+		"memory": func(*testing.T) Option { return WithMemory() },
+		"sqlite": func(t *testing.T) Option { return WithCacheDir(t.TempDir()) },
+	}
+	for backend, option := range storage {
+		t.Run(backend, func(t *testing.T) {
+			failing.Store(true)
+			requests.Store(0)
+			client := NewClient(WithBaseURL(server.URL), option(t), WithDefaultInterval(30*time.Second), WithMaxRetries(1),
+				WithoutCircuitBreaker(), WithLogLevel(LogLevelNone), WithAuth(Auth{
+					ProjectID:     "test-project",
+					AgentID:       "test-agent",
+					EnvironmentID: "test-environment",
+				}))
+			defer func() {
+				_ = client.Close()
+			}()
+			now := time.Now()
+			client.now = func() time.Time {
+				return now
+			}
+
+			if client.Is("test-flag").Enabled() {
+				t.Error("Expected test-flag off while the first fetch fails")
+			}
+			failing.Store(false)
+
+			// the next fetch waits out the default interval
+			now = now.Add(29 * time.Second)
+			if client.Is("test-flag").Enabled() {
+				t.Error("Expected test-flag off before the default interval has passed")
+			}
+			if got := requests.Load(); got != 1 {
+				t.Errorf("Expected 1 fetch within the default interval, got %d", got)
+			}
+
+			now = now.Add(2 * time.Second)
+			if !client.Is("test-flag").Enabled() {
+				t.Error("Expected test-flag fetched once the default interval has passed")
+			}
+			if got := requests.Load(); got != 2 {
+				t.Errorf("Expected 2 fetches, got %d", got)
+			}
+		})
+	}
+
+	t.Run("without it", func(t *testing.T) {
+		failing.Store(true)
+		defer failing.Store(false)
+		requests.Store(0)
+		client := NewClient(WithBaseURL(server.URL), WithMemory(), WithMaxRetries(1), WithoutCircuitBreaker(), WithLogLevel(LogLevelNone),
+			WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}))
+		defer func() {
+			_ = client.Close()
+		}()
+		client.Is("test-flag").Enabled()
+		client.Is("test-flag").Enabled()
+		if got := requests.Load(); got != 2 {
+			t.Errorf("Expected every evaluation to fetch, got %d fetches", got)
+		}
+	})
+}
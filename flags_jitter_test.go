@@ -0,0 +1,69 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithRefreshJitter(t *testing.T) {
+	const interval = 24 * time.Hour
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"intervalAllowed": %d, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`, int(interval.Seconds()))
+	}))
+	defer server.Close()
+
+	newClient := func(opts ...Option) *Client {
+		client := NewClient(append(opts, WithBaseURL(server.URL), WithMemory(), WithAuth(Auth{
+			ProjectID:     "test-project",
+			AgentID:       "test-agent",
+			EnvironmentID: "test-environment",
+		}))...)
+		t.Cleanup(func() {
+			_ = client.Close()
+		})
+		return client
+	}
+	nextRefresh := func(client *Client) time.Time {
+		t.Helper()
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+		next, ok := client.Cache.NextRefresh()
+		if !ok {
+			t.Fatal("Expected the cache to have a next refresh")
+		}
+		return next
+	}
+
+	start := time.Now().Truncate(time.Second)
+	first, second := newClient(WithRefreshJitter(0.5)), newClient(WithRefreshJitter(0.5))
+	firstNext, secondNext := nextRefresh(first), nextRefresh(second)
+	if firstNext.Equal(secondNext) {
+		t.Errorf("Expected clients created together to refresh at different times, both at %v", firstNext)
+	}
+	for _, next := range []time.Time{firstNext, secondNext} {
+		if next.Before(start.Add(interval/2)) || next.After(time.Now().Add(interval)) {
+			t.Errorf("Expected the next refresh within half the interval before %v, got %v", start.Add(interval), next)
+		}
+	}
+
+	// only the first refresh is jittered, the instances stay apart from then on
+	first.sample = func() float64 {
+		return 0.99
+	}
+	if next := nextRefresh(first); next.Before(start.Add(interval)) {
+		t.Errorf("Expected a later refresh to use the full interval, got %v", next)
+	}
+
+	plain := newClient()
+	plain.sample = func() float64 {
+		return 0.99
+	}
+	if next := nextRefresh(plain); next.Before(start.Add(interval)) {
+		t.Errorf("Expected no jitter without WithRefreshJitter, got %v", next)
+	}
+}
@@ -0,0 +1,76 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchLatency(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every tenth fetch is slow
+		delay := 10 * time.Millisecond
+		if requests.Add(1)%10 == 0 {
+			delay = 100 * time.Millisecond
+		}
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory())
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if latency := client.FetchLatency(); latency != (LatencyStats{}) {
+		t.Errorf("Expected no latency before a fetch, got %+v", latency)
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+	}
+
+	latency := client.FetchLatency()
+	if latency.Count != 20 {
+		t.Errorf("Expected 20 fetches, got %d", latency.Count)
+	}
+	if latency.P50 < 10*time.Millisecond || latency.P50 >= 100*time.Millisecond {
+		t.Errorf("Expected p50 to be a fast fetch, got %s", latency.P50)
+	}
+	if latency.P95 < 100*time.Millisecond || latency.P99 < 100*time.Millisecond {
+		t.Errorf("Expected p95 and p99 to be slow fetches, got %s and %s", latency.P95, latency.P99)
+	}
+	if latency.P99 > time.Second {
+		t.Errorf("Expected p99 to be about 100ms, got %s", latency.P99)
+	}
+}
+
+func TestFetchLatency_Window(t *testing.T) {
+	var l fetchLatency
+	for i := 0; i < latencyWindow; i++ {
+		l.record(time.Second)
+	}
+	for i := 0; i < latencyWindow; i++ {
+		l.record(time.Millisecond)
+	}
+
+	stats := l.stats()
+	if stats.Count != 2*latencyWindow {
+		t.Errorf("Expected %d fetches counted, got %d", 2*latencyWindow, stats.Count)
+	}
+	if stats.P99 != time.Millisecond {
+		t.Errorf("Expected only the most recent fetches in the percentiles, got p99 %s", stats.P99)
+	}
+}
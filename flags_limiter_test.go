@@ -0,0 +1,79 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMaxConcurrentRefetches(t *testing.T) {
+	var inFlight, maxInFlight, requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if n <= seen || maxInFlight.CompareAndSwap(seen, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	// the smallest cap is the one every client is under
+	const clients = 5
+	var created []*Client
+	for i := 0; i < clients; i++ {
+		created = append(created, NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithMaxConcurrentRefetches(clients-i)))
+	}
+	var wg sync.WaitGroup
+	for _, client := range created {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !client.Is("test-flag").Enabled() {
+				t.Error("Expected test-flag to be enabled")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := requests.Load(); got != clients {
+		t.Errorf("Expected %d fetches, got %d", clients, got)
+	}
+	if got := maxInFlight.Load(); got != 1 {
+		t.Errorf("Expected the fetches to run one at a time, got %d at once", got)
+	}
+
+	t.Run("gives up when the context is done", func(t *testing.T) {
+		slots := &limiter{limit: 1}
+		release, err := slots.acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := slots.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the wait to time out, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithLocalKeyStyle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [
+			{"enabled": true, "details": {"name": "local_flag", "id": "1"}},
+			{"enabled": true, "details": {"name": "local-flag", "id": "2"}},
+			{"enabled": true, "details": {"name": "new-search", "id": "3"}},
+			{"enabled": true, "details": {"name": "file-flag", "id": "4"}}
+		]}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("FLAGS_LOCAL_FLAG", "false")
+	t.Setenv("FLAGS_NEW_SEARCH", "false")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file-flag"), []byte("false"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		style []Option
+		want  map[string]bool
+		// unknown is whether Validate finds FLAGS_NEW_SEARCH is for no flag
+		unknown bool
+	}{
+		{
+			name: "auto",
+			want: map[string]bool{"local_flag": false, "local-flag": true, "new-search": false, "new search": false, "file-flag": false},
+		},
+		{
+			name:  "all",
+			style: []Option{WithLocalKeyStyle(LocalKeyAll)},
+			want:  map[string]bool{"local_flag": false, "local-flag": false, "new-search": false, "new search": false, "file-flag": false},
+		},
+		{
+			name:    "exact",
+			style:   []Option{WithLocalKeyStyle(LocalKeyExact)},
+			want:    map[string]bool{"local_flag": false, "local-flag": true, "new-search": true, "new search": false, "file-flag": false},
+			unknown: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(append(tt.style, WithBaseURL(server.URL), WithMemory(), WithFileOverrideDir(dir), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}))...)
+			defer func() {
+				_ = client.Close()
+			}()
+
+			for name, want := range tt.want {
+				if got := client.Is(name).Enabled(); got != want {
+					t.Errorf("Is(%q) = %v, want %v", name, got, want)
+				}
+			}
+
+			unknown := false
+			for _, err := range client.Validate() {
+				if errors.Is(err, ErrInvalidOverride) && strings.Contains(err.Error(), "FLAGS_NEW_SEARCH") {
+					unknown = true
+				}
+			}
+			if unknown != tt.unknown {
+				t.Errorf("Expected FLAGS_NEW_SEARCH unknown to be %v", tt.unknown)
+			}
+		})
+	}
+}
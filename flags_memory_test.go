@@ -2,9 +2,11 @@ package flags
 
 import (
 	"fmt"
+	"github.com/flags-gg/go-flags/cache"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -186,3 +188,177 @@ func TestConcurrentAccess_Memory(t *testing.T) {
 		}
 	}
 }
+
+func TestList_Memory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 90,
+			"flags": [
+				{"enabled": true, "rollout": 25, "tags": ["team:payments"], "details": {"name": "Full-Flag", "id": "42"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory())
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+
+	flags, err := client.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(flags) != 1 {
+		t.Fatalf("Expected 1 flag, got %d", len(flags))
+	}
+
+	got := flags[0]
+	if got.Details.Name != "full-flag" || got.Details.ID != "42" || !got.Enabled {
+		t.Errorf("Expected the name, id, and value to be kept, got %+v", got)
+	}
+	if got.Rollout == nil || *got.Rollout != 25 || !got.HasTag("team:payments") {
+		t.Errorf("Expected the rollout and tags to be kept, got %+v", got)
+	}
+
+	memory, ok := client.Cache.CacheSystem.(*cache.Memory)
+	if !ok {
+		t.Fatalf("Expected a memory backend, got %T", client.Cache.CacheSystem)
+	}
+	metadata := memory.Metadata()
+	if metadata.TTL != 90*time.Second {
+		t.Errorf("Expected a TTL of 90s, got %s", metadata.TTL)
+	}
+	if until := time.Until(metadata.NextRefresh); until < 80*time.Second || until > 91*time.Second {
+		t.Errorf("Expected the next refresh in about 90s, got %s", until)
+	}
+}
+
+func TestWithMemoryLimit(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "flag-a", "id": "1"}},
+				{"enabled": true, "details": {"name": "flag-b", "id": "2"}},
+				{"enabled": true, "details": {"name": "flag-c", "id": "3"}},
+				{"enabled": false, "details": {"name": "flag-d", "id": "4"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemoryLimit(2), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	steps := []struct {
+		name        string
+		want        bool
+		wantFetches int32
+	}{
+		{name: "flag-a", want: true, wantFetches: 1},
+		{name: "flag-b", want: true, wantFetches: 1},
+		// past the cap, evaluating it refetches and keeps it in place of flag-a
+		{name: "flag-c", want: true, wantFetches: 2},
+		{name: "flag-b", want: true, wantFetches: 2},
+		{name: "flag-c", want: true, wantFetches: 2},
+		{name: "flag-a", want: true, wantFetches: 3},
+		{name: "flag-d", want: false, wantFetches: 4},
+		{name: "unknown", want: false, wantFetches: 4},
+	}
+	for i, step := range steps {
+		if got := client.Is(step.name).Enabled(); got != step.want {
+			t.Errorf("step %d: expected %s to be %v, got %v", i, step.name, step.want, got)
+		}
+		if got := fetches.Load(); got != step.wantFetches {
+			t.Errorf("step %d: expected %d fetches, got %d", i, step.wantFetches, got)
+		}
+		if count, _ := client.Count(); count != 4 {
+			t.Errorf("step %d: expected the evicted flags to still be counted, got %d", i, count)
+		}
+	}
+
+	// flag-b was evicted by evaluating flag-a, looking it up by ID refetches it the same as by name
+	if !client.IsID("2").Enabled() {
+		t.Error("Expected an evicted flag to be enabled by ID")
+	}
+	if got := fetches.Load(); got != 5 {
+		t.Errorf("Expected evaluating an evicted flag by ID to refetch, got %d fetches", got)
+	}
+	if flags, _ := client.List(); len(flags) != 4 {
+		t.Errorf("Expected List to include the evicted flags, got %d", len(flags))
+	}
+}
+
+func TestWithMemory_NoDatabaseFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	var resolved atomic.Int32
+	resolver := WithPathResolver(func() (string, error) {
+		resolved.Add(1)
+		return dir, nil
+	})
+
+	// the backend is picked whichever side of the storage options it's on
+	for _, opts := range [][]Option{{WithMemory(), resolver}, {resolver, WithMemory()}} {
+		client := NewClient(append(opts, WithBaseURL(server.URL), WithAuth(Auth{
+			ProjectID:     "test-project",
+			AgentID:       "test-agent",
+			EnvironmentID: "test-environment",
+		}))...)
+		if client == nil {
+			t.Fatal("NewClient failed")
+		}
+		if _, ok := client.Cache.CacheSystem.(*cache.Memory); !ok || !client.Cache.IsMemory {
+			t.Errorf("Expected the memory backend, got %T", client.Cache.CacheSystem)
+		}
+		if !client.Is("test-flag").Enabled() {
+			t.Error("Expected test-flag enabled")
+		}
+		other := client.WithAuth(Auth{ProjectID: "other-project", AgentID: "test-agent", EnvironmentID: "test-environment"})
+		if !other.Is("test-flag").Enabled() {
+			t.Error("Expected test-flag enabled for the other auth")
+		}
+		_ = other.Close()
+		if err := client.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	if n := resolved.Load(); n != 0 {
+		t.Errorf("Expected the cache path never resolved, it was %d times", n)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no database file, found %v", entries)
+	}
+	client := NewClient(WithMemory())
+	if client.Cache.FileName != nil {
+		t.Errorf("Expected no file name, got %s", *client.Cache.FileName)
+	}
+	_ = client.Close()
+}
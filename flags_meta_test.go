@@ -0,0 +1,62 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_ListWithMeta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}, {"enabled": false, "details": {"name": "other-flag", "id": "2"}}]}`)
+	}))
+	defer server.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	tests := []struct {
+		name    string
+		storage Option
+	}{
+		{"memory", WithMemory()},
+		{"sqlite", WithCacheDir(t.TempDir())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(WithBaseURL(server.URL), auth, tt.storage)
+			defer func() {
+				_ = client.Close()
+			}()
+
+			// SQLite keeps the time to the second
+			before := time.Now().Truncate(time.Second)
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+			after := time.Now()
+
+			flags, err := client.ListWithMeta()
+			if err != nil {
+				t.Fatalf("ListWithMeta: %v", err)
+			}
+			if len(flags) != 2 {
+				t.Fatalf("Expected 2 flags, got %d", len(flags))
+			}
+			for _, f := range flags {
+				if f.UpdatedAt.Before(before) || f.UpdatedAt.After(after) {
+					t.Errorf("Expected %s updated between %v and %v, got %v", f.Details.Name, before, after, f.UpdatedAt)
+				}
+				if f.Details.Name == "test-flag" && !f.Enabled {
+					t.Error("Expected test-flag enabled")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_RequireFlag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "new-checkout", "id": "1"}},
+				{"enabled": false, "details": {"name": "new-search", "id": "2"}},
+				{"enabled": true, "rollout": 50, "details": {"name": "beta", "id": "3"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithEvaluationKeyFromContext(func(ctx context.Context) string {
+		user, _ := ctx.Value(userKey{}).(string)
+		return user
+	}), WithBucketer(BucketerFunc(func(flagName, key string) int {
+		if key == "alice" {
+			return 10
+		}
+		return 90
+	})))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("next"))
+	})
+	disabled := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("disabled"))
+	})
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		user       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "enabled", handler: client.RequireFlag("new-checkout", nil)(next), wantStatus: http.StatusOK, wantBody: "next"},
+		{name: "disabled defaults to a 404", handler: client.RequireFlag("new-search", nil)(next), wantStatus: http.StatusNotFound},
+		{name: "disabled handler", handler: client.RequireFlag("new-search", disabled)(next), wantStatus: http.StatusServiceUnavailable, wantBody: "disabled"},
+		{name: "unknown flag", handler: client.RequireFlag("missing", nil)(next), wantStatus: http.StatusNotFound},
+		{name: "rolled out to the user", handler: client.RequireFlag("beta", nil)(next), user: "alice", wantStatus: http.StatusOK, wantBody: "next"},
+		{name: "not rolled out to the user", handler: client.RequireFlag("beta", nil)(next), user: "bob", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.user != "" {
+				req = req.WithContext(context.WithValue(req.Context(), userKey{}, tt.user))
+			}
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
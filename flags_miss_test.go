@@ -0,0 +1,71 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithRefetchOnMiss(t *testing.T) {
+	var created atomic.Bool
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		flags := `{"enabled": true, "details": {"name": "flag-a", "id": "1"}}`
+		if created.Load() {
+			flags += `, {"enabled": true, "details": {"name": "flag-new", "id": "2"}}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"intervalAllowed": 600, "flags": [%s]}`, flags)
+	}))
+	defer server.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	for _, refetchOnMiss := range []bool{true, false} {
+		t.Run(fmt.Sprintf("refetch on miss %t", refetchOnMiss), func(t *testing.T) {
+			created.Store(false)
+			fetches.Store(0)
+			opts := []Option{WithBaseURL(server.URL), auth, WithMemory()}
+			if refetchOnMiss {
+				opts = append(opts, WithRefetchOnMiss())
+			}
+			client := NewClient(opts...)
+			defer func() {
+				_ = client.Close()
+			}()
+
+			if !client.Is("flag-a").Enabled() {
+				t.Fatal("Expected flag-a to be enabled")
+			}
+			created.Store(true)
+
+			if got := client.Is("flag-new").Enabled(); got != refetchOnMiss {
+				t.Errorf("Expected flag-new enabled to be %t, got %t", refetchOnMiss, got)
+			}
+			want := int32(1)
+			if refetchOnMiss {
+				want = 2
+			}
+			if got := fetches.Load(); got != want {
+				t.Errorf("Expected %d fetches, got %d", want, got)
+			}
+
+			// a flag that doesn't exist only refetches the once
+			client.Is("flag-missing").Enabled()
+			client.Is("flag-missing").Enabled()
+			if refetchOnMiss {
+				want++
+			}
+			if got := fetches.Load(); got != want {
+				t.Errorf("Expected %d fetches after the unknown flag, got %d", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithOfflineFirst(t *testing.T) {
+	var down, enabled atomic.Bool
+	enabled.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		// a negative interval makes the cache stale as soon as it's written, so every read syncs
+		response := fmt.Sprintf(`{
+			"intervalAllowed": -1,
+			"flags": [{"enabled": %t, "details": {"name": "test-flag", "id": "1"}}]
+		}`, enabled.Load())
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithMaxRetries(1), WithoutCircuitBreaker(), WithQuiet(), WithOfflineFirst())
+	defer func() {
+		_ = client.Close()
+	}()
+
+	read := func() bool {
+		t.Helper()
+		start := time.Now()
+		got := client.Is("test-flag").Enabled()
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("Expected the read not to wait on the API, took %s", elapsed)
+		}
+		return got
+	}
+
+	// nothing is cached yet, the first read starts the sync
+	if read() {
+		t.Error("Expected nothing to be served before the first sync")
+	}
+	waitFor(t, "the first sync", read)
+
+	// reads are served from the cache while the API is unreachable
+	down.Store(true)
+	for i := 0; i < 5; i++ {
+		if !read() {
+			t.Error("Expected the cached value while offline")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// once it's back the cache syncs
+	enabled.Store(false)
+	down.Store(false)
+	waitFor(t, "the sync after reconnecting", func() bool {
+		return !read()
+	})
+}
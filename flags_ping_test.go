@@ -0,0 +1,98 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_Ping(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("X-Project-ID") != "test-project" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	tests := []struct {
+		name      string
+		url       string
+		projectID string
+		wantErr   string
+	}{
+		{
+			name:      "success",
+			url:       server.URL,
+			projectID: "test-project",
+		},
+		{
+			name:      "unauthorized",
+			url:       server.URL,
+			projectID: "wrong-project",
+			wantErr:   "unauthorized",
+		},
+		{
+			name:      "network failure",
+			url:       closed.URL,
+			projectID: "test-project",
+			wantErr:   "failed to execute request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client := NewClient(WithBaseURL(tt.url), WithAuth(Auth{
+				ProjectID:     tt.projectID,
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}), WithMemory(), WithMaxRetries(3))
+
+			err := client.Ping(context.Background())
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Expected no error, got %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+
+			if tt.url == server.URL && requests.Load() != 1 {
+				t.Errorf("Expected a single request without retries, got %d", requests.Load())
+			}
+			if !client.Cache.ShouldRefreshCache() {
+				t.Error("Expected ping to not write the cache")
+			}
+			if _, exists := client.Cache.Get("test-flag"); exists {
+				t.Error("Expected ping to not cache any flags")
+			}
+		})
+	}
+}
+
+func TestWithPingOnStart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithPingOnStart())
+	if client != nil {
+		t.Error("Expected NewClient to fail when the startup ping fails")
+	}
+}
@@ -0,0 +1,122 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFlag_EvaluateDetailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "plain-flag", "id": "1"}},
+				{"enabled": true, "rollout": 100, "details": {"name": "rollout-flag", "id": "2"}},
+				{"enabled": true, "activeFrom": "2020-01-01T00:00:00Z", "details": {"name": "scheduled-flag", "id": "3"}},
+				{"enabled": false, "details": {"name": "overridden-flag", "id": "4"}},
+				{"enabled": false, "details": {"name": "kill", "id": "5"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	client := NewClient(WithBaseURL(server.URL), auth, WithMemory())
+	client.SetOverride("overridden-flag", true)
+	killed := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithKillSwitch("kill"))
+	down := NewClient(WithBaseURL(failing.URL), auth, WithMemory(), WithMaxRetries(1), WithoutCircuitBreaker(), WithQuiet())
+	downOverridden := NewClient(WithBaseURL(failing.URL), auth, WithMemory(), WithMaxRetries(1), WithoutCircuitBreaker(), WithQuiet())
+	downOverridden.SetOverride("plain-flag", true)
+
+	tests := []struct {
+		name    string
+		flag    *Flag
+		enabled bool
+		reason  Reason
+	}{
+		{"cached", client.Is("plain-flag"), true, ReasonCached},
+		{"rollout", client.Is("rollout-flag"), true, ReasonTargetingMatch},
+		{"active window", client.Is("scheduled-flag"), true, ReasonTargetingMatch},
+		{"override", client.Is("overridden-flag"), true, ReasonLocalOverride},
+		{"unknown", client.Is("unknown-flag"), false, ReasonDefault},
+		{"kill switch", killed.Is("plain-flag"), false, ReasonStatic},
+		{"fetch failed", down.Is("plain-flag"), false, ReasonError},
+		{"override while down", downOverridden.Is("plain-flag"), true, ReasonLocalOverride},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled, reason, err := tt.flag.EvaluateDetailed()
+			if enabled != tt.enabled {
+				t.Errorf("Expected %v, got %v", tt.enabled, enabled)
+			}
+			if reason != tt.reason {
+				t.Errorf("Expected reason %s, got %s", tt.reason, reason)
+			}
+			if got := tt.flag.Reason(); got != string(tt.reason) {
+				t.Errorf("Expected Reason to give %s, got %s", tt.reason, got)
+			}
+			if tt.flag.Client.baseURL == failing.URL {
+				if !errors.Is(err, ErrUpstream) {
+					t.Errorf("Expected ErrUpstream, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFlag_EvaluateFor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "rollout": 50, "value": "green", "details": {"name": "rollout-flag", "id": "1"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithBucketer(BucketerFunc(func(_, key string) int {
+		if key == "in" {
+			return 0
+		}
+		return 99
+	})), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer client.Close()
+
+	tests := []struct {
+		key  string
+		want Evaluation
+	}{
+		{"in", Evaluation{Enabled: true, Value: "green", Reason: ReasonTargetingMatch}},
+		{"out", Evaluation{Reason: ReasonTargetingMatch}},
+		{"", Evaluation{Reason: ReasonTargetingMatch}},
+	}
+	for _, tt := range tests {
+		if got := client.Is("rollout-flag").EvaluateFor(tt.key); got != tt.want {
+			t.Errorf("%q: expected %+v, got %+v", tt.key, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithRefreshHandler(t *testing.T) {
+	responses := []string{
+		`{"intervalAllowed": 60, "flags": [
+			{"enabled": true, "details": {"name": "flag-a", "id": "1"}},
+			{"enabled": false, "details": {"name": "flag-b", "id": "2"}},
+			{"enabled": true, "tags": ["ui"], "details": {"name": "flag-c", "id": "3"}}
+		]}`,
+		// flag-b turned on, flag-c gone, flag-d added, flag-a the same
+		`{"intervalAllowed": 60, "flags": [
+			{"enabled": true, "details": {"name": "flag-a", "id": "1"}},
+			{"enabled": true, "details": {"name": "flag-b", "id": "2"}},
+			{"enabled": true, "details": {"name": "flag-d", "id": "4"}}
+		]}`,
+		// nothing changed
+		`{"intervalAllowed": 60, "flags": [
+			{"enabled": true, "details": {"name": "flag-a", "id": "1"}},
+			{"enabled": true, "details": {"name": "flag-b", "id": "2"}},
+			{"enabled": true, "details": {"name": "flag-d", "id": "4"}}
+		]}`,
+	}
+
+	var next atomic.Int32
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, responses[next.Add(1)-1])
+	}))
+	defer server.Close()
+
+	for _, memory := range []bool{true, false} {
+		t.Run(fmt.Sprintf("memory %t", memory), func(t *testing.T) {
+			next.Store(0)
+			failing.Store(false)
+			backend := WithMemory()
+			if !memory {
+				fileName := filepath.Join(t.TempDir(), "flags.db")
+				backend = SetFileName(&fileName)
+			}
+
+			var events []RefreshEvent
+			client := NewClient(WithBaseURL(server.URL), backend, WithMaxRetries(1), WithQuiet(), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}), WithRefreshHandler(func(event RefreshEvent) {
+				events = append(events, event)
+			}))
+			defer func() {
+				_ = client.Close()
+			}()
+
+			for range responses {
+				if err := client.refetch(); err != nil {
+					t.Fatalf("refetch: %v", err)
+				}
+			}
+			// the failure opens the circuit, so the next refetch is skipped
+			failing.Store(true)
+			_ = client.refetch()
+			_ = client.refetch()
+
+			want := []struct {
+				flags, changed int
+				err            error
+			}{
+				{flags: 3, changed: 3},
+				{flags: 3, changed: 3},
+				{flags: 3, changed: 0},
+				{err: ErrUpstream},
+				{err: ErrCircuitOpen},
+			}
+			if len(events) != len(want) {
+				t.Fatalf("Expected %d events, got %d: %+v", len(want), len(events), events)
+			}
+			for i, w := range want {
+				got := events[i]
+				if got.Source != "http" {
+					t.Errorf("event %d: expected the http source, got %q", i, got.Source)
+				}
+				if got.Flags != w.flags || got.Changed != w.changed {
+					t.Errorf("event %d: expected %d flags with %d changed, got %d with %d changed", i, w.flags, w.changed, got.Flags, got.Changed)
+				}
+				if (w.err == nil) != (got.Err == nil) || (w.err != nil && !errors.Is(got.Err, w.err)) {
+					t.Errorf("event %d: expected error %v, got %v", i, w.err, got.Err)
+				}
+				if got.Duration <= 0 {
+					t.Errorf("event %d: expected a duration, got %s", i, got.Duration)
+				}
+			}
+		})
+	}
+}
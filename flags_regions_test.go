@@ -0,0 +1,76 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithRegions(t *testing.T) {
+	var failing atomic.Bool
+	newServer := func(delay time.Duration, fails bool, hits *atomic.Int64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			if fails && failing.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			time.Sleep(delay)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+		}))
+	}
+	var slowHits, fastHits atomic.Int64
+	slow := newServer(50*time.Millisecond, false, &slowHits)
+	defer slow.Close()
+	fast := newServer(0, true, &fastHits)
+	defer fast.Close()
+
+	client := NewClient(WithMemory(), WithMaxRetries(1), WithoutCircuitBreaker(), WithQuiet(), WithRegions([]Region{
+		{Name: "slow", BaseURL: slow.URL},
+		{Name: "fast", BaseURL: fast.URL},
+	}), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	for i := 0; i < 6; i++ {
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch %d: %v", i, err)
+		}
+	}
+	// each is fetched from once to measure it, then the fast one is preferred
+	if slowHits.Load() != 1 || fastHits.Load() != 5 {
+		t.Errorf("Expected 1 fetch from the slow region and 5 from the fast one, got %d and %d", slowHits.Load(), fastHits.Load())
+	}
+
+	t.Run("skips a failing region", func(t *testing.T) {
+		failing.Store(true)
+		defer failing.Store(false)
+		slowHits.Store(0)
+		fastHits.Store(0)
+
+		if err := client.refetch(); err == nil {
+			t.Fatal("Expected the fetch from the failing region to fail")
+		}
+		if err := client.refetch(); err != nil {
+			t.Fatalf("Expected the slow region to be used while the fast one fails: %v", err)
+		}
+		if slowHits.Load() != 1 || fastHits.Load() != 1 {
+			t.Errorf("Expected 1 fetch from each region, got %d slow and %d fast", slowHits.Load(), fastHits.Load())
+		}
+	})
+
+	t.Run("every region failing", func(t *testing.T) {
+		set := &regionSet{regions: []regionState{{Region: Region{Name: "a"}}, {Region: Region{Name: "b"}}}}
+		now := time.Now()
+		set.record(0, 0, true, now)
+		set.record(1, 0, true, now.Add(-time.Second))
+		if got := set.pick(now); got != 1 {
+			t.Errorf("Expected the region that failed longest ago, got %d", got)
+		}
+	})
+}
@@ -0,0 +1,421 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testRetrier(maxAttempts int, circuit *CircuitState, errs *[]error) *retrier {
+	return &retrier{
+		maxAttempts: maxAttempts,
+		circuit:     circuit,
+		cooldown:    10 * time.Second,
+		backoff: func(int) time.Duration {
+			return time.Millisecond
+		},
+		now: time.Now,
+		onError: func(err error) {
+			*errs = append(*errs, err)
+		},
+		errorf: func(format string, args ...interface{}) error {
+			return fmt.Errorf(format, args...)
+		},
+	}
+}
+
+func failingFetch(failures int, calls *int) fetchFunc {
+	return func(ctx context.Context) (*ApiResponse, error) {
+		*calls++
+		if *calls <= failures {
+			return nil, fmt.Errorf("attempt %d failed", *calls)
+		}
+		return &ApiResponse{IntervalAllowed: 60}, nil
+	}
+}
+
+func TestRetrier_Do(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		failures    int
+		wantCalls   int
+		wantErrs    int
+		wantOpen    bool
+		wantErr     error
+	}{
+		{name: "first attempt succeeds", maxAttempts: 3, failures: 0, wantCalls: 1},
+		{name: "succeeds after retrying", maxAttempts: 3, failures: 2, wantCalls: 3, wantErrs: 2},
+		{name: "attempts run out", maxAttempts: 3, failures: 5, wantCalls: 3, wantErrs: 3, wantOpen: true, wantErr: ErrCircuitOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []error
+			circuit := &CircuitState{}
+			calls := 0
+
+			resp, err := testRetrier(tt.maxAttempts, circuit, &errs).do(context.Background(), failingFetch(tt.failures, &calls))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && (resp == nil || resp.IntervalAllowed != 60) {
+				t.Errorf("Expected the fetched response, got %+v", resp)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Expected %d calls, got %d", tt.wantCalls, calls)
+			}
+			if len(errs) != tt.wantErrs {
+				t.Errorf("Expected %d reported errors, got %d", tt.wantErrs, len(errs))
+			}
+			if circuit.isOpen != tt.wantOpen {
+				t.Errorf("Expected the circuit open to be %v", tt.wantOpen)
+			}
+			if !tt.wantOpen && circuit.failureCount != 0 {
+				t.Errorf("Expected a success to reset the failure count, got %d", circuit.failureCount)
+			}
+		})
+	}
+}
+
+func TestRetrier_OpenCircuit(t *testing.T) {
+	var errs []error
+	circuit := &CircuitState{isOpen: true, failureCount: 3, lastFailure: time.Now()}
+	r := testRetrier(3, circuit, &errs)
+	calls := 0
+
+	if _, err := r.do(context.Background(), failingFetch(0, &calls)); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no fetch while the circuit is open, got %d", calls)
+	}
+
+	circuit.lastFailure = time.Now().Add(-time.Minute)
+	if _, err := r.do(context.Background(), failingFetch(0, &calls)); err != nil {
+		t.Fatalf("Expected the circuit to close after the cooldown, got %v", err)
+	}
+	if calls != 1 || circuit.isOpen || circuit.failureCount != 0 {
+		t.Errorf("Expected one fetch and a closed circuit, got %d calls and %+v", calls, circuit)
+	}
+}
+
+func TestRetrier_MaxDuration(t *testing.T) {
+	var errs []error
+	r := testRetrier(5, &CircuitState{}, &errs)
+	r.maxDuration = 50 * time.Millisecond
+	r.backoff = func(int) time.Duration {
+		return time.Second
+	}
+	calls := 0
+
+	start := time.Now()
+	_, err := r.do(context.Background(), failingFetch(5, &calls))
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the retry time to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the backoff to stop at the max duration, took %s", elapsed)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single attempt, got %d", calls)
+	}
+}
+
+func TestRetrier_Cancelled(t *testing.T) {
+	var errs []error
+	r := testRetrier(5, &CircuitState{}, &errs)
+	r.backoff = func(int) time.Duration {
+		return time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	_, err := r.do(ctx, func(ctx context.Context) (*ApiResponse, error) {
+		calls++
+		cancel()
+		return nil, errors.New("failed")
+	})
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the refetch to be cancelled, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no retry once cancelled, got %d calls", calls)
+	}
+}
+
+func TestRetrier_OpenForGrows(t *testing.T) {
+	var errs []error
+	circuit := &CircuitState{}
+	r := testRetrier(1, circuit, &errs)
+	r.cooldown = time.Second
+	r.maxCooldown = 8 * time.Second
+
+	now := time.Unix(0, 0)
+	r.now = func() time.Time {
+		return now
+	}
+
+	failing := true
+	var attempts []time.Time
+	fetch := func(ctx context.Context) (*ApiResponse, error) {
+		attempts = append(attempts, now)
+		if failing {
+			return nil, errors.New("api down")
+		}
+		return &ApiResponse{}, nil
+	}
+
+	// evaluate every 100ms for a minute of outage
+	for i := 0; i < 600; i++ {
+		_, _ = r.do(context.Background(), fetch)
+		now = now.Add(100 * time.Millisecond)
+	}
+
+	var gaps []time.Duration
+	for i := 1; i < len(attempts); i++ {
+		gaps = append(gaps, attempts[i].Sub(attempts[i-1]))
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second}
+	if len(gaps) < len(want) {
+		t.Fatalf("Expected at least %d gaps, got %v", len(want), gaps)
+	}
+	for i, w := range want {
+		if gaps[i] != w {
+			t.Errorf("Expected gap %d to be %s, got %s (all %v)", i, w, gaps[i], gaps)
+		}
+	}
+	for _, gap := range gaps {
+		if gap > r.maxCooldown {
+			t.Errorf("Expected the gap to be capped at %s, got %s", r.maxCooldown, gap)
+		}
+	}
+
+	// once the api is back a single success resets the open time
+	failing = false
+	for circuit.isOpen {
+		_, _ = r.do(context.Background(), fetch)
+		now = now.Add(100 * time.Millisecond)
+	}
+	if circuit.trips != 0 {
+		t.Fatalf("Expected a success to reset the trips, got %d", circuit.trips)
+	}
+
+	failing = true
+	attempts = nil
+	for i := 0; i < 20; i++ {
+		_, _ = r.do(context.Background(), fetch)
+		now = now.Add(100 * time.Millisecond)
+	}
+	if len(attempts) != 2 || attempts[1].Sub(attempts[0]) != time.Second {
+		t.Errorf("Expected the next outage to start at the base open time, got %v", attempts)
+	}
+}
+
+func TestRetrier_NoCircuit(t *testing.T) {
+	var errs []error
+	circuit := &CircuitState{}
+	r := testRetrier(2, circuit, &errs)
+	r.noCircuit = true
+	calls := 0
+
+	for i := 0; i < 3; i++ {
+		_, err := r.do(context.Background(), failingFetch(100, &calls))
+		if err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: expected the last failure, got %v", i, err)
+		}
+	}
+	if calls != 6 {
+		t.Errorf("Expected every call to use all its attempts, got %d fetches", calls)
+	}
+	if circuit.isOpen {
+		t.Error("Expected the circuit to stay closed")
+	}
+}
+
+func TestWithoutCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+		want int32
+	}{
+		{name: "breaker suppresses fetches", want: 1},
+		{name: "without breaker every read fetches", opts: []Option{WithoutCircuitBreaker()}, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client := NewClient(append([]Option{WithBaseURL(server.URL), WithMemory(), WithMaxRetries(1), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			})}, tt.opts...)...)
+			defer func() {
+				_ = client.Close()
+			}()
+
+			for i := 0; i < 5; i++ {
+				client.Is("test-flag").Enabled()
+			}
+			if got := requests.Load(); got != tt.want {
+				t.Errorf("Expected %d fetches, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTripCircuit(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": -1, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "with breaker"},
+		{name: "without breaker", opts: []Option{WithoutCircuitBreaker()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client := NewClient(append([]Option{WithBaseURL(server.URL), WithMemory(), WithMaxRetries(1), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			})}, tt.opts...)...)
+			defer func() {
+				_ = client.Close()
+			}()
+
+			if client.CircuitOpen() {
+				t.Error("Expected a new client to have the circuit closed")
+			}
+
+			client.TripCircuit()
+			if !client.CircuitOpen() {
+				t.Error("Expected the circuit to be open after TripCircuit")
+			}
+			// the cache is always stale, so every refetch would fetch if the circuit let it
+			for i := 0; i < 3; i++ {
+				if err := client.refetch(); err != nil {
+					t.Errorf("refetch: %v", err)
+				}
+			}
+			if got := requests.Load(); got != 0 {
+				t.Errorf("Expected no fetches with the circuit tripped, got %d", got)
+			}
+
+			client.ResetCircuit()
+			if client.CircuitOpen() {
+				t.Error("Expected the circuit to be closed after ResetCircuit")
+			}
+			if err := client.refetch(); err != nil {
+				t.Errorf("refetch: %v", err)
+			}
+			if got := requests.Load(); got != 1 {
+				t.Errorf("Expected a fetch once the circuit was reset, got %d", got)
+			}
+		})
+	}
+}
+
+func TestResetCircuit_AfterFailures(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithMaxRetries(1), WithQuiet(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	_ = client.refetch()
+	if !client.CircuitOpen() {
+		t.Fatal("Expected the failure to open the circuit")
+	}
+	_ = client.refetch()
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected the open circuit to skip the refetch, got %d fetches", got)
+	}
+
+	client.ResetCircuit()
+	if client.CircuitOpen() {
+		t.Error("Expected ResetCircuit to close a circuit opened by failures")
+	}
+	_ = client.refetch()
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Expected a fetch straight after ResetCircuit, got %d fetches", got)
+	}
+}
+
+func TestWithDisableCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	var errs []error
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithMaxRetries(1), WithDisableCircuitBreaker(), WithQuiet(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	for i := 1; i <= 3; i++ {
+		errs = nil
+		if client.Is("test-flag").Enabled() {
+			t.Errorf("evaluation %d: expected the flag to be off", i)
+		}
+		if got := requests.Load(); got != int32(i) {
+			t.Errorf("evaluation %d: expected %d fetches, got %d", i, i, got)
+		}
+		if len(errs) == 0 {
+			t.Errorf("evaluation %d: expected the failure to be reported", i)
+		}
+		for _, err := range errs {
+			if !errors.Is(err, ErrUpstream) || errors.Is(err, ErrCircuitOpen) {
+				t.Errorf("evaluation %d: expected the real error, got %v", i, err)
+			}
+		}
+	}
+
+	if err := client.refetch(); !errors.Is(err, ErrUpstream) {
+		t.Errorf("Expected refetch to return the real error, got %v", err)
+	}
+	if client.CircuitOpen() {
+		t.Error("Expected the circuit to stay closed")
+	}
+}
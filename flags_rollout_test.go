@@ -0,0 +1,203 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+type userKey struct{}
+
+func TestEnabledCtx_Rollout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "rollout": 50, "details": {"name": "half-flag", "id": "1"}},
+				{"enabled": true, "details": {"name": "full-flag", "id": "2"}},
+				{"enabled": false, "rollout": 100, "details": {"name": "disabled-flag", "id": "3"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "flags.db")
+	backends := map[string]Option{
+		"memory": WithMemory(),
+		"sqlite": SetFileName(&filename),
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}), backend, WithEvaluationKeyFromContext(func(ctx context.Context) string {
+				key, _ := ctx.Value(userKey{}).(string)
+				return key
+			}))
+			defer func() {
+				_ = client.Close()
+			}()
+
+			ctx := context.WithValue(context.Background(), userKey{}, "user-1")
+			first := client.Is("half-flag").EnabledCtx(ctx)
+			for i := 0; i < 5; i++ {
+				if got := client.Is("half-flag").EnabledCtx(ctx); got != first {
+					t.Fatalf("Expected consistent bucketing for user-1, got %v then %v", first, got)
+				}
+			}
+			if got := client.Is("half-flag").EnabledFor("user-1"); got != first {
+				t.Errorf("Expected EnabledFor to match EnabledCtx for the same key, got %v and %v", got, first)
+			}
+
+			enabled := 0
+			for i := 0; i < 1000; i++ {
+				ctx := context.WithValue(context.Background(), userKey{}, fmt.Sprintf("user-%d", i))
+				if client.Is("half-flag").EnabledCtx(ctx) {
+					enabled++
+				}
+			}
+			if enabled < 400 || enabled > 600 {
+				t.Errorf("Expected roughly half of 1000 users to be enabled, got %d", enabled)
+			}
+
+			if !client.Is("full-flag").EnabledCtx(ctx) {
+				t.Error("Expected a flag without a rollout to be enabled for everyone")
+			}
+			if client.Is("disabled-flag").EnabledCtx(ctx) {
+				t.Error("Expected a disabled flag to stay disabled whatever the rollout")
+			}
+			if client.Is("half-flag").Enabled() {
+				t.Error("Expected a partial rollout to be off without an evaluation key")
+			}
+		})
+	}
+}
+
+func TestWithStickyRollouts(t *testing.T) {
+	var rollout atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := fmt.Sprintf(`{
+			"intervalAllowed": 60,
+			"flags": [{"enabled": true, "rollout": %d, "details": {"name": "sticky-flag", "id": "1"}}]
+		}`, rollout.Load())
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "flags.db")
+	backends := map[string]Option{
+		"memory": WithMemory(),
+		"sqlite": SetFileName(&filename),
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			rollout.Store(100)
+			auth := Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}
+			sticky := NewClient(WithBaseURL(server.URL), WithAuth(auth), backend, WithStickyRollouts())
+			plain := NewClient(WithBaseURL(server.URL), WithAuth(auth), WithMemory())
+			defer func() {
+				_ = sticky.Close()
+			}()
+
+			if !sticky.Is("sticky-flag").EnabledFor("user-1") || !plain.Is("sticky-flag").EnabledFor("user-1") {
+				t.Fatal("Expected user-1 to be enabled at 100%")
+			}
+
+			rollout.Store(0)
+			for _, client := range []*Client{sticky, plain} {
+				if err := client.refetch(); err != nil {
+					t.Fatalf("refetch: %v", err)
+				}
+			}
+
+			if !sticky.Is("sticky-flag").EnabledFor("user-1") {
+				t.Error("Expected user-1 to stay enabled after the rollout dropped")
+			}
+			if sticky.Is("sticky-flag").EnabledFor("user-2") {
+				t.Error("Expected a user never enabled to be off at 0%")
+			}
+			if plain.Is("sticky-flag").EnabledFor("user-1") {
+				t.Error("Expected user-1 to be off at 0% without sticky rollouts")
+			}
+		})
+	}
+}
+
+func TestSHA256Bucketer_KnownBuckets(t *testing.T) {
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{key: "user-1", want: 8},
+		{key: "user-2", want: 82},
+		{key: "user-3", want: 41},
+		{key: "alice", want: 50},
+		{key: "bob", want: 13},
+	}
+
+	for _, tt := range tests {
+		if got := (SHA256Bucketer{}).Bucket("half-flag", tt.key); got != tt.want {
+			t.Errorf("%s: got bucket %d, want %d", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestWithBucketer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [{"enabled": true, "rollout": 50, "details": {"name": "half-flag", "id": "1"}}]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	// a fixed bucketer, as if matching the assignments of another SDK
+	buckets := map[string]int{
+		"user-1": 90,
+		"user-2": 10,
+		"user-3": 49,
+		"user-4": 50,
+	}
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithBucketer(BucketerFunc(func(flagName, key string) int {
+		if flagName != "half-flag" {
+			t.Errorf("Expected the flag name half-flag, got %s", flagName)
+		}
+		return buckets[key]
+	})))
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "user-1", want: false},
+		{key: "user-2", want: true},
+		{key: "user-3", want: true},
+		{key: "user-4", want: false},
+	}
+	for _, tt := range tests {
+		if got := client.Is("half-flag").EnabledFor(tt.key); got != tt.want {
+			t.Errorf("%s in bucket %d: got %v, want %v", tt.key, buckets[tt.key], got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEnabled_ActiveWindow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "activeFrom": "2026-01-01T00:00:00Z", "activeUntil": "2026-02-01T00:00:00Z", "details": {"name": "window-flag", "id": "1"}},
+				{"enabled": true, "activeFrom": "2026-01-01T00:00:00Z", "details": {"name": "from-flag", "id": "2"}},
+				{"enabled": false, "activeFrom": "2026-01-01T00:00:00Z", "activeUntil": "2026-02-01T00:00:00Z", "details": {"name": "disabled-flag", "id": "3"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "flags.db")
+	backends := map[string]Option{
+		"memory": WithMemory(),
+		"sqlite": SetFileName(&filename),
+	}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		wantWindow bool
+		wantFrom   bool
+	}{
+		{
+			name:       "before window",
+			now:        time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC),
+			wantWindow: false,
+			wantFrom:   false,
+		},
+		{
+			name:       "start of window",
+			now:        time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantWindow: true,
+			wantFrom:   true,
+		},
+		{
+			name:       "in window",
+			now:        time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC),
+			wantWindow: true,
+			wantFrom:   true,
+		},
+		{
+			name:       "after window",
+			now:        time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+			wantWindow: false,
+			wantFrom:   true,
+		},
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			var now atomic.Int64
+			client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}), backend, WithEvalCacheSize(10), WithClock(func() time.Time {
+				return time.Unix(now.Load(), 0)
+			}))
+			defer func() {
+				_ = client.Close()
+			}()
+
+			for _, tt := range tests {
+				now.Store(tt.now.Unix())
+				if got := client.Is("window-flag").Enabled(); got != tt.wantWindow {
+					t.Errorf("%s: window-flag got %v, want %v", tt.name, got, tt.wantWindow)
+				}
+				if got := client.Is("from-flag").Enabled(); got != tt.wantFrom {
+					t.Errorf("%s: from-flag got %v, want %v", tt.name, got, tt.wantFrom)
+				}
+				if client.Is("disabled-flag").Enabled() {
+					t.Errorf("%s: expected disabled-flag to stay disabled", tt.name)
+				}
+			}
+
+			if client.evalCache.len() != 0 {
+				t.Errorf("Expected flags with a window to not be memoized, got %d entries", client.evalCache.len())
+			}
+		})
+	}
+}
+
+func TestEnabled_ActiveWindowLocalOverride(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [{"enabled": true, "activeUntil": "2026-01-01T00:00:00Z", "details": {"name": "expired-flag", "id": "1"}}]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithClock(func() time.Time {
+		return time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	}))
+
+	if client.Is("expired-flag").Enabled() {
+		t.Error("Expected expired-flag to be off after its window")
+	}
+
+	t.Setenv("FLAGS_EXPIRED_FLAG", "true")
+	if !client.Is("expired-flag").Enabled() {
+		t.Error("Expected a local override to ignore the window")
+	}
+}
+
+func TestFlag_EnabledAt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "activeFrom": "2026-01-01T00:00:00Z", "activeUntil": "2026-02-01T00:00:00Z", "details": {"name": "launch-flag", "id": "1"}},
+				{"enabled": false, "activeFrom": "2026-01-01T00:00:00Z", "activeUntil": "2026-02-01T00:00:00Z", "details": {"name": "disabled-flag", "id": "2"}},
+				{"enabled": true, "details": {"name": "always-flag", "id": "3"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	// the clock is before the window, EnabledAt mustn't use it
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithEvalCacheSize(10), WithClock(func() time.Time {
+		return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{name: "before window", at: time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC), want: false},
+		{name: "in window", at: time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), want: true},
+		{name: "after window", at: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), want: false},
+	}
+	for _, tt := range tests {
+		if got := client.Is("launch-flag").EnabledAt(tt.at); got != tt.want {
+			t.Errorf("%s: launch-flag got %v, want %v", tt.name, got, tt.want)
+		}
+		if client.Is("disabled-flag").EnabledAt(tt.at) {
+			t.Errorf("%s: expected disabled-flag to stay disabled", tt.name)
+		}
+		if !client.Is("always-flag").EnabledAt(tt.at) {
+			t.Errorf("%s: expected always-flag to be enabled without a window", tt.name)
+		}
+	}
+
+	if client.Is("launch-flag").Enabled() {
+		t.Error("Expected Enabled to still use the clock, which is before the window")
+	}
+}
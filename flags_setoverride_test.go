@@ -0,0 +1,130 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestClient_SetOverride(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "new-checkout", "id": "1"}},
+				{"enabled": false, "details": {"name": "new-search", "id": "2"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), auth, WithEvalCacheSize(10))
+	defer func() {
+		_ = client.Close()
+	}()
+	other := NewClient(WithBaseURL(server.URL), WithMemory(), auth)
+	defer func() {
+		_ = other.Close()
+	}()
+
+	// evaluated first so the eval cache has to be purged by the overrides
+	if !client.Is("new-checkout").Enabled() || client.Is("new-search").Enabled() {
+		t.Fatal("Expected the cached values before any override")
+	}
+
+	client.SetOverride("new-checkout", false)
+	client.SetOverride("New-Search", true)
+	client.SetOverride("not-in-the-api", true)
+	if client.Is("new-checkout").Enabled() {
+		t.Error("Expected the override to turn new-checkout off")
+	}
+	if !client.Is("new-search").Enabled() {
+		t.Error("Expected the override to turn new-search on")
+	}
+	if !client.Is("not-in-the-api").Enabled() {
+		t.Error("Expected an override for a flag that isn't cached to apply")
+	}
+	if !other.Is("new-checkout").Enabled() || other.Is("new-search").Enabled() {
+		t.Error("Expected the overrides to only apply to the client they were set on")
+	}
+
+	t.Setenv("FLAGS_NEW_SEARCH", "false")
+	if client.Is("new-search").Enabled() {
+		t.Error("Expected an env var to win over the override")
+	}
+
+	client.ClearOverrides()
+	if !client.Is("new-checkout").Enabled() {
+		t.Error("Expected new-checkout to be from the cache once the overrides are cleared")
+	}
+	if client.Is("not-in-the-api").Enabled() {
+		t.Error("Expected not-in-the-api to be unknown once the overrides are cleared")
+	}
+}
+
+func TestWithPersistentOverrides(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": false, "details": {"name": "new-search", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "flags.db")
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	client := NewClient(WithBaseURL(server.URL), auth, SetFileName(&filename), WithPersistentOverrides())
+	client.SetOverride("New-Search", true)
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	restarted := NewClient(WithBaseURL(server.URL), auth, SetFileName(&filename), WithPersistentOverrides())
+	if !restarted.Is("new-search").Enabled() {
+		t.Error("Expected the override to survive the restart and win over the cached value")
+	}
+	if enabled, _, _ := restarted.resolve("new-search", ""); !enabled {
+		t.Error("Expected the override to be resolved ahead of the cache")
+	}
+
+	restarted.ClearOverrides()
+	if restarted.Is("new-search").Enabled() {
+		t.Error("Expected the cached value once the overrides are cleared")
+	}
+	if err := restarted.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	cleared := NewClient(WithBaseURL(server.URL), auth, SetFileName(&filename), WithPersistentOverrides())
+	defer func() {
+		_ = cleared.Close()
+	}()
+	if cleared.Is("new-search").Enabled() {
+		t.Error("Expected ClearOverrides to remove the stored override")
+	}
+
+	without := NewClient(WithBaseURL(server.URL), auth, SetFileName(&filename))
+	without.SetOverride("new-search", true)
+	if err := without.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	again := NewClient(WithBaseURL(server.URL), auth, SetFileName(&filename), WithPersistentOverrides())
+	defer func() {
+		_ = again.Close()
+	}()
+	if again.Is("new-search").Enabled() {
+		t.Error("Expected an override set without WithPersistentOverrides not to be stored")
+	}
+}
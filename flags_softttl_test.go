@@ -0,0 +1,113 @@
+package flags
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithSoftTTL(t *testing.T) {
+	var fetches atomic.Int32
+	var interval atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		// a negative interval leaves the cache that many seconds past its TTL as soon as it's written
+		response := fmt.Sprintf(`{
+			"intervalAllowed": %d,
+			"flags": [
+				{"enabled": true, "details": {"name": "enabled-flag", "id": "1"}}
+			]
+		}`, interval.Load())
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	const reads = 1000
+	tests := []struct {
+		name     string
+		softTTL  time.Duration
+		interval int32
+		sqlite   bool
+		// past the window a refetch that leaves the cache stale serves nothing, the same as without a soft ttl
+		stale    bool
+		min, max int32
+	}{
+		{name: "fresh cache", softTTL: 100 * time.Second, interval: 60, min: 0, max: 0},
+		{name: "halfway through the window", softTTL: 100 * time.Second, interval: -50, min: 400, max: 600},
+		{name: "halfway through the window sqlite", softTTL: 100 * time.Second, interval: -50, sqlite: true, min: 400, max: 600},
+		{name: "start of the window", softTTL: time.Hour, interval: -1, min: 0, max: 10},
+		{name: "past the window", softTTL: 100 * time.Second, interval: -200, stale: true, min: reads, max: reads},
+		{name: "no soft ttl", interval: -1, stale: true, min: reads, max: reads},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interval.Store(tt.interval)
+
+			backend := WithMemory()
+			if tt.sqlite {
+				fileName := filepath.Join(t.TempDir(), "flags.db")
+				backend = SetFileName(&fileName)
+			}
+			client := NewClient(WithBaseURL(server.URL), backend, WithSoftTTL(tt.softTTL), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}))
+			defer func() {
+				_ = client.Close()
+			}()
+			client.sample = rand.New(rand.NewPCG(1, 2)).Float64
+
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+			fetches.Store(0)
+
+			for i := 0; i < reads; i++ {
+				if got := client.Is("enabled-flag").Enabled(); got == tt.stale {
+					t.Fatalf("read %d: got %v, want %v", i, got, !tt.stale)
+				}
+			}
+			if got := fetches.Load(); got < tt.min || got > tt.max {
+				t.Errorf("Expected between %d and %d refetches over %d reads, got %d", tt.min, tt.max, reads, got)
+			}
+		})
+	}
+}
+
+func TestWithSoftTTL_NeverRefreshed(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "enabled-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithSoftTTL(time.Hour), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+	client.sample = func() float64 {
+		return 0.99
+	}
+
+	// an empty cache isn't in the soft window, the first evaluation has to fetch
+	if !client.Is("enabled-flag").Enabled() {
+		t.Error("Expected the first evaluation to fetch the flags")
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("Expected 1 fetch, got %d", fetches.Load())
+	}
+}
@@ -1,10 +1,17 @@
 package flags
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/flags-gg/go-flags/cache"
+	"github.com/flags-gg/go-flags/flag"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -189,3 +196,277 @@ func TestConcurrentAccess_SQLite(t *testing.T) {
 		}
 	}
 }
+
+func TestReadOnly_SQLite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "flags.db")
+	seed := cache.NewSQLLite(&filename)
+	if err := seed.Init(); err != nil {
+		t.Fatalf("failed to init seed database: %v", err)
+	}
+	if err := seed.Refresh([]flag.FeatureFlag{
+		{Enabled: true, Details: flag.Details{Name: "baked-flag", ID: "1"}},
+		{Enabled: false, Details: flag.Details{Name: "baked-disabled-flag", ID: "2"}},
+	}, 60); err != nil {
+		t.Fatalf("failed to seed database: %v", err)
+	}
+	if err := seed.Close(); err != nil {
+		t.Fatalf("failed to close seed database: %v", err)
+	}
+	if err := os.Chmod(filename, 0444); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), SetFileName(&filename), WithReadOnly())
+	if client == nil {
+		t.Fatal("Expected client to open the read only database")
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if !client.Is("baked-flag").Enabled() {
+		t.Error("Expected baked-flag to be enabled")
+	}
+	if client.Is("baked-disabled-flag").Enabled() {
+		t.Error("Expected baked-disabled-flag to be disabled")
+	}
+	if err := client.refetch(); err != nil {
+		t.Errorf("Expected refetch to be a no-op, got %v", err)
+	}
+	if err := client.Cache.Refresh(nil, 60); err == nil {
+		t.Error("Expected refresh of a read only database to fail")
+	}
+
+	if requests != 0 {
+		t.Errorf("Expected no requests in read only mode, got %d", requests)
+	}
+	after, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("Expected the read only database to be untouched")
+	}
+}
+
+func TestCacheEncryption_SQLite(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [{"enabled": true, "details": {"name": "encrypted-flag", "id": "1"}}]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "flags.db")
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), SetFileName(&filename), WithCacheEncryption(bytes.Repeat([]byte("k"), 16)))
+	if client == nil {
+		t.Fatal("Expected client to be created")
+	}
+
+	if !client.Is("encrypted-flag").Enabled() {
+		t.Error("Expected encrypted-flag to be enabled")
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("encrypted-flag")) {
+		t.Error("Expected the flag name to not be stored in plaintext")
+	}
+}
+
+func TestHistory_SQLite(t *testing.T) {
+	var enabled atomic.Bool
+	server := newToggleServer(&enabled)
+	defer server.Close()
+
+	for name, opts := range map[string][]Option{
+		"plaintext": nil,
+		"encrypted": {WithCacheEncryption(bytes.Repeat([]byte("k"), 32))},
+	} {
+		t.Run(name, func(t *testing.T) {
+			enabled.Store(true)
+			filename := filepath.Join(t.TempDir(), "flags.db")
+			client := NewClient(append([]Option{WithBaseURL(server.URL), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}), SetFileName(&filename)}, opts...)...)
+			defer func() {
+				_ = client.Close()
+			}()
+
+			for _, value := range []bool{true, true, false, false, true, false} {
+				enabled.Store(value)
+				if err := client.refetch(); err != nil {
+					t.Fatalf("refetch: %v", err)
+				}
+			}
+
+			// a reset clears the flags, refetching the same value after it isn't a change
+			if err := client.Reset(); err != nil {
+				t.Fatalf("Reset: %v", err)
+			}
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+
+			history, err := client.History("Test-Flag", 0)
+			if err != nil {
+				t.Fatalf("History: %v", err)
+			}
+			want := []bool{false, true, false, true}
+			if len(history) != len(want) {
+				t.Fatalf("Expected %d changes, got %d: %+v", len(want), len(history), history)
+			}
+			for i, change := range history {
+				if change.Name != "test-flag" || change.Enabled != want[i] || change.ChangedAt.IsZero() {
+					t.Errorf("Change %d: got %+v, want enabled %v", i, change, want[i])
+				}
+			}
+
+			limited, err := client.History("test-flag", 1)
+			if err != nil {
+				t.Fatalf("History with limit: %v", err)
+			}
+			if len(limited) != 1 || limited[0].Enabled {
+				t.Errorf("Expected only the latest change, got %+v", limited)
+			}
+		})
+	}
+}
+
+func TestHistory_MemoryUnsupported(t *testing.T) {
+	client := NewClient(WithMemory())
+	if _, err := client.History("test-flag", 0); err == nil {
+		t.Error("Expected the memory cache to not keep history")
+	}
+}
+
+func TestDefaultFileName_PerEnvironment(t *testing.T) {
+	newClient := func(env string, opts ...Option) *Client {
+		client := NewClient(append([]Option{WithAuth(Auth{
+			ProjectID:     "test-project",
+			AgentID:       "test-agent",
+			EnvironmentID: env,
+		})}, opts...)...)
+		if client == nil {
+			t.Fatalf("NewClient(%s) returned nil", env)
+		}
+		t.Cleanup(func() {
+			_ = client.Close()
+			if client.Cache.FileName != nil {
+				_ = os.Remove(*client.Cache.FileName)
+			}
+		})
+		return client
+	}
+
+	staging := newClient("staging")
+	production := newClient("production")
+	stagingAgain := newClient("staging")
+
+	if staging.Cache.FileName == nil || production.Cache.FileName == nil {
+		t.Fatal("Expected a default file name to be set")
+	}
+	if *staging.Cache.FileName == *production.Cache.FileName {
+		t.Errorf("Expected different environments to use different files, both use %s", *staging.Cache.FileName)
+	}
+	if *staging.Cache.FileName != *stagingAgain.Cache.FileName {
+		t.Errorf("Expected the same environment to share a file, got %s and %s", *staging.Cache.FileName, *stagingAgain.Cache.FileName)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "explicit.db")
+	explicit := newClient("staging", SetFileName(&fileName))
+	if *explicit.Cache.FileName != fileName {
+		t.Errorf("Expected SetFileName to override the default, got %s", *explicit.Cache.FileName)
+	}
+}
+
+func TestWithQueryLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [
+			{"enabled": true, "details": {"name": "flag-a", "id": "1"}},
+			{"enabled": false, "details": {"name": "flag-b", "id": "2"}}
+		]}`)
+	}))
+	defer server.Close()
+
+	type logged struct {
+		query string
+		args  []interface{}
+		d     time.Duration
+		err   error
+	}
+	var mu sync.Mutex
+	var queries []logged
+
+	fileName := filepath.Join(t.TempDir(), "flags.db")
+	client := NewClient(WithBaseURL(server.URL), SetFileName(&fileName), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithQueryLogger(func(query string, args []interface{}, d time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, logged{query: query, args: args, d: d, err: err})
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	mu.Lock()
+	queries = nil
+	mu.Unlock()
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	inserted := make(map[interface{}]bool)
+	for _, q := range queries {
+		if !strings.HasPrefix(q.query, "INSERT INTO flags ") {
+			continue
+		}
+		if q.d <= 0 {
+			t.Errorf("Expected a duration for %q, got %s", q.query, q.d)
+		}
+		if q.err != nil {
+			t.Errorf("Expected %q to succeed, got %v", q.query, q.err)
+		}
+		if len(q.args) > 0 {
+			inserted[q.args[0]] = true
+		}
+	}
+	if !inserted["flag-a"] || !inserted["flag-b"] {
+		t.Errorf("Expected the inserts of flag-a and flag-b to be logged, got %v", queries)
+	}
+}
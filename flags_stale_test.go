@@ -0,0 +1,154 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithAllowStaleOnError(t *testing.T) {
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		// a negative interval makes the cache stale as soon as it's written
+		response := `{
+			"intervalAllowed": -1,
+			"flags": [
+				{"enabled": true, "details": {"name": "enabled-flag", "id": "1"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	t.Setenv("FLAGS_LOCAL_FLAG", "true")
+
+	tests := []struct {
+		name       string
+		allowStale bool
+		memory     bool
+		want       bool
+	}{
+		{name: "memory serves stale when allowed", allowStale: true, memory: true, want: true},
+		{name: "memory fails closed by default", allowStale: false, memory: true, want: false},
+		{name: "sqlite serves stale when allowed", allowStale: true, want: true},
+		{name: "sqlite fails closed by default", allowStale: false, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			down.Store(false)
+
+			opts := []Option{WithBaseURL(server.URL), WithMaxRetries(1), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			})}
+			if tt.memory {
+				opts = append(opts, WithMemory())
+			} else {
+				fileName := filepath.Join(t.TempDir(), "flags.db")
+				opts = append(opts, SetFileName(&fileName))
+			}
+			if tt.allowStale {
+				opts = append(opts, WithAllowStaleOnError())
+			}
+
+			var errs []error
+			client := NewClient(append(opts, WithErrorHandler(func(err error) {
+				errs = append(errs, err)
+			}))...)
+			defer func() {
+				_ = client.Close()
+			}()
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+
+			down.Store(true)
+			// the first evaluation's refetch fails, the second finds the circuit open, neither can refresh the cache
+			for i := 0; i < 2; i++ {
+				if got := client.Is("enabled-flag").Enabled(); got != tt.want {
+					t.Errorf("evaluation %d: got %v, want %v", i, got, tt.want)
+				}
+			}
+			if len(errs) == 0 {
+				t.Error("Expected the failed refetch to be reported")
+			}
+
+			// overrides aren't stale, they win either way
+			if !client.Is("local-flag").Enabled() {
+				t.Error("Expected the env override to be served")
+			}
+		})
+	}
+}
+
+func TestDecodeFailure_KeepsCache(t *testing.T) {
+	var body atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, body.Load().(string))
+	}))
+	defer server.Close()
+
+	good := `{"intervalAllowed": -1, "flags": [
+		{"enabled": true, "details": {"name": "enabled-flag", "id": "1"}},
+		{"enabled": false, "details": {"name": "disabled-flag", "id": "2"}}
+	]}`
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "truncated", body: good[:len(good)/2]},
+		{name: "not json", body: "<html>bad gateway</html>"},
+		{name: "wrong shape", body: `{"flags": {"enabled-flag": true}}`},
+		{name: "no flags", body: `{"error": "upstream unavailable"}`},
+		{name: "null flags", body: `{"intervalAllowed": 60, "flags": null}`},
+		{name: "empty object", body: `{}`},
+	}
+
+	for _, memory := range []bool{true, false} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s memory %t", tt.name, memory), func(t *testing.T) {
+				body.Store(good)
+				backend := WithMemory()
+				if !memory {
+					fileName := filepath.Join(t.TempDir(), "flags.db")
+					backend = SetFileName(&fileName)
+				}
+				client := NewClient(WithBaseURL(server.URL), backend, WithMaxRetries(1), WithoutCircuitBreaker(), WithAllowStaleOnError(), WithQuiet(), WithAuth(Auth{
+					ProjectID:     "test-project",
+					AgentID:       "test-agent",
+					EnvironmentID: "test-environment",
+				}))
+				defer func() {
+					_ = client.Close()
+				}()
+				if err := client.refetch(); err != nil {
+					t.Fatalf("refetch: %v", err)
+				}
+
+				body.Store(tt.body)
+				if err := client.refetch(); !errors.Is(err, ErrDecode) {
+					t.Errorf("Expected a decode error, got %v", err)
+				}
+
+				if count, err := client.Count(); err != nil || count != 2 {
+					t.Errorf("Expected the 2 cached flags to be kept, got %d (%v)", count, err)
+				}
+				if !client.Is("enabled-flag").Enabled() || client.Is("disabled-flag").Enabled() {
+					t.Error("Expected the cached flags to still be served")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithStaticFlag(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": false, "details": {"name": "static-on", "id": "1"}},
+				{"enabled": true, "details": {"name": "static-off", "id": "2"}},
+				{"enabled": true, "details": {"name": "dynamic", "id": "3"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	t.Setenv("FLAGS_STATIC_OFF", "true")
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithStaticFlag("Static-On", true), WithStaticFlag("static-off", false), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if !client.Is("static-on").Enabled() {
+		t.Error("Expected static-on to be on")
+	}
+	if client.Is("static-off").Enabled() {
+		t.Error("Expected static-off to be off")
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("Expected static flags to be evaluated without a fetch, got %d requests", got)
+	}
+
+	// the cache and every override disagree with the static values
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+	client.SetOverride("static-on", false)
+	if !client.Is("static-on").Enabled() {
+		t.Error("Expected static-on to ignore the cache and SetOverride")
+	}
+	if client.Is("static-off").Enabled() {
+		t.Error("Expected static-off to ignore the cache and the env var")
+	}
+	if !client.Is("dynamic").Enabled() {
+		t.Error("Expected a flag that isn't static to come from the cache")
+	}
+
+	if status, err := client.Status("static-off"); err != nil || status != StatusDisabled {
+		t.Errorf("Expected static-off to be disabled, got %v (%v)", status, err)
+	}
+	if reason := client.Is("static-on").Reason(); reason != string(ReasonStatic) {
+		t.Errorf("Expected reason %s, got %s", ReasonStatic, reason)
+	}
+}
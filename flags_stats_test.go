@@ -0,0 +1,132 @@
+package flags
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const statsPayload = `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`
+
+func TestStats_PayloadSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(statsPayload))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory())
+
+	if !client.Is("test-flag").Enabled() {
+		t.Fatal("Expected test-flag to be enabled")
+	}
+
+	stats := client.Stats()
+	if stats.Fetches != 1 {
+		t.Errorf("Expected 1 fetch, got %d", stats.Fetches)
+	}
+	if stats.LastPayloadBytes != int64(len(statsPayload)) {
+		t.Errorf("Expected payload of %d bytes, got %d", len(statsPayload), stats.LastPayloadBytes)
+	}
+	if stats.LastDecompressedBytes != int64(len(statsPayload)) {
+		t.Errorf("Expected decompressed payload of %d bytes, got %d", len(statsPayload), stats.LastDecompressedBytes)
+	}
+	if stats.TotalPayloadBytes != int64(len(statsPayload)) {
+		t.Errorf("Expected total of %d bytes, got %d", len(statsPayload), stats.TotalPayloadBytes)
+	}
+}
+
+func TestStats_GzipPayloadSize(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write([]byte(statsPayload)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Expected gzip to be accepted, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory())
+
+	if !client.Is("test-flag").Enabled() {
+		t.Fatal("Expected test-flag to be enabled from a gzipped payload")
+	}
+
+	stats := client.Stats()
+	if stats.LastPayloadBytes != int64(compressed.Len()) {
+		t.Errorf("Expected payload of %d bytes, got %d", compressed.Len(), stats.LastPayloadBytes)
+	}
+	if stats.LastDecompressedBytes != int64(len(statsPayload)) {
+		t.Errorf("Expected decompressed payload of %d bytes, got %d", len(statsPayload), stats.LastDecompressedBytes)
+	}
+}
+
+func TestWithForceHTTP2(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(statsPayload))
+	})
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	t.Run("negotiates h2 and keeps the connection alive", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(handler)
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		defer server.Close()
+
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithForceHTTP2())
+		client.roundTripper().TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+		for i := 0; i < 2; i++ {
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch %d: %v", i, err)
+			}
+		}
+
+		stats := client.Stats()
+		if stats.LastProtocol != "HTTP/2.0" {
+			t.Errorf("Expected h2 to be negotiated, got %s", stats.LastProtocol)
+		}
+		if !stats.LastConnectionReused {
+			t.Error("Expected the second refetch to reuse the connection")
+		}
+	})
+
+	t.Run("fails over HTTP/1.1", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithMaxRetries(1), WithoutCircuitBreaker(), WithQuiet(), WithForceHTTP2())
+		if err := client.refetch(); !errors.Is(err, ErrUpstream) {
+			t.Errorf("Expected ErrUpstream, got %v", err)
+		}
+		if got := client.Stats().LastProtocol; got != "HTTP/1.1" {
+			t.Errorf("Expected HTTP/1.1 to be reported, got %s", got)
+		}
+	})
+}
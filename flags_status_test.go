@@ -0,0 +1,137 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClient_Status(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "enabled-flag", "id": "1"}},
+				{"enabled": false, "details": {"name": "disabled-flag", "id": "2"}},
+				{"enabled": false, "details": {"name": "overridden-flag", "id": "3"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	t.Setenv("FLAGS_OVERRIDDEN_FLAG", "true")
+	t.Setenv("FLAGS_LOCAL_ONLY_FLAG", "false")
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory())
+
+	tests := []struct {
+		name     string
+		flagName string
+		want     FlagStatus
+	}{
+		{
+			name:     "enabled flag",
+			flagName: "enabled-flag",
+			want:     StatusEnabled,
+		},
+		{
+			name:     "disabled flag",
+			flagName: "disabled-flag",
+			want:     StatusDisabled,
+		},
+		{
+			name:     "unknown flag",
+			flagName: "non-existent",
+			want:     StatusUnknown,
+		},
+		{
+			name:     "locally overridden flag",
+			flagName: "overridden-flag",
+			want:     StatusEnabled,
+		},
+		{
+			name:     "local only flag",
+			flagName: "local-only-flag",
+			want:     StatusDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.Status(tt.flagName)
+			if err != nil {
+				t.Fatalf("Status: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Flag %s: got %s, want %s", tt.flagName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_StatusStale(t *testing.T) {
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		// a negative interval makes the cache stale as soon as it's written
+		response := `{
+			"intervalAllowed": -1,
+			"flags": [{"enabled": true, "details": {"name": "enabled-flag", "id": "1"}}]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	t.Setenv("FLAGS_LOCAL_FLAG", "true")
+
+	for _, allowStale := range []bool{true, false} {
+		t.Run(fmt.Sprintf("allow stale %t", allowStale), func(t *testing.T) {
+			down.Store(false)
+			opts := []Option{WithBaseURL(server.URL), WithMaxRetries(1), WithMemory(), WithQuiet(), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			})}
+			if allowStale {
+				opts = append(opts, WithAllowStaleOnError())
+			}
+			client := NewClient(opts...)
+			defer func() {
+				_ = client.Close()
+			}()
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+
+			// the failed refetch opens the circuit, so the cache stays stale
+			down.Store(true)
+			_ = client.refetch()
+
+			want := StatusUnknown
+			if allowStale {
+				want = StatusEnabled
+			}
+			if got, err := client.Status("enabled-flag"); err != nil || got != want {
+				t.Errorf("Expected %s from a stale cache, got %s (%v)", want, got, err)
+			}
+			if got := client.Is("enabled-flag").Enabled(); got != (want == StatusEnabled) {
+				t.Errorf("Expected the evaluation to agree with the status, got %v", got)
+			}
+			if got, err := client.Status("local-flag"); err != nil || got != StatusEnabled {
+				t.Errorf("Expected the env override to be enabled, got %s (%v)", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package flags
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDefaultCacheDir(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the user cache dir is only from XDG_CACHE_HOME and HOME on linux")
+	}
+
+	tests := []struct {
+		name string
+		// setup gives the XDG_CACHE_HOME, HOME, and TMPDIR to resolve with, and the dir expected
+		setup func(t *testing.T) (xdg, home, tmp, want string)
+	}{
+		{
+			name: "XDG_CACHE_HOME",
+			setup: func(t *testing.T) (string, string, string, string) {
+				xdg := t.TempDir()
+				return xdg, t.TempDir(), t.TempDir(), filepath.Join(xdg, cacheDirName)
+			},
+		},
+		{
+			name: "HOME without XDG_CACHE_HOME",
+			setup: func(t *testing.T) (string, string, string, string) {
+				home := t.TempDir()
+				return "", home, t.TempDir(), filepath.Join(home, ".cache", cacheDirName)
+			},
+		},
+		{
+			name: "temp dir without a user cache dir",
+			setup: func(t *testing.T) (string, string, string, string) {
+				tmp := t.TempDir()
+				return "", "", tmp, filepath.Join(tmp, cacheDirName)
+			},
+		},
+		{
+			name: "temp dir when the user cache dir can't be created",
+			setup: func(t *testing.T) (string, string, string, string) {
+				file := filepath.Join(t.TempDir(), "not-a-dir")
+				if err := os.WriteFile(file, nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+				tmp := t.TempDir()
+				return file, "", tmp, filepath.Join(tmp, cacheDirName)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xdg, home, tmp, want := tt.setup(t)
+			t.Setenv("XDG_CACHE_HOME", xdg)
+			t.Setenv("HOME", home)
+			t.Setenv("TMPDIR", tmp)
+
+			dir, err := defaultCacheDir()
+			if err != nil {
+				t.Fatalf("defaultCacheDir: %v", err)
+			}
+			if dir != want {
+				t.Errorf("Expected %s, got %s", want, dir)
+			}
+			info, err := os.Stat(dir)
+			if err != nil {
+				t.Fatalf("Expected the dir to be created: %v", err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o700 {
+				t.Errorf("Expected the dir to only be accessible by the user, got %o", perm)
+			}
+		})
+	}
+}
+
+func TestWithPathResolver(t *testing.T) {
+	auth := Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}
+
+	t.Run("keeps the cache in the resolved dir", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "nested", "cache")
+		client := NewClient(WithAuth(auth), WithPathResolver(func() (string, error) {
+			return dir, nil
+		}))
+		if client == nil {
+			t.Fatal("Expected a client")
+		}
+		defer func() {
+			_ = client.Close()
+		}()
+
+		want := filepath.Join(dir, "flags-"+auth.hash()+".db")
+		if got := *client.Cache.FileName; got != want {
+			t.Errorf("Expected the cache at %s, got %s", want, got)
+		}
+		if _, err := os.Stat(want); err != nil {
+			t.Errorf("Expected the cache to be created: %v", err)
+		}
+	})
+
+	t.Run("SetFileName wins", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "flags.db")
+		client := NewClient(WithAuth(auth), SetFileName(&fileName), WithPathResolver(func() (string, error) {
+			t.Error("Expected the resolver not to be called")
+			return "", nil
+		}))
+		if client == nil {
+			t.Fatal("Expected a client")
+		}
+		_ = client.Close()
+	})
+
+	t.Run("an error fails the client", func(t *testing.T) {
+		var errs []error
+		client := NewClient(WithAuth(auth), WithLogLevel(LogLevelNone), WithPathResolver(func() (string, error) {
+			return "", errors.New("no disk")
+		}), WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}))
+		if client != nil {
+			t.Fatal("Expected a resolver error to fail the client")
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], ErrCacheUnavailable) {
+			t.Errorf("Expected ErrCacheUnavailable, got %v", errs)
+		}
+	})
+}
+
+func TestWithCacheDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows doesn't have unix permissions")
+	}
+
+	dir := filepath.Join(t.TempDir(), "nested", "cache")
+	auth := Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}
+	client := NewClient(WithCacheDir(dir), WithAuth(auth))
+	if client == nil {
+		t.Fatal("Expected a client")
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("Expected the cache dir to be created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Errorf("Expected the cache dir to be 0700, got %o", perm)
+	}
+
+	fileName := filepath.Join(dir, "flags-"+auth.hash()+".db")
+	info, err = os.Stat(fileName)
+	if err != nil {
+		t.Fatalf("Expected the cache file to be named for the auth: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("Expected the cache file to be 0600, got %o", perm)
+	}
+
+	// a file left with looser permissions is restricted too
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(fileName, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reopened := NewClient(WithCacheDir(dir), WithAuth(auth))
+	defer func() {
+		_ = reopened.Close()
+	}()
+	info, err = os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("Expected the existing cache file to be restricted to 0600, got %o", perm)
+	}
+}
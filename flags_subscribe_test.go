@@ -0,0 +1,155 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// describeChanges gives the changes as sorted "name old->new" strings, "-" standing in for a missing flag
+func describeChanges(changes []FlagChange) []string {
+	var got []string
+	for _, c := range changes {
+		before, after := "-", "-"
+		if c.Old != nil {
+			before = fmt.Sprint(c.Old.Enabled)
+		}
+		if c.New != nil {
+			after = fmt.Sprint(c.New.Enabled)
+		}
+		got = append(got, fmt.Sprintf("%s %s->%s", c.Name, before, after))
+	}
+	slices.Sort(got)
+	return got
+}
+
+func drain(ch <-chan FlagChange) []FlagChange {
+	var changes []FlagChange
+	for {
+		select {
+		case c, ok := <-ch:
+			if !ok {
+				return changes
+			}
+			changes = append(changes, c)
+		default:
+			return changes
+		}
+	}
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	responses := []string{
+		`{"intervalAllowed": 60, "flags": [
+			{"enabled": true, "details": {"name": "flag-a", "id": "1"}},
+			{"enabled": false, "details": {"name": "flag-b", "id": "2"}},
+			{"enabled": true, "details": {"name": "flag-c", "id": "3"}}
+		]}`,
+		// flag-b turned on, flag-c gone, flag-d added, flag-a the same
+		`{"intervalAllowed": 60, "flags": [
+			{"enabled": true, "details": {"name": "flag-a", "id": "1"}},
+			{"enabled": true, "details": {"name": "flag-b", "id": "2"}},
+			{"enabled": false, "details": {"name": "flag-d", "id": "4"}}
+		]}`,
+		// nothing changed
+		`{"intervalAllowed": 60, "flags": [
+			{"enabled": true, "details": {"name": "flag-a", "id": "1"}},
+			{"enabled": true, "details": {"name": "flag-b", "id": "2"}},
+			{"enabled": false, "details": {"name": "flag-d", "id": "4"}}
+		]}`,
+	}
+
+	var next atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, responses[next.Add(1)-1])
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithMaxRetries(1), WithQuiet(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	first, cancelFirst := client.Subscribe()
+	second, cancelSecond := client.Subscribe()
+	defer cancelSecond()
+
+	want := [][]string{
+		{"flag-a -->true", "flag-b -->false", "flag-c -->true"},
+		{"flag-b false->true", "flag-c true->-", "flag-d -->false"},
+		nil,
+	}
+	for i, w := range want {
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch %d: %v", i, err)
+		}
+		gotFirst, gotSecond := describeChanges(drain(first)), describeChanges(drain(second))
+		if !slices.Equal(gotFirst, w) {
+			t.Errorf("refetch %d: expected the first subscriber to get %v, got %v", i, w, gotFirst)
+		}
+		if !slices.Equal(gotSecond, w) {
+			t.Errorf("refetch %d: expected the second subscriber to get %v, got %v", i, w, gotSecond)
+		}
+	}
+
+	cancelFirst()
+	if _, ok := <-first; ok {
+		t.Error("Expected the cancelled subscriber's channel to be closed")
+	}
+	cancelFirst()
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, ok := <-second; ok {
+		t.Error("Expected Close to close the subscriber's channel")
+	}
+	closed, _ := client.Subscribe()
+	if _, ok := <-closed; ok {
+		t.Error("Expected subscribing to a closed client to give a closed channel")
+	}
+}
+
+func TestClient_SubscribeSlowConsumer(t *testing.T) {
+	var flags []string
+	for i := range subscriberBuffer + 10 {
+		flags = append(flags, fmt.Sprintf(`{"enabled": true, "details": {"name": "flag-%d", "id": "%d"}}`, i, i))
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"intervalAllowed": 60, "flags": [%s]}`, strings.Join(flags, ","))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithMaxRetries(1), WithQuiet(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	slow, cancel := client.Subscribe()
+	defer cancel()
+
+	// the refresh mustn't wait on a subscriber that isn't reading
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+	if got := len(drain(slow)); got != subscriberBuffer {
+		t.Errorf("Expected the slow subscriber to get %d changes, got %d", subscriberBuffer, got)
+	}
+	if got := client.Stats().DroppedChanges; got != 10 {
+		t.Errorf("Expected 10 dropped changes, got %d", got)
+	}
+}
@@ -0,0 +1,172 @@
+package flags
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/flags-gg/go-flags/flag"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestListByTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "tags": ["team:payments", "type:killswitch"], "details": {"name": "payments-killswitch", "id": "1"}},
+				{"enabled": false, "tags": ["team:payments"], "details": {"name": "new-checkout", "id": "2"}},
+				{"enabled": true, "tags": ["team:search"], "details": {"name": "fuzzy-search", "id": "3"}},
+				{"enabled": true, "details": {"name": "untagged-flag", "id": "4"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	plainFile := filepath.Join(dir, "plain.db")
+	encryptedFile := filepath.Join(dir, "encrypted.db")
+	backends := map[string][]Option{
+		"memory":    {WithMemory()},
+		"sqlite":    {SetFileName(&plainFile)},
+		"encrypted": {SetFileName(&encryptedFile), WithCacheEncryption(bytes.Repeat([]byte("k"), 32))},
+	}
+
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{tag: "team:payments", want: []string{"new-checkout", "payments-killswitch"}},
+		{tag: "type:killswitch", want: []string{"payments-killswitch"}},
+		{tag: "team:search", want: []string{"fuzzy-search"}},
+		{tag: "team:unknown", want: nil},
+	}
+
+	for name, opts := range backends {
+		t.Run(name, func(t *testing.T) {
+			client := NewClient(append([]Option{WithBaseURL(server.URL), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			})}, opts...)...)
+			defer func() {
+				_ = client.Close()
+			}()
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+
+			for _, tt := range tests {
+				flags, err := client.ListByTag(tt.tag)
+				if err != nil {
+					t.Fatalf("ListByTag(%s): %v", tt.tag, err)
+				}
+
+				var got []string
+				for _, f := range flags {
+					got = append(got, f.Details.Name)
+				}
+				sort.Strings(got)
+				if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+					t.Errorf("%s: got %v, want %v", tt.tag, got, tt.want)
+				}
+			}
+
+			all, err := client.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range all {
+				if f.Details.Name == "payments-killswitch" && len(f.Tags) != 2 {
+					t.Errorf("Expected both tags to round trip, got %v", f.Tags)
+				}
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "tags": ["team:payments"], "details": {"name": "payments-killswitch", "id": "1"}},
+				{"enabled": false, "tags": ["team:payments"], "details": {"name": "new-checkout", "id": "2"}},
+				{"enabled": true, "tags": ["team:search"], "details": {"name": "fuzzy-search", "id": "3"}},
+				{"enabled": false, "details": {"name": "untagged-flag", "id": "4"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		pred func(flag.FeatureFlag) bool
+		want []string
+	}{
+		{
+			name: "owned by payments",
+			pred: func(f flag.FeatureFlag) bool {
+				return f.HasTag("team:payments")
+			},
+			want: []string{"new-checkout", "payments-killswitch"},
+		},
+		{
+			name: "enabled",
+			pred: func(f flag.FeatureFlag) bool {
+				return f.Enabled
+			},
+			want: []string{"fuzzy-search", "payments-killswitch"},
+		},
+		{
+			name: "enabled and owned by payments",
+			pred: func(f flag.FeatureFlag) bool {
+				return f.Enabled && f.HasTag("team:payments")
+			},
+			want: []string{"payments-killswitch"},
+		},
+		{
+			name: "nothing matches",
+			pred: func(f flag.FeatureFlag) bool {
+				return false
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := client.Find(tt.pred)
+			if err != nil {
+				t.Fatalf("Find: %v", err)
+			}
+
+			var got []string
+			for _, f := range flags {
+				got = append(got, f.Details.Name)
+			}
+			sort.Strings(got)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,19 @@
 package flags
 
 import (
+	"context"
 	"fmt"
+	"github.com/flags-gg/go-flags/cache"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -93,3 +103,1153 @@ func TestErrorHandling(t *testing.T) {
 		})
 	}
 }
+
+func TestWithRegion(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantURL string
+		wantNil bool
+	}{
+		{
+			name:    "us region",
+			opts:    []Option{WithRegion("us")},
+			wantURL: "https://us.api.flags.gg",
+		},
+		{
+			name:    "eu region",
+			opts:    []Option{WithRegion("eu")},
+			wantURL: "https://eu.api.flags.gg",
+		},
+		{
+			name:    "ap region",
+			opts:    []Option{WithRegion("AP")},
+			wantURL: "https://ap.api.flags.gg",
+		},
+		{
+			name:    "explicit base url wins",
+			opts:    []Option{WithBaseURL("https://custom.flags.gg"), WithRegion("eu")},
+			wantURL: "https://custom.flags.gg",
+		},
+		{
+			name:    "explicit base url wins regardless of order",
+			opts:    []Option{WithRegion("eu"), WithBaseURL("https://custom.flags.gg")},
+			wantURL: "https://custom.flags.gg",
+		},
+		{
+			name:    "unknown region",
+			opts:    []Option{WithRegion("moon")},
+			wantNil: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(append(tt.opts, WithMemory())...)
+			if tt.wantNil {
+				if client != nil {
+					t.Error("Expected nil client for unknown region")
+				}
+				return
+			}
+			if client == nil {
+				t.Fatal("Expected client to be created")
+			}
+			if client.baseURL != tt.wantURL {
+				t.Errorf("Expected baseURL to be %s, got %s", tt.wantURL, client.baseURL)
+			}
+		})
+	}
+}
+
+func TestClient_WithAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := fmt.Sprintf(`{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "%s-flag", "id": "1"}}
+			]
+		}`, r.Header.Get("X-Project-ID"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "flags.db")
+	backends := map[string]Option{
+		"memory": WithMemory(),
+		"sqlite": SetFileName(&filename),
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+				ProjectID:     "project-a",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}), backend)
+			defer func() {
+				_ = client.Close()
+			}()
+
+			if !client.Is("project-a-flag").Enabled() {
+				t.Error("Expected project-a-flag to be enabled for project a")
+			}
+
+			other := client.WithAuth(Auth{
+				ProjectID:     "project-b",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			})
+			if other == nil {
+				t.Fatal("Expected client copy to be created")
+			}
+			if other.httpClient != client.httpClient {
+				t.Error("Expected client copy to share the http client")
+			}
+
+			if !other.Is("project-b-flag").Enabled() {
+				t.Error("Expected project-b-flag to be enabled for project b")
+			}
+			if other.Is("project-a-flag").Enabled() {
+				t.Error("Expected project-a-flag to be unknown for project b")
+			}
+
+			if !client.Is("project-a-flag").Enabled() {
+				t.Error("Expected project a cache to be untouched by project b")
+			}
+			if client.Is("project-b-flag").Enabled() {
+				t.Error("Expected project-b-flag to be unknown for project a")
+			}
+		})
+	}
+}
+
+func TestWithKillSwitch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "master-switch", "id": "1"}},
+				{"enabled": true, "details": {"name": "enabled-flag", "id": "2"}},
+				{"enabled": false, "details": {"name": "disabled-flag", "id": "3"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithKillSwitch("Master-Switch"))
+
+	if !client.Is("enabled-flag").Enabled() {
+		t.Error("Expected enabled-flag to be enabled while the kill switch is on")
+	}
+
+	t.Setenv("FLAGS_MASTER_SWITCH", "false")
+	if client.Is("master-switch").Enabled() {
+		t.Error("Expected the kill switch to be off")
+	}
+	if client.Is("enabled-flag").Enabled() {
+		t.Error("Expected enabled-flag to be disabled while the kill switch is off")
+	}
+	if client.Is("disabled-flag").Enabled() {
+		t.Error("Expected disabled-flag to be disabled while the kill switch is off")
+	}
+
+	t.Setenv("FLAGS_MASTER_SWITCH", "true")
+	if !client.Is("enabled-flag").Enabled() {
+		t.Error("Expected enabled-flag to be enabled once the kill switch is back on")
+	}
+	if client.Is("disabled-flag").Enabled() {
+		t.Error("Expected disabled-flag to stay disabled once the kill switch is back on")
+	}
+}
+
+func TestWithFileOverrideDir(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "file-flag", "id": "1"}},
+				{"enabled": false, "details": {"name": "env-flag", "id": "2"}},
+				{"enabled": true, "details": {"name": "server-flag", "id": "3"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	// replace the file rather than editing it, like a ConfigMap update
+	writeOverride := func(name, value string) {
+		tmp := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(tmp, []byte(value+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeOverride("file_flag", "false")
+	writeOverride("env_flag", "false")
+	writeOverride(".hidden_flag", "true")
+
+	t.Setenv("FLAGS_ENV_FLAG", "true")
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithFileOverrideDir(dir))
+
+	tests := []struct {
+		name     string
+		flagName string
+		want     bool
+	}{
+		{
+			name:     "file overrides the server",
+			flagName: "file-flag",
+			want:     false,
+		},
+		{
+			name:     "file wins over env var",
+			flagName: "env-flag",
+			want:     false,
+		},
+		{
+			name:     "server flag without override",
+			flagName: "server-flag",
+			want:     true,
+		},
+		{
+			name:     "hidden files are ignored",
+			flagName: ".hidden_flag",
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := client.Is(tt.flagName).Enabled()
+			if got != tt.want {
+				t.Errorf("Flag %s: got %v, want %v", tt.flagName, got, tt.want)
+			}
+		})
+	}
+
+	writeOverride("file_flag", "true")
+	if !client.Is("file-flag").Enabled() {
+		t.Error("Expected a changed override file to be picked up")
+	}
+}
+
+func TestOverrides_DirChanged(t *testing.T) {
+	dir := t.TempDir()
+	o := &overrides{}
+	if !o.dirChanged(dir) {
+		t.Fatal("Expected the dir to be read the first time")
+	}
+	if !o.dirChanged(dir) {
+		t.Error("Expected the dir to be read again so soon after it changed")
+	}
+
+	// long enough after the change that the mtime can be trusted
+	o.readAt = o.dirModTime.Add(2 * racyWindow)
+	if o.dirChanged(dir) {
+		t.Error("Expected an unchanged dir not to be read again")
+	}
+
+	changed := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(dir, changed, changed); err != nil {
+		t.Fatal(err)
+	}
+	if !o.dirChanged(dir) {
+		t.Error("Expected a changed mtime to read the dir again")
+	}
+	if o.dirChanged(dir) {
+		t.Error("Expected a dir read long after it changed not to be read again")
+	}
+}
+
+func TestWithBackend(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "flags.db")
+	tests := []struct {
+		name    string
+		backend string
+		want    Backend
+		check   func(c cache.Caching) bool
+	}{
+		{
+			name:    "memory",
+			backend: "memory",
+			want:    BackendMemory,
+			check: func(c cache.Caching) bool {
+				_, ok := c.(*cache.Memory)
+				return ok
+			},
+		},
+		{
+			name:    "sqlite",
+			backend: "SQLite",
+			want:    BackendSQLite,
+			check: func(c cache.Caching) bool {
+				_, ok := c.(*cache.SQLLite)
+				return ok
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := ParseBackend(tt.backend)
+			if err != nil {
+				t.Fatalf("ParseBackend: %v", err)
+			}
+			if backend != tt.want {
+				t.Errorf("Expected backend %s, got %s", tt.want, backend)
+			}
+
+			client := NewClient(WithBackend(backend), SetFileName(&filename))
+			defer func() {
+				_ = client.Close()
+			}()
+			if !tt.check(client.Cache.CacheSystem) {
+				t.Errorf("Expected %s backend, got %T", tt.want, client.Cache.CacheSystem)
+			}
+		})
+	}
+
+	if _, err := ParseBackend("redis"); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var errs []error
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithMaxRetries(1), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+
+	if client.Is("test-flag").Enabled() {
+		t.Error("Expected false for a failing server")
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if !strings.Contains(errs[0].Error(), "unexpected status code: 500") {
+		t.Errorf("Expected status code error, got %v", errs[0])
+	}
+}
+
+func TestWithErrorHandler_OverrideDirNotADirectory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "overrides")
+	if err := os.WriteFile(file, []byte("true"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	client := NewClient(WithMemory(), WithFileOverrideDir(file), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	// keep the circuit open so only the override error is reported
+	client.circuitState.isOpen = true
+	client.circuitState.lastFailure = time.Now()
+
+	client.Is("test-flag").Enabled()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "failed to read override dir") {
+		t.Errorf("Expected override dir error, got %v", errs)
+	}
+}
+
+func TestWithMaxRetryDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	maxRetryDuration := 500 * time.Millisecond
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithMaxRetries(5), WithMaxRetryDuration(maxRetryDuration))
+
+	start := time.Now()
+	err := client.refetch()
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Error("Expected an error once the retry time is exceeded")
+	}
+	if elapsed > maxRetryDuration+250*time.Millisecond {
+		t.Errorf("Expected refetch to return within %s, took %s", maxRetryDuration, elapsed)
+	}
+
+	spent := client.Stats().LastRetryDuration
+	if spent < 200*time.Millisecond || spent > elapsed {
+		t.Errorf("Expected retry duration between 200ms and %s, got %s", elapsed, spent)
+	}
+}
+
+func TestWithResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithDialTimeout(time.Second), WithResponseHeaderTimeout(100*time.Millisecond))
+
+	transport, ok := client.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected an *http.Transport, got %T", client.httpClient.Transport)
+	}
+	if transport.DialContext == nil {
+		t.Error("Expected the dial timeout to set a dialer")
+	}
+	if client.httpClient.Timeout != 10*time.Second {
+		t.Errorf("Expected the overall timeout to stay 10s, got %s", client.httpClient.Timeout)
+	}
+
+	start := time.Now()
+	_, err := client.fetchFlags(context.Background())
+	elapsed := time.Since(start)
+
+	if err == nil || !strings.Contains(err.Error(), "timeout awaiting response headers") {
+		t.Errorf("Expected the response header timeout to fire, got %v", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected the response header timeout to fire well before the overall timeout, took %s", elapsed)
+	}
+}
+
+// captureOutput gives everything written to stdout and stderr while fn runs
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	defer func() {
+		os.Stdout, os.Stderr = stdout, stderr
+	}()
+
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+
+	fn()
+	_ = w.Close()
+	return <-done
+}
+
+func TestWithQuiet(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	var handled atomic.Int32
+	filename := filepath.Join(t.TempDir(), "flags.db")
+	out := captureOutput(t, func() {
+		client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+			ProjectID:     "test-project",
+			AgentID:       "test-agent",
+			EnvironmentID: "test-environment",
+		}), SetFileName(&filename), WithQuiet(), WithErrorHandler(func(error) {
+			handled.Add(1)
+		}))
+		defer func() {
+			_ = client.Close()
+		}()
+
+		if !client.Is("test-flag").Enabled() {
+			t.Error("Expected test-flag to be enabled once the fetch recovers")
+		}
+	})
+
+	if out != "" {
+		t.Errorf("Expected no output when quiet, got %q", out)
+	}
+	if handled.Load() == 0 {
+		t.Error("Expected the transient failure to still reach the error handler")
+	}
+
+	out = captureOutput(t, func() {
+		client := NewClient(WithMemory(), WithQuiet())
+		defer func() {
+			_ = client.Close()
+		}()
+
+		if _, err := client.History("test-flag", 0); err == nil {
+			t.Error("Expected the memory cache to not keep history")
+		}
+		if _, err := client.WithAuth(Auth{ProjectID: "other"}).History("test-flag", 0); err == nil {
+			t.Error("Expected the namespaced memory cache to not keep history")
+		}
+	})
+	if out != "" {
+		t.Errorf("Expected no output from the cache when quiet, got %q", out)
+	}
+
+	out = captureOutput(t, func() {
+		if client := NewClient(WithRegion("mars"), WithQuiet()); client != nil {
+			t.Error("Expected an unknown region to fail")
+		}
+	})
+	if !strings.Contains(out, "unknown region") {
+		t.Errorf("Expected startup errors to still be logged when quiet, got %q", out)
+	}
+}
+
+func TestClient_SetAuth(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		response := fmt.Sprintf(`{
+			"intervalAllowed": 60,
+			"flags": [{"enabled": %t, "details": {"name": "green-flag", "id": "1"}}]
+		}`, r.Header.Get("X-Environment-ID") == "green")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	blue := Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "blue",
+	}
+	client := NewClient(WithBaseURL(server.URL), WithAuth(blue), WithMemory(), WithEvalCacheSize(10))
+	if client.Is("green-flag").Enabled() {
+		t.Fatal("Expected green-flag to be disabled for blue")
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					client.Is("green-flag").Enabled()
+				}
+			}
+		}()
+	}
+
+	green := blue
+	green.EnvironmentID = "green"
+	if err := client.SetAuth(green); err != nil {
+		t.Errorf("SetAuth: %v", err)
+	}
+	close(done)
+	wg.Wait()
+
+	if !client.Is("green-flag").Enabled() {
+		t.Error("Expected green-flag to be enabled once rotated to green")
+	}
+
+	fetched := requests.Load()
+	if err := client.SetAuth(green); err != nil {
+		t.Errorf("SetAuth with the same auth: %v", err)
+	}
+	if requests.Load() != fetched {
+		t.Error("Expected setting the same auth to not refetch")
+	}
+}
+
+func TestClient_Reset(t *testing.T) {
+	var fetches atomic.Int32
+	var enabled atomic.Bool
+	enabled.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		response := fmt.Sprintf(`{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": %t, "details": {"name": "test-flag", "id": "1"}}
+			]
+		}`, enabled.Load())
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	for _, memory := range []bool{true, false} {
+		t.Run(fmt.Sprintf("memory %t", memory), func(t *testing.T) {
+			enabled.Store(true)
+			fetches.Store(0)
+
+			backend := WithMemory()
+			if !memory {
+				fileName := filepath.Join(t.TempDir(), "flags.db")
+				backend = SetFileName(&fileName)
+			}
+			client := NewClient(WithBaseURL(server.URL), backend, WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}))
+			defer func() {
+				_ = client.Close()
+			}()
+
+			if !client.Is("test-flag").Enabled() {
+				t.Fatal("Expected test-flag to be enabled")
+			}
+			enabled.Store(false)
+			if !client.Is("test-flag").Enabled() || fetches.Load() != 1 {
+				t.Fatalf("Expected the cached value without a refetch, got %d fetches", fetches.Load())
+			}
+
+			if err := client.Reset(); err != nil {
+				t.Fatalf("Reset: %v", err)
+			}
+			if count, err := client.Count(); err != nil || count != 0 {
+				t.Errorf("Expected no cached flags after Reset, got %d (%v)", count, err)
+			}
+			if client.Is("test-flag").Enabled() {
+				t.Error("Expected the fresh value after Reset")
+			}
+			if fetches.Load() != 2 {
+				t.Errorf("Expected Reset to cause 1 refetch, got %d", fetches.Load()-1)
+			}
+		})
+	}
+}
+
+func TestClient_ResetConcurrent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				client.Is("test-flag").Enabled()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if err := client.Reset(); err != nil {
+					t.Errorf("Reset: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !client.Is("test-flag").Enabled() {
+		t.Error("Expected test-flag to be refetched after the resets")
+	}
+}
+
+func TestFetch_InvalidFlagsSkipped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "valid-flag", "id": "1"}},
+				{"enabled": true, "details": {"id": "2"}},
+				null,
+				{"enabled": true, "rollout": 150, "details": {"name": "bad-rollout", "id": "3"}},
+				{"enabled": false, "details": {"name": "Valid-Flag", "id": "4"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	var errs []error
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+
+	if !client.Is("valid-flag").Enabled() {
+		t.Error("Expected the valid flag to still be cached")
+	}
+	if client.Is("bad-rollout").Enabled() {
+		t.Error("Expected a flag with an out of range rollout to be skipped")
+	}
+
+	all, err := client.Cache.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Errorf("Expected only the valid flag to be cached, got %+v", all)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error for the invalid flags, got %v", errs)
+	}
+	for _, want := range []string{"skipped 4 flags", "flag 1 has no name", "flag 2 has no name", "flag 3 (bad-rollout) has a rollout of 150", "flag 4 (Valid-Flag) is a duplicate"} {
+		if !strings.Contains(errs[0].Error(), want) {
+			t.Errorf("Expected the error to contain %q, got %v", want, errs[0])
+		}
+	}
+}
+
+func TestWithClientVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{
+			name: "default version",
+			want: Version,
+		},
+		{
+			name: "with commit sha",
+			opts: []Option{WithClientVersion("abc1234")},
+			want: Version + "+abc1234",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got atomic.Value
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got.Store(r.Header.Get("X-Flags-Client-Version"))
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": []}`)
+			}))
+			defer server.Close()
+
+			client := NewClient(append([]Option{WithBaseURL(server.URL), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}), WithMemory()}, tt.opts...)...)
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+
+			if got.Load() != tt.want {
+				t.Errorf("Expected X-Flags-Client-Version %q, got %q", tt.want, got.Load())
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var ids []string
+	var mu sync.Mutex
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		mu.Unlock()
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": []}`)
+	}))
+	defer server.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	t.Run("each request gets a distinct id", func(t *testing.T) {
+		ids = nil
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory())
+		for i := 0; i < 3; i++ {
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			if id == "" || seen[id] {
+				t.Errorf("Expected distinct request IDs, got %v", ids)
+			}
+			seen[id] = true
+		}
+	})
+
+	t.Run("the id is in the refetch error", func(t *testing.T) {
+		ids = nil
+		failing.Store(true)
+		defer failing.Store(false)
+
+		var errs []error
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithMaxRetries(1), WithRequestIDGenerator(func() string {
+			return "test-request-id"
+		}), WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}))
+		_ = client.refetch()
+
+		if len(ids) != 1 || ids[0] != "test-request-id" {
+			t.Errorf("Expected the generated request ID to be sent, got %v", ids)
+		}
+		if len(errs) == 0 || !strings.Contains(errs[0].Error(), "test-request-id") {
+			t.Errorf("Expected the request ID in the refetch error, got %v", errs)
+		}
+	})
+}
+
+func TestWithRequestModifier(t *testing.T) {
+	var got atomic.Value
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		got.Store(r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": []}`)
+	}))
+	defer server.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	t.Run("adds a bearer token", func(t *testing.T) {
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithRequestModifier(func(r *http.Request) error {
+			if r.Header.Get("X-Project-ID") != "test-project" {
+				return fmt.Errorf("expected the standard headers to be set first")
+			}
+			r.Header.Set("Authorization", "Bearer test-token")
+			return nil
+		}))
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+
+		if got.Load() != "Bearer test-token" {
+			t.Errorf("Expected the bearer token on the wire, got %q", got.Load())
+		}
+	})
+
+	t.Run("an error aborts the fetch", func(t *testing.T) {
+		calls.Store(0)
+		var errs []error
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithMaxRetries(1), WithRequestModifier(func(r *http.Request) error {
+			return fmt.Errorf("token expired")
+		}), WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}))
+		_ = client.refetch()
+
+		if calls.Load() != 0 {
+			t.Errorf("Expected no request to be sent, got %d", calls.Load())
+		}
+		if len(errs) == 0 || !strings.Contains(errs[0].Error(), "token expired") {
+			t.Errorf("Expected the modifier error to be reported, got %v", errs)
+		}
+	})
+}
+
+func TestWithBasicAuth(t *testing.T) {
+	var authorization, proxyAuthorization atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+		proxyAuthorization.Store(r.Header.Get("Proxy-Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": []}`)
+	}))
+	defer server.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+	// base64 of proxy-user:proxy-pass
+	basic := "Basic cHJveHktdXNlcjpwcm94eS1wYXNz"
+
+	t.Run("sets the basic header", func(t *testing.T) {
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithBasicAuth("proxy-user", "proxy-pass"))
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+
+		if authorization.Load() != basic {
+			t.Errorf("Expected %q on the wire, got %q", basic, authorization.Load())
+		}
+		if proxyAuthorization.Load() != "" {
+			t.Errorf("Expected no Proxy-Authorization header, got %q", proxyAuthorization.Load())
+		}
+	})
+
+	t.Run("keeps a bearer token from a modifier", func(t *testing.T) {
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithBasicAuth("proxy-user", "proxy-pass"), WithRequestModifier(func(r *http.Request) error {
+			r.Header.Set("Authorization", "Bearer test-token")
+			return nil
+		}))
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+
+		if authorization.Load() != "Bearer test-token" {
+			t.Errorf("Expected the bearer token in Authorization, got %q", authorization.Load())
+		}
+		if proxyAuthorization.Load() != basic {
+			t.Errorf("Expected %q in Proxy-Authorization, got %q", basic, proxyAuthorization.Load())
+		}
+	})
+}
+
+func TestWithBootstrapURL(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithBootstrapURL(server.URL+"/snapshot.json"), WithPingOnStart(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	// neither a ping nor a diff uses up the bootstrap, run with -race
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Ping(context.Background()); err != nil {
+				t.Errorf("Ping: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := client.Diff(context.Background()); err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch %d: %v", i, err)
+		}
+	}
+	if !client.Is("test-flag").Enabled() {
+		t.Error("Expected test-flag to be enabled")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"/flags", "/flags", "/flags", "/flags", "/snapshot.json", "/flags", "/flags"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("Expected the fetches to go to %v, got %v", want, paths)
+	}
+}
+
+// countingListener counts the connections accepted
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+func TestWithTransportTuning(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	listener := &countingListener{Listener: server.Listener}
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithDialTimeout(time.Second), WithTransportTuning(TransportTuning{
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     time.Minute,
+		KeepAlive:           15 * time.Second,
+		ForceAttemptHTTP2:   true,
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	transport := client.roundTripper()
+	if transport.MaxIdleConnsPerHost != 4 || transport.IdleConnTimeout != time.Minute || !transport.ForceAttemptHTTP2 {
+		t.Errorf("Expected the tuning to be applied, got %d idle conns, %s idle timeout, force HTTP/2 %t", transport.MaxIdleConnsPerHost, transport.IdleConnTimeout, transport.ForceAttemptHTTP2)
+	}
+	if d := client.dialer(); d.KeepAlive != 15*time.Second || d.Timeout != time.Second {
+		t.Errorf("Expected the dialer to keep the dial timeout and the keep-alive, got %s and %s", d.Timeout, d.KeepAlive)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch %d: %v", i, err)
+		}
+	}
+	if got := listener.accepted.Load(); got != 1 {
+		t.Errorf("Expected the refetches to reuse a single connection, got %d", got)
+	}
+	if !client.Stats().LastConnectionReused {
+		t.Error("Expected the last refetch to report a reused connection")
+	}
+}
+
+func TestFetchFlags_CacheControlMaxAge(t *testing.T) {
+	var cacheControl atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := cacheControl.Load().(string); v != "" {
+			w.Header().Set("Cache-Control", v)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		cacheControl string
+		want         time.Duration
+	}{
+		{cacheControl: "max-age=30", want: 30 * time.Second},
+		{cacheControl: `public, Max-Age="30"`, want: 30 * time.Second},
+		{cacheControl: "", want: time.Minute},
+		{cacheControl: "no-cache", want: time.Minute},
+		{cacheControl: "max-age=abc", want: time.Minute},
+		{cacheControl: "max-age=0", want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			cacheControl.Store(tt.cacheControl)
+			client := NewClient(WithBaseURL(server.URL), WithMemory(), WithAuth(Auth{
+				ProjectID:     "test-project",
+				AgentID:       "test-agent",
+				EnvironmentID: "test-environment",
+			}))
+			defer func() {
+				_ = client.Close()
+			}()
+
+			start := time.Now()
+			if err := client.refetch(); err != nil {
+				t.Fatalf("refetch: %v", err)
+			}
+			next, ok := client.Cache.NextRefresh()
+			if !ok {
+				t.Fatal("Expected a next refresh")
+			}
+			if got := next.Sub(start); got < tt.want-time.Second || got > tt.want+time.Second {
+				t.Errorf("Expected the next refresh %s out, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithMaxEvaluationTime(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	budget := 100 * time.Millisecond
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithMaxEvaluationTime(budget))
+
+	start := time.Now()
+	if client.Is("test-flag").Enabled() {
+		t.Error("Expected the evaluation to fail closed once the budget is spent")
+	}
+	if elapsed := time.Since(start); elapsed > budget+200*time.Millisecond {
+		t.Errorf("Expected the evaluation to return within %s, took %s", budget, elapsed)
+	}
+	close(release)
+
+	// the refetch carries on in the background and fills the cache
+	deadline := time.Now().Add(2 * time.Second)
+	for !client.Is("test-flag").Enabled() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the background refetch to fill the cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,89 @@
+package flags
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// clientCertificate makes a self signed client certificate
+func clientCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+func TestWithClientCertificate(t *testing.T) {
+	cert, leaf := clientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(leaf)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	t.Run("with the certificate", func(t *testing.T) {
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithClientCertificate(cert), WithRootCAs(rootCAs))
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+		if !client.Is("test-flag").Enabled() {
+			t.Error("Expected test-flag to be enabled")
+		}
+	})
+
+	t.Run("in either order", func(t *testing.T) {
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithRootCAs(rootCAs), WithClientCertificate(cert))
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+	})
+
+	t.Run("without the certificate", func(t *testing.T) {
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithRootCAs(rootCAs), WithMaxRetries(1), WithoutCircuitBreaker(), WithQuiet())
+		if err := client.refetch(); !errors.Is(err, ErrUpstream) {
+			t.Errorf("Expected ErrUpstream, got %v", err)
+		}
+	})
+}
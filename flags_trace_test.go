@@ -0,0 +1,152 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTracerProvider gives a tracer provider whose spans are exported to the in memory exporter as they end
+func newTracerProvider(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+	})
+	return tp, exporter
+}
+
+// named gives the ended spans called name
+func named(exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStubs {
+	var spans tracetest.SpanStubs
+	for _, s := range exporter.GetSpans() {
+		if s.Name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// attributes gives the spans attributes by key
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "test-flag", "id": "1"}}]}`)
+	}))
+	defer server.Close()
+
+	auth := WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})
+
+	t.Run("fetch and evaluate", func(t *testing.T) {
+		tp, exporter := newTracerProvider(t)
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithTracerProvider(tp))
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+		if !client.Is("test-flag").Enabled() {
+			t.Fatal("Expected test-flag to be enabled")
+		}
+
+		fetches := named(exporter, "flags.fetch")
+		if len(fetches) != 1 {
+			t.Fatalf("Expected 1 fetch span, got %d", len(fetches))
+		}
+		fetch := attributes(fetches[0])
+		if fetch["flags.source"].AsString() != "http" || fetch["flags.retries"].AsInt64() != 0 || len(fetches[0].Events) != 0 {
+			t.Errorf("Unexpected fetch span %+v", fetches[0])
+		}
+		if scope := fetches[0].InstrumentationScope.Name; scope != tracerName {
+			t.Errorf("Expected the span to be recorded under %s, got %s", tracerName, scope)
+		}
+
+		evaluations := named(exporter, "flags.evaluate")
+		if len(evaluations) != 1 {
+			t.Fatalf("Expected 1 evaluate span, got %d", len(evaluations))
+		}
+		evaluate := attributes(evaluations[0])
+		if evaluate["flags.name"].AsString() != "test-flag" || !evaluate["flags.result"].AsBool() || evaluate["flags.source"].AsString() != "cache" {
+			t.Errorf("Unexpected evaluate span %+v", evaluations[0])
+		}
+	})
+
+	t.Run("evaluation is a child of the callers span", func(t *testing.T) {
+		tp, exporter := newTracerProvider(t)
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithTracerProvider(tp))
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+
+		ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+		client.Is("test-flag").EnabledCtx(ctx)
+		parent.End()
+
+		evaluations := named(exporter, "flags.evaluate")
+		if len(evaluations) != 1 {
+			t.Fatalf("Expected 1 evaluate span, got %d", len(evaluations))
+		}
+		if got, want := evaluations[0].Parent.SpanID(), parent.SpanContext().SpanID(); got != want {
+			t.Errorf("Expected the evaluate span's parent to be %s, got %s", want, got)
+		}
+		if got, want := evaluations[0].SpanContext.TraceID(), parent.SpanContext().TraceID(); got != want {
+			t.Errorf("Expected the evaluate span to be in trace %s, got %s", want, got)
+		}
+	})
+
+	t.Run("records the failure", func(t *testing.T) {
+		failing = true
+		defer func() {
+			failing = false
+		}()
+
+		tp, exporter := newTracerProvider(t)
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory(), WithTracerProvider(tp), WithMaxRetries(1), WithoutCircuitBreaker(), WithQuiet())
+		if err := client.refetch(); err == nil {
+			t.Fatal("Expected the refetch to fail")
+		}
+
+		fetches := named(exporter, "flags.fetch")
+		if len(fetches) != 1 {
+			t.Fatalf("Expected 1 fetch span, got %d", len(fetches))
+		}
+		if fetches[0].Status.Code != codes.Error {
+			t.Errorf("Expected the fetch span to be failed, got %+v", fetches[0].Status)
+		}
+		if events := fetches[0].Events; len(events) != 1 || events[0].Name != "exception" {
+			t.Errorf("Expected the error to be recorded, got %+v", events)
+		}
+	})
+
+	t.Run("nothing without a tracer provider", func(t *testing.T) {
+		client := NewClient(WithBaseURL(server.URL), auth, WithMemory())
+		if err := client.refetch(); err != nil {
+			t.Fatalf("refetch: %v", err)
+		}
+		if _, span := client.startSpan(context.Background(), "flags.fetch"); span.IsRecording() {
+			t.Errorf("Expected the no-op span, got %T", span)
+		}
+	})
+}
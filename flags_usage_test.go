@@ -0,0 +1,74 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFlagUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "enabled-flag", "id": "1"}},
+				{"enabled": false, "details": {"name": "disabled-flag", "id": "2"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory())
+
+	before := time.Now()
+	for i := 0; i < 3; i++ {
+		client.Is("enabled-flag").Enabled()
+	}
+	client.Is("Disabled-Flag").Enabled()
+	after := time.Now()
+
+	usage := client.FlagUsage()
+	tests := []struct {
+		name            string
+		flagName        string
+		wantEvaluations int64
+	}{
+		{
+			name:            "evaluated three times",
+			flagName:        "enabled-flag",
+			wantEvaluations: 3,
+		},
+		{
+			name:            "evaluated once with mixed case",
+			flagName:        "disabled-flag",
+			wantEvaluations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := usage[tt.flagName]
+			if !ok {
+				t.Fatalf("Expected usage for %s", tt.flagName)
+			}
+			if got.Evaluations != tt.wantEvaluations {
+				t.Errorf("Expected %d evaluations, got %d", tt.wantEvaluations, got.Evaluations)
+			}
+			if got.LastEvaluated.Before(before) || got.LastEvaluated.After(after) {
+				t.Errorf("Expected last evaluated between %v and %v, got %v", before, after, got.LastEvaluated)
+			}
+		})
+	}
+
+	if _, ok := usage["never-evaluated"]; ok {
+		t.Error("Expected no usage for a flag that was never evaluated")
+	}
+}
@@ -0,0 +1,71 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClient_Validate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "max-items", "id": "1"}},
+				{"enabled": false, "details": {"name": "new-search", "id": "2"}},
+				{"enabled": false, "details": {"name": "checkout", "id": "3"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	t.Setenv("FLAGS_MAX_ITEMS", "abc")
+	t.Setenv("FLAGS_NEW_SEARCH", "true")
+	t.Setenv("FLAGS_NEW_SERACH", "false")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "checkout"), []byte("yes\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithFileOverrideDir(dir), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	errs := client.Validate()
+	want := []string{
+		`env var FLAGS_MAX_ITEMS is "abc"`,
+		`env var FLAGS_NEW_SERACH is for new_serach, there's no flag with that name`,
+		`override file ` + filepath.Join(dir, "checkout") + ` is "yes"`,
+	}
+	for _, err := range errs {
+		if strings.Contains(err.Error(), "FLAGS_NEW_SEARCH") {
+			t.Errorf("Expected FLAGS_NEW_SEARCH to be valid, got %v", err)
+		}
+	}
+	for _, w := range want {
+		found := false
+		for _, err := range errs {
+			if strings.Contains(err.Error(), w) {
+				found = true
+				if !errors.Is(err, ErrInvalidOverride) {
+					t.Errorf("Expected %v to match ErrInvalidOverride", err)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected an error containing %q, got %v", w, errs)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package flags
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithResponseValidator(t *testing.T) {
+	var enabled atomic.Bool
+	enabled.Store(true)
+	server := newToggleServer(&enabled)
+	defer server.Close()
+
+	var events []RefreshEvent
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithQuiet(), WithResponseValidator(func(resp *ApiResponse) error {
+		for _, f := range resp.Flags {
+			if f.Enabled {
+				return nil
+			}
+		}
+		return errors.New("every flag is disabled")
+	}), WithRefreshHandler(func(event RefreshEvent) {
+		events = append(events, event)
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if err := client.refetch(); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+
+	enabled.Store(false)
+	err := client.refetch()
+	if !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected the all disabled response to be rejected, got %v", err)
+	}
+	if len(events) != 2 || !errors.Is(events[1].Err, ErrRejected) {
+		t.Errorf("Expected the rejection in the refresh event, got %+v", events)
+	}
+	if !client.Is("test-flag").Enabled() {
+		t.Error("Expected the previously cached value to survive the rejected response")
+	}
+}
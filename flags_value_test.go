@@ -0,0 +1,68 @@
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "value": "42", "details": {"name": "max-items", "id": "1"}},
+				{"enabled": true, "value": "checkout-v2", "details": {"name": "variant", "id": "2"}},
+				{"enabled": true, "value": "{\"limit\": 5, \"regions\": [\"eu\", \"us\"]}", "details": {"name": "rate-limit", "id": "3"}},
+				{"enabled": false, "value": "7", "details": {"name": "disabled-items", "id": "4"}},
+				{"enabled": true, "details": {"name": "no-value", "id": "5"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	type rateLimit struct {
+		Limit   int      `json:"limit"`
+		Regions []string `json:"regions"`
+	}
+
+	if got := Value(client.Is("max-items"), 10); got != 42 {
+		t.Errorf("Expected the int 42, got %d", got)
+	}
+	if got := Value(client.Is("variant"), "control"); got != "checkout-v2" {
+		t.Errorf("Expected the string checkout-v2, got %q", got)
+	}
+	got := Value(client.Is("rate-limit"), rateLimit{})
+	if got.Limit != 5 || len(got.Regions) != 2 || got.Regions[1] != "us" {
+		t.Errorf("Expected the struct to be decoded, got %+v", got)
+	}
+
+	t.Run("default on a miss", func(t *testing.T) {
+		tests := []struct {
+			name string
+			got  int
+		}{
+			{name: "unknown flag", got: Value(client.Is("missing"), 10)},
+			{name: "disabled flag", got: Value(client.Is("disabled-items"), 10)},
+			{name: "no value", got: Value(client.Is("no-value"), 10)},
+			{name: "type mismatch", got: Value(client.Is("variant"), 10)},
+		}
+		for _, tt := range tests {
+			if tt.got != 10 {
+				t.Errorf("%s: expected the default 10, got %d", tt.name, tt.got)
+			}
+		}
+	})
+}
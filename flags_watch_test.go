@@ -0,0 +1,100 @@
+package flags
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, ch <-chan bool) (bool, bool) {
+	t.Helper()
+
+	select {
+	case enabled, ok := <-ch:
+		return enabled, ok
+	case <-time.After(time.Second):
+		t.Fatal("Expected a value from the watch channel")
+		return false, false
+	}
+}
+
+func TestFlag_Watch(t *testing.T) {
+	var enabled atomic.Bool
+	enabled.Store(true)
+	server := newToggleServer(&enabled)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithMemory(), WithEvalCacheSize(10))
+
+	ch, cancel := client.Is("Test-Flag").Watch()
+	if got, _ := receive(t, ch); !got {
+		t.Error("Expected the current value of true on subscription")
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		emits   bool
+	}{
+		{
+			name:    "disabled",
+			enabled: false,
+			emits:   true,
+		},
+		{
+			name:    "unchanged",
+			enabled: false,
+			emits:   false,
+		},
+		{
+			name:    "enabled again",
+			enabled: true,
+			emits:   true,
+		},
+	}
+	for _, tt := range tests {
+		enabled.Store(tt.enabled)
+		if err := client.refetch(); err != nil {
+			t.Fatalf("%s: refetch: %v", tt.name, err)
+		}
+
+		if !tt.emits {
+			select {
+			case got := <-ch:
+				t.Errorf("%s: expected no emission, got %v", tt.name, got)
+			default:
+			}
+			continue
+		}
+		if got, _ := receive(t, ch); got != tt.enabled {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.enabled)
+		}
+	}
+
+	cancel()
+	cancel()
+	if _, ok := receive(t, ch); ok {
+		t.Error("Expected the channel to be closed on cancel")
+	}
+
+	closed, _ := client.Is("test-flag").Watch()
+	if got, _ := receive(t, closed); !got {
+		t.Error("Expected the current value of true on subscription")
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := receive(t, closed); ok {
+		t.Error("Expected the channel to be closed on Close")
+	}
+
+	after, _ := client.Is("test-flag").Watch()
+	<-after
+	if _, ok := receive(t, after); ok {
+		t.Error("Expected watching a closed client to give a closed channel")
+	}
+}
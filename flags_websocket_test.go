@@ -0,0 +1,175 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, what string, fn func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWithWebSocket(t *testing.T) {
+	sendDelta := make(chan struct{})
+	var projectID atomic.Value
+	mux := http.NewServeMux()
+	mux.Handle("/ws", websocket.Handler(func(ws *websocket.Conn) {
+		projectID.Store(ws.Request().Header.Get("X-Project-ID"))
+
+		_ = websocket.Message.Send(ws, `{
+			"type": "snapshot",
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "pushed-flag", "id": "1"}},
+				{"enabled": true, "details": {"name": "removed-flag", "id": "2"}}
+			]
+		}`)
+
+		<-sendDelta
+		_ = websocket.Message.Send(ws, `{
+			"type": "delta",
+			"flags": [
+				{"enabled": false, "details": {"name": "Pushed-Flag", "id": "1"}},
+				{"enabled": true, "details": {"name": "added-flag", "id": "3"}}
+			],
+			"removed": ["removed-flag"]
+		}`)
+
+		// hold the connection open until the client closes it
+		var discard string
+		_ = websocket.Message.Receive(ws, &discard)
+	}))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithWebSocket("ws"+strings.TrimPrefix(server.URL, "http")+"/ws"), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	waitFor(t, "the snapshot", func() bool {
+		enabled, ok := client.Cache.Get("pushed-flag")
+		return ok && enabled
+	})
+	if projectID.Load() != "test-project" {
+		t.Errorf("Expected the auth in the handshake, got %v", projectID.Load())
+	}
+	if client.Cache.ShouldRefreshCache() {
+		t.Error("Expected the snapshot to keep the cache fresh")
+	}
+
+	close(sendDelta)
+	waitFor(t, "the delta", func() bool {
+		_, ok := client.Cache.Get("added-flag")
+		return ok
+	})
+	if enabled, _ := client.Cache.Get("pushed-flag"); enabled {
+		t.Error("Expected the delta to disable pushed-flag")
+	}
+	if _, ok := client.Cache.Get("removed-flag"); ok {
+		t.Error("Expected the delta to remove removed-flag")
+	}
+	if !client.Is("added-flag").Enabled() {
+		t.Error("Expected added-flag to be enabled")
+	}
+}
+
+func TestWithWebSocket_FallsBackToPolling(t *testing.T) {
+	var fetches atomic.Int32
+	mux := http.NewServeMux()
+	mux.Handle("/ws", websocket.Handler(func(ws *websocket.Conn) {
+		// drop the connection straight away, like a gateway that's down
+	}))
+	mux.HandleFunc("/flags", func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, `{"intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "polled-flag", "id": "1"}}]}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var errs atomic.Int32
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithWebSocket("ws"+strings.TrimPrefix(server.URL, "http")+"/ws"), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithErrorHandler(func(err error) {
+		errs.Add(1)
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if !client.Is("polled-flag").Enabled() {
+		t.Error("Expected polled-flag to be fetched over HTTP")
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("Expected one HTTP fetch, got %d", fetches.Load())
+	}
+	waitFor(t, "the lost connection to be reported", func() bool {
+		return errs.Load() > 0
+	})
+}
+
+func TestWithWebSocket_MalformedSnapshotKeepsCache(t *testing.T) {
+	sendBad := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/ws", websocket.Handler(func(ws *websocket.Conn) {
+		_ = websocket.Message.Send(ws, `{"type": "snapshot", "intervalAllowed": 60, "flags": [{"enabled": true, "details": {"name": "pushed-flag", "id": "1"}}]}`)
+
+		<-sendBad
+		_ = websocket.Message.Send(ws, `{"type": "snapshot", "error": "upstream unavailable"}`)
+		_ = websocket.Message.Send(ws, `{"type": "snapshot", "flags": [`)
+
+		var discard string
+		_ = websocket.Message.Receive(ws, &discard)
+	}))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var decodeErrs atomic.Int32
+	client := NewClient(WithBaseURL(server.URL), WithMemory(), WithWebSocket("ws"+strings.TrimPrefix(server.URL, "http")+"/ws"), WithAuth(Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	}), WithQuiet(), WithErrorHandler(func(err error) {
+		if errors.Is(err, ErrDecode) {
+			decodeErrs.Add(1)
+		}
+	}))
+	defer func() {
+		_ = client.Close()
+	}()
+
+	waitFor(t, "the snapshot", func() bool {
+		enabled, ok := client.Cache.Get("pushed-flag")
+		return ok && enabled
+	})
+
+	close(sendBad)
+	waitFor(t, "the malformed snapshots to be reported", func() bool {
+		return decodeErrs.Load() == 2
+	})
+	if enabled, ok := client.Cache.Get("pushed-flag"); !ok || !enabled {
+		t.Error("Expected the malformed snapshots to keep the cached flags")
+	}
+}
@@ -0,0 +1,66 @@
+package flags
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// groups are named sets of flags that make up one feature, registered with Client.Group
+type groups struct {
+	mu      sync.RWMutex
+	members map[string][]string
+}
+
+// Group registers name as a feature made of the member flags, so it can be gated on with GroupEnabled or
+// GroupAnyEnabled. Registering a name again replaces its members
+func (c *Client) Group(name string, members ...string) {
+	c.groups.mu.Lock()
+	defer c.groups.mu.Unlock()
+
+	if c.groups.members == nil {
+		c.groups.members = make(map[string][]string)
+	}
+	c.groups.members[strings.ToLower(name)] = slices.Clone(members)
+}
+
+// GroupEnabled reports whether every member of the group is enabled, a group that isn't registered or has no
+// members isn't enabled
+func (c *Client) GroupEnabled(name string) bool {
+	members := c.groups.get(name)
+	if len(members) == 0 {
+		return false
+	}
+	for _, member := range members {
+		if !c.Is(member).Enabled() {
+			return false
+		}
+	}
+	return true
+}
+
+// GroupAnyEnabled reports whether at least one member of the group is enabled
+func (c *Client) GroupAnyEnabled(name string) bool {
+	for _, member := range c.groups.get(name) {
+		if c.Is(member).Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *groups) get(name string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.members[strings.ToLower(name)]
+}
+
+// clone copies the groups for a client from WithAuth, so registering one on either doesn't change the other
+func (g *groups) clone() *groups {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return &groups{members: maps.Clone(g.members)}
+}
@@ -0,0 +1,101 @@
+package flags
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// GRPCGetFlagsMethod is the full method name the gRPC transport calls, the service is defined as
+//
+//	service FlagService {
+//	  rpc GetFlags(google.protobuf.Empty) returns (google.protobuf.Struct);
+//	}
+//
+// with the returned Struct having the same shape as the HTTP API response
+const GRPCGetFlagsMethod = "/flags.v1.FlagService/GetFlags"
+
+// FlagServiceClient is the client for the flags gRPC service
+type FlagServiceClient interface {
+	GetFlags(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error)
+}
+
+type flagServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFlagServiceClient(cc grpc.ClientConnInterface) FlagServiceClient {
+	return &flagServiceClient{cc: cc}
+}
+
+func (c *flagServiceClient) GetFlags(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := c.cc.Invoke(ctx, GRPCGetFlagsMethod, in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// grpcTransport fetches the flags from a gRPC flags service, auth is sent as metadata
+type grpcTransport struct {
+	client  *Client
+	service FlagServiceClient
+}
+
+func (t *grpcTransport) Fetch(ctx context.Context) (*ApiResponse, error) {
+	if err := t.client.checkAuth(); err != nil {
+		return nil, err
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		"x-project-id", t.client.auth.ProjectID,
+		"x-agent-id", t.client.auth.AgentID,
+		"x-environment-id", t.client.auth.EnvironmentID,
+		"x-flags-client-version", t.client.reportedVersion(),
+	)
+	if t.client.evalContext != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-flags-context", t.client.evalContext)
+	}
+
+	resp, err := t.service.GetFlags(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, t.client.errorf("%w: failed to execute grpc request: %w", ErrUpstream, err)
+	}
+
+	body, err := protojson.Marshal(resp)
+	if err != nil {
+		return nil, t.client.errorf("%w: failed to encode grpc response: %w", ErrDecode, err)
+	}
+
+	apiResp, err := decodeResponse(body)
+	if err != nil {
+		return nil, t.client.errorf("%w: failed to decode body %w", ErrDecode, err)
+	}
+	return apiResp, nil
+}
+
+func (t *grpcTransport) bind(c *Client) transport {
+	return &grpcTransport{
+		client:  c,
+		service: t.service,
+	}
+}
+
+func (t *grpcTransport) source() string {
+	return "grpc"
+}
+
+// WithGRPC fetches the flags over the given gRPC connection instead of HTTP. The evaluation context of IsWithContext
+// is sent as x-flags-context metadata. WithBootstrapURL and WithRegions are HTTP endpoints, so NewClient fails if
+// either is given with it
+func WithGRPC(conn *grpc.ClientConn) Option {
+	return func(c *Client) {
+		c.transport = &grpcTransport{
+			client:  c,
+			service: NewFlagServiceClient(conn),
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package flags
+
+import (
+	"github.com/flags-gg/go-flags/flag"
+	"strings"
+	"time"
+)
+
+// FlagChange is a flag changing value, recorded when a refresh gives a different value than the one cached
+type FlagChange struct {
+	Name      string
+	Enabled   bool
+	ChangedAt time.Time
+	// Old and New are the flag before and after the change, they're only set for a change from Subscribe or Diff.
+	// Old is nil for a flag that's been added and New for one that's been removed
+	Old *flag.FeatureFlag
+	New *flag.FeatureFlag
+}
+
+// History gives up to limit of the flags most recent value changes, newest first, a limit of 0 or less gives them all.
+// A flag seen for the first time counts as a change, only the SQLite cache keeps history
+func (c *Client) History(name string, limit int) ([]FlagChange, error) {
+	changes, err := c.Cache.History(strings.ToLower(name), limit)
+	if err != nil {
+		return nil, c.errorf("%w: failed to get flag history: %w", ErrCacheUnavailable, err)
+	}
+
+	history := make([]FlagChange, 0, len(changes))
+	for _, change := range changes {
+		history = append(history, FlagChange{
+			Name:      change.Name,
+			Enabled:   change.Enabled,
+			ChangedAt: change.ChangedAt,
+		})
+	}
+	return history, nil
+}
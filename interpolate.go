@@ -0,0 +1,66 @@
+package flags
+
+import (
+	"os"
+	"strings"
+)
+
+// WithInterpolation has String replace ${VAR} in flag values with the VAR env var when it's read, a placeholder for
+// a var that isn't set is left as it is. $${ is a literal ${, so $${VAR} reads as ${VAR}
+func WithInterpolation() Option {
+	return func(c *Client) {
+		c.interpolation = true
+	}
+}
+
+// String gives the flags string value, empty if the flag is off, unknown, or only enabled by a local override
+func (f *Flag) String() string {
+	c := f.Client
+	name := f.name()
+	if !c.isEnabled(name) {
+		return ""
+	}
+
+	featureFlag, ok := c.Cache.GetFlag(c.canonical(name))
+	if !ok {
+		return ""
+	}
+	if c.interpolation {
+		return interpolate(featureFlag.Value)
+	}
+	return featureFlag.Value
+}
+
+// interpolate replaces each ${VAR} with the env var, leaving the placeholder for one that isn't set
+func interpolate(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			b.WriteString("${")
+			i += len("$${")
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				// unterminated, there's nothing more to replace
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			placeholder := s[i : i+2+end+1]
+			if val, ok := os.LookupEnv(s[i+2 : i+2+end]); ok && end > 0 {
+				b.WriteString(val)
+			} else {
+				b.WriteString(placeholder)
+			}
+			i += len(placeholder)
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package flags
+
+import (
+	"time"
+)
+
+// WithIntervalUnit is the unit the API gives intervalAllowed in, seconds unless it's set, e.g. time.Millisecond for
+// a backend that sends milliseconds. The cache keeps whole seconds, so part of a second is rounded up
+func WithIntervalUnit(unit time.Duration) Option {
+	return func(c *Client) {
+		if unit > 0 {
+			c.intervalUnit = unit
+		}
+	}
+}
+
+// WithDefaultInterval is the refresh interval until the first successful fetch gives one. Either cache backend is
+// seeded with it (60 seconds unless it's set), and a fetch that fails before one has succeeded isn't tried again
+// until d has passed, evaluations serve the overrides or off in the meantime. Without it every stale evaluation
+// tries again, as far as the circuit breaker allows
+func WithDefaultInterval(d time.Duration) Option {
+	return func(c *Client) {
+		if d > 0 {
+			c.defaultInterval = d
+			c.Cache.SetDefaultInterval(d)
+		}
+	}
+}
+
+// backOff puts the next fetch off for the default interval, if nothing has been fetched yet. It expects the caller
+// to hold the mutex
+func (c *Client) backOff() {
+	if c.defaultInterval <= 0 {
+		return
+	}
+	if _, refreshed := c.Cache.NextRefresh(); refreshed {
+		return
+	}
+	c.retryAt.Store(c.now().Add(c.defaultInterval).UnixNano())
+}
+
+// backingOff reports whether a fetch is being put off by backOff
+func (c *Client) backingOff() bool {
+	retryAt := c.retryAt.Load()
+	return retryAt != 0 && c.now().UnixNano() < retryAt
+}
+
+// unit gives the unit intervalAllowed is in
+func (c *Client) unit() time.Duration {
+	if c.intervalUnit <= 0 {
+		return time.Second
+	}
+	return c.intervalUnit
+}
+
+// intervalSeconds converts intervalAllowed from the APIs unit to the whole seconds the cache keeps, rounded up so a
+// short interval doesn't become no interval at all
+func (c *Client) intervalSeconds(intervalAllowed int) int {
+	unit := c.unit()
+	if unit == time.Second {
+		return intervalAllowed
+	}
+	d := time.Duration(intervalAllowed) * unit
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,20 @@
+package flags
+
+// WithRefreshJitter shortens the first cache TTL by a random part of up to fraction of the interval the API asked for,
+// so a fleet deployed together doesn't refetch in step on every interval after. The fraction is clamped to [0, 1]
+func WithRefreshJitter(fraction float64) Option {
+	return func(c *Client) {
+		c.refreshJitter = min(max(fraction, 0), 1)
+	}
+}
+
+// jitter gives the interval for the cache, only the first refresh of the client is jittered. It expects the caller
+// to hold the mutex
+func (c *Client) jitter(intervalAllowed int) int {
+	if c.jittered || c.refreshJitter <= 0 || intervalAllowed <= 0 {
+		return intervalAllowed
+	}
+	c.jittered = true
+
+	return intervalAllowed - int(c.sample()*c.refreshJitter*float64(intervalAllowed))
+}
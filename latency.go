@@ -0,0 +1,81 @@
+package flags
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyWindow is how many of the most recent fetches the percentiles are taken over
+const latencyWindow = 512
+
+// LatencyStats is how long fetches from the flags API have taken, every attempt counts including failed ones
+type LatencyStats struct {
+	// Count is how many fetches have been made, the percentiles are over the most recent of them
+	Count int64
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+// fetchLatency keeps the durations of the most recent fetches in a ring, so recording one never allocates
+type fetchLatency struct {
+	mu        sync.Mutex
+	durations [latencyWindow]time.Duration
+	count     int64
+}
+
+func (l *fetchLatency) record(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.durations[l.count%latencyWindow] = d
+	l.count++
+}
+
+func (l *fetchLatency) stats() LatencyStats {
+	l.mu.Lock()
+	recent := make([]time.Duration, min(l.count, latencyWindow))
+	copy(recent, l.durations[:len(recent)])
+	count := l.count
+	l.mu.Unlock()
+
+	if len(recent) == 0 {
+		return LatencyStats{}
+	}
+	slices.Sort(recent)
+	return LatencyStats{
+		Count: count,
+		P50:   percentile(recent, 50),
+		P95:   percentile(recent, 95),
+		P99:   percentile(recent, 99),
+	}
+}
+
+// percentile gives the nearest rank percentile p of the sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// FetchLatency gives the count and percentiles of how long fetches from the flags API have taken
+func (c *Client) FetchLatency() LatencyStats {
+	return c.latency.stats()
+}
+
+// timedFetch is the transports Fetch once there's a fetch slot free, recording how long it took without the wait
+func (c *Client) timedFetch(ctx context.Context) (*ApiResponse, error) {
+	release, err := c.refetchLimit.acquire(ctx)
+	if err != nil {
+		return nil, c.errorf("waiting for a fetch slot: %w", err)
+	}
+	defer release()
+
+	start := time.Now()
+	defer func() {
+		c.latency.record(time.Since(start))
+	}()
+
+	return c.transport.Fetch(ctx)
+}
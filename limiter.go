@@ -0,0 +1,81 @@
+package flags
+
+import (
+	"context"
+	"sync"
+)
+
+// refetchLimit is the one process wide cap WithMaxConcurrentRefetches puts clients under
+var refetchLimit = &limiter{}
+
+// WithMaxConcurrentRefetches caps how many flag fetches run at once across the process, e.g. when it hosts a lot of
+// short lived clients. Every client given it shares one cap, a fetch past it waits for a slot to free up, or for its
+// context to be done. When clients are given different n the smallest wins, the cap only ever tightens. n below 1 is
+// no cap for this client, its fetches aren't counted, nor are those of clients without the option
+func WithMaxConcurrentRefetches(n int) Option {
+	return func(c *Client) {
+		if n < 1 {
+			c.refetchLimit = nil
+			return
+		}
+		refetchLimit.tighten(n)
+		c.refetchLimit = refetchLimit
+	}
+}
+
+// limiter counts the fetches in flight against a cap
+type limiter struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	// freed is closed when a slot frees up, so every waiter checks again
+	freed chan struct{}
+}
+
+// tighten lowers the cap to n, a higher n leaves it as it is
+func (l *limiter) tighten(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit == 0 || n < l.limit {
+		l.limit = n
+	}
+}
+
+// acquire waits for a slot, the release it gives has to be called once the fetch is done. A nil limiter never waits
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	for {
+		l.mu.Lock()
+		if l.inFlight < l.limit {
+			l.inFlight++
+			l.mu.Unlock()
+			return l.release, nil
+		}
+		if l.freed == nil {
+			l.freed = make(chan struct{})
+		}
+		freed := l.freed
+		l.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	if l.freed != nil {
+		close(l.freed)
+		l.freed = nil
+	}
+}
@@ -0,0 +1,76 @@
+package flags
+
+import (
+	"strings"
+)
+
+// LocalKeyStyle is which flag names an override from a FLAGS_ env var or override file applies to. An override
+// written with underscores, like FLAGS_NEW_SEARCH, always applies to new_search; the style is whether it also applies
+// to the dash and space forms new-search and new search, which an env var name can't hold
+type LocalKeyStyle int
+
+const (
+	// LocalKeyAuto applies an override to the dash and space forms, unless a flag with its underscore name is cached,
+	// then the override is for that flag alone. This is the default
+	LocalKeyAuto LocalKeyStyle = iota
+	// LocalKeyAll always applies an override to the dash and space forms too, even shadowing a flag that has them
+	LocalKeyAll
+	// LocalKeyExact only applies an override to the flag with its exact name, e.g. an override file named new-search
+	LocalKeyExact
+)
+
+// WithLocalKeyStyle sets which flag names an override applies to, LocalKeyAuto unless it's set. An override file
+// or env var for the exact name wins over one for another form of it
+func WithLocalKeyStyle(style LocalKeyStyle) Option {
+	return func(c *Client) {
+		c.localKeyStyle = style
+	}
+}
+
+// localKey gives the underscore form of the flag name, the form an override for any of its forms is kept under
+func localKey(name string) string {
+	return strings.NewReplacer("-", "_", " ", "_").Replace(name)
+}
+
+// lookupLocal gives the override in col for the already lowercased flag, if one applies to it
+func (c *Client) lookupLocal(col map[string]bool, name string) (bool, bool) {
+	if enabled, ok := col[name]; ok {
+		return enabled, true
+	}
+	if c.localKeyStyle == LocalKeyExact {
+		return false, false
+	}
+
+	key := localKey(name)
+	if key == name {
+		return false, false
+	}
+	enabled, ok := col[key]
+	if !ok {
+		return false, false
+	}
+	if c.localKeyStyle == LocalKeyAuto {
+		// the override is for the flag that has the name as written
+		if _, cached := c.Cache.GetFlag(key); cached {
+			return false, false
+		}
+	}
+	return enabled, true
+}
+
+// knownOverride reports whether the override name applies to a known flag
+func (c *Client) knownOverride(known map[string]bool, name string) bool {
+	name = strings.ToLower(name)
+	if known[name] {
+		return true
+	}
+	if c.localKeyStyle == LocalKeyExact {
+		return false
+	}
+	for flag := range known {
+		if localKey(flag) == name {
+			return true
+		}
+	}
+	return false
+}
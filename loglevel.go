@@ -0,0 +1,65 @@
+package flags
+
+import (
+	"fmt"
+	"github.com/bugfixes/go-bugfixes/logs"
+)
+
+// LogLevel is how much the client logs, errors are still returned and passed to the error handler whatever the level
+type LogLevel int
+
+const (
+	// LogLevelAll logs every error, including each failed fetch while retrying
+	LogLevelAll LogLevel = iota
+	// LogLevelStartup only logs errors creating the client, routine fetch and cache errors aren't logged
+	LogLevelStartup
+	// LogLevelNone doesn't log anything
+	LogLevelNone
+)
+
+// errorSkipDepth is the frame the logs are attributed to, the caller of errorf rather than errorf itself
+const errorSkipDepth = 4
+
+// WithLogLevel sets how much the client logs
+func WithLogLevel(level LogLevel) Option {
+	return func(c *Client) {
+		c.logLevel = level
+		if level > LogLevelAll {
+			c.Cache.SetQuiet()
+		}
+	}
+}
+
+// WithQuiet only logs errors creating the client, so brief outages don't fill the logs with retries
+func WithQuiet() Option {
+	return WithLogLevel(LogLevelStartup)
+}
+
+// errorf builds a routine error, it's only logged at LogLevelAll. The format can wrap with %w
+func (c *Client) errorf(format string, inputs ...interface{}) error {
+	err := fmt.Errorf(format, inputs...)
+	if c.logLevel > LogLevelAll {
+		return err
+	}
+	// the logger formats with Sprintf, which can't take %w, so it logs the built message
+	(&logs.BugFixes{SkipDepthOverride: errorSkipDepth}).Errorf("%s", err)
+	return err
+}
+
+// startupErrorf builds an error creating the client, it's logged unless the level is LogLevelNone
+func (c *Client) startupErrorf(format string, inputs ...interface{}) error {
+	err := fmt.Errorf(format, inputs...)
+	if c.logLevel >= LogLevelNone {
+		return err
+	}
+	(&logs.BugFixes{SkipDepthOverride: errorSkipDepth}).Errorf("%s", err)
+	return err
+}
+
+// warnf logs a warning, like a startup error it's logged unless the level is LogLevelNone
+func (c *Client) warnf(format string, inputs ...interface{}) {
+	if c.logLevel >= LogLevelNone {
+		return
+	}
+	(&logs.BugFixes{SkipDepthOverride: errorSkipDepth}).Warnf(format, inputs...)
+}
@@ -0,0 +1,32 @@
+package flags
+
+import (
+	"time"
+
+	"github.com/flags-gg/go-flags/flag"
+)
+
+// FlagWithMeta is a cached flag with when it was last refreshed
+type FlagWithMeta struct {
+	flag.FeatureFlag
+	// UpdatedAt is when the flag was last refreshed, to the second with SQLite
+	UpdatedAt time.Time
+}
+
+// ListWithMeta gives every cached flag with when it was last refreshed, e.g. for an admin page showing how fresh each
+// flag is. It waits for a refresh in flight to finish
+func (c *Client) ListWithMeta() ([]FlagWithMeta, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	updated, err := c.Cache.GetAllUpdated()
+	if err != nil {
+		return nil, c.errorf("%w: failed to list flags: %w", ErrCacheUnavailable, err)
+	}
+
+	flags := make([]FlagWithMeta, 0, len(updated))
+	for _, f := range updated {
+		flags = append(flags, FlagWithMeta{FeatureFlag: f.FeatureFlag, UpdatedAt: f.UpdatedAt})
+	}
+	return flags, nil
+}
@@ -0,0 +1,24 @@
+package flags
+
+import (
+	"net/http"
+)
+
+// RequireFlag is net/http middleware gating the handlers it wraps behind the flag, it's evaluated for each request
+// with the requests context, so WithEvaluationKeyFromContext can roll it out per user. While the flag is off the
+// request is served by onDisabled, a 404 when it's nil
+func (c *Client) RequireFlag(name string, onDisabled http.Handler) func(http.Handler) http.Handler {
+	if onDisabled == nil {
+		onDisabled = http.NotFoundHandler()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !c.Is(name).EnabledCtx(r.Context()) {
+				onDisabled.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
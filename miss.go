@@ -0,0 +1,77 @@
+package flags
+
+import (
+	"sync"
+)
+
+// WithRefetchOnMiss refetches when a flag that isn't cached is evaluated against a fresh cache, so a flag created
+// since the last refresh is picked up without waiting for the cache to go stale. Each name only triggers one refetch
+// until the cache next goes stale and is refreshed, so a flag that doesn't exist isn't refetched for on every call,
+// and concurrent evaluations of it wait on the one refetch
+func WithRefetchOnMiss() Option {
+	return func(c *Client) {
+		c.refetchOnMiss = true
+	}
+}
+
+// misses are the names of unknown flags that have been refetched for since the last stale refresh
+type misses struct {
+	mu    sync.Mutex
+	tried map[string]struct{}
+}
+
+// try reports whether the name hasn't been refetched for yet, marking it as refetched
+func (m *misses) try(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tried[name]; ok {
+		return false
+	}
+	if m.tried == nil {
+		m.tried = make(map[string]struct{})
+	}
+	m.tried[name] = struct{}{}
+	return true
+}
+
+func (m *misses) seen(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.tried[name]
+	return ok
+}
+
+// reset lets every name trigger a refetch again, it's called once the cache has been refreshed for going stale
+func (m *misses) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tried = nil
+}
+
+// refetchMissing refetches when refetch on miss is on and the flag isn't cached or overridden locally
+func (c *Client) refetchMissing(name string) error {
+	if !c.refetchOnMiss || c.readOnly || c.misses.seen(name) || c.known(name) {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// another evaluation may have refetched for it while this one waited
+	if !c.misses.try(name) || c.known(name) {
+		return nil
+	}
+
+	return c.doRefetch()
+}
+
+// known reports whether the flag is cached or overridden locally
+func (c *Client) known(name string) bool {
+	if _, ok := c.local(name); ok {
+		return true
+	}
+	_, ok := c.Cache.GetFlag(name)
+	return ok
+}
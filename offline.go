@@ -0,0 +1,38 @@
+package flags
+
+import (
+	"time"
+)
+
+// offlineSyncInterval is how often WithOfflineFirst checks whether the cache is due a sync
+const offlineSyncInterval = 30 * time.Second
+
+// WithOfflineFirst always evaluates from the cache, an evaluation never waits on the API. A stale cache is served
+// while it's synced in the background, and kept being served while the API can't be reached, the cache is also
+// checked every 30 seconds so it's synced without evaluations. Until the first sync only the overrides and whatever
+// SQLite kept from a previous run are served
+func WithOfflineFirst() Option {
+	return func(c *Client) {
+		c.offlineFirst = true
+		c.allowStaleOnError = true
+		if c.refreshInterval <= 0 {
+			c.refreshInterval = offlineSyncInterval
+		}
+	}
+}
+
+// revalidate refetches a stale cache in the background, one at a time, while evaluations carry on with the cache
+func (c *Client) revalidate() {
+	if !c.background.revalidating.CompareAndSwap(false, true) {
+		return
+	}
+
+	if !c.background.goroutine(func() {
+		defer c.background.revalidating.Store(false)
+		if err := c.refreshIfStale(); err != nil {
+			c.reportError(c.errorf("failed to refresh flags in the background: %w", err))
+		}
+	}) {
+		c.background.revalidating.Store(false)
+	}
+}
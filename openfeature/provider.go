@@ -0,0 +1,109 @@
+// Package openfeature is an OpenFeature provider backed by a flags client, register it with the OpenFeature SDK as
+// openfeature.SetProvider(NewProvider(client)). The targeting key of the evaluation context is the evaluation key
+// rollouts are bucketed by
+package openfeature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/flags-gg/go-flags"
+	of "github.com/open-feature/go-sdk/openfeature"
+)
+
+// Provider resolves evaluations against the client, it's an of.FeatureProvider
+type Provider struct {
+	client *flags.Client
+}
+
+var _ of.FeatureProvider = (*Provider)(nil)
+
+func NewProvider(client *flags.Client) *Provider {
+	return &Provider{
+		client: client,
+	}
+}
+
+func (p *Provider) Metadata() of.Metadata {
+	return of.Metadata{Name: "flags.gg"}
+}
+
+// Hooks has no provider hooks
+func (p *Provider) Hooks() []of.Hook {
+	return nil
+}
+
+// BooleanEvaluation gives whether the flag is enabled for the targeting key
+func (p *Provider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, evalCtx of.FlattenedContext) of.BoolResolutionDetail {
+	e, detail, ok := p.evaluate(flag, evalCtx)
+	if !ok {
+		return of.BoolResolutionDetail{Value: defaultValue, ProviderResolutionDetail: detail}
+	}
+	return of.BoolResolutionDetail{Value: e.Enabled, ProviderResolutionDetail: detail}
+}
+
+func (p *Provider) StringEvaluation(ctx context.Context, flag string, defaultValue string, evalCtx of.FlattenedContext) of.StringResolutionDetail {
+	value, detail := resolve(p, flag, defaultValue, evalCtx)
+	return of.StringResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+func (p *Provider) FloatEvaluation(ctx context.Context, flag string, defaultValue float64, evalCtx of.FlattenedContext) of.FloatResolutionDetail {
+	value, detail := resolve(p, flag, defaultValue, evalCtx)
+	return of.FloatResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+func (p *Provider) IntEvaluation(ctx context.Context, flag string, defaultValue int64, evalCtx of.FlattenedContext) of.IntResolutionDetail {
+	value, detail := resolve(p, flag, defaultValue, evalCtx)
+	return of.IntResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+func (p *Provider) ObjectEvaluation(ctx context.Context, flag string, defaultValue interface{}, evalCtx of.FlattenedContext) of.InterfaceResolutionDetail {
+	value, detail := resolve(p, flag, defaultValue, evalCtx)
+	return of.InterfaceResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+// evaluate evaluates the flag once for the targeting key, ok is false when the default has to be used because the
+// flag is unknown or the flags couldn't be refetched
+func (p *Provider) evaluate(flag string, evalCtx of.FlattenedContext) (flags.Evaluation, of.ProviderResolutionDetail, bool) {
+	key, _ := evalCtx[of.TargetingKey].(string)
+	e := p.client.Is(flag).EvaluateFor(key)
+	detail := of.ProviderResolutionDetail{Reason: of.Reason(e.Reason)}
+	switch e.Reason {
+	case flags.ReasonDefault:
+		detail.ResolutionError = of.NewFlagNotFoundResolutionError(fmt.Sprintf("flag %s not found", flag))
+		return e, detail, false
+	case flags.ReasonError:
+		detail.ResolutionError = of.NewGeneralResolutionError(e.Err.Error())
+		return e, detail, false
+	}
+	return e, detail, true
+}
+
+// resolve gives the flags value decoded as a T, the default when it's off, unknown, has no value, or doesn't decode
+// as a T. It's a func rather than a method since methods can't have type parameters
+func resolve[T any](p *Provider, flag string, defaultValue T, evalCtx of.FlattenedContext) (T, of.ProviderResolutionDetail) {
+	e, detail, ok := p.evaluate(flag, evalCtx)
+	if !ok {
+		return defaultValue, detail
+	}
+	if !e.Enabled {
+		detail.Reason = of.DisabledReason
+		return defaultValue, detail
+	}
+
+	if e.Value == "" {
+		return defaultValue, detail
+	}
+	var value T
+	if s, ok := any(&value).(*string); ok {
+		*s = e.Value
+		return value, detail
+	}
+	if err := json.Unmarshal([]byte(e.Value), &value); err != nil {
+		return defaultValue, of.ProviderResolutionDetail{
+			Reason:          of.ErrorReason,
+			ResolutionError: of.NewTypeMismatchResolutionError(fmt.Sprintf("flag %s value %q is not a %T", flag, e.Value, defaultValue)),
+		}
+	}
+	return value, detail
+}
@@ -0,0 +1,126 @@
+package openfeature
+
+import (
+	"context"
+	"fmt"
+	"github.com/flags-gg/go-flags"
+	of "github.com/open-feature/go-sdk/openfeature"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newClient registers a provider for a flags client fetching from handler, and gives an OpenFeature client using it
+func newClient(t *testing.T, handler http.HandlerFunc, opts ...flags.Option) *of.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	opts = append([]flags.Option{flags.WithBaseURL(server.URL), flags.WithMemory(), flags.WithMaxRetries(1), flags.WithoutCircuitBreaker(), flags.WithQuiet(), flags.WithAuth(flags.Auth{
+		ProjectID:     "test-project",
+		AgentID:       "test-agent",
+		EnvironmentID: "test-environment",
+	})}, opts...)
+	client := flags.NewClient(opts...)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	if err := of.SetNamedProviderAndWait(t.Name(), NewProvider(client)); err != nil {
+		t.Fatalf("Failed to set the provider: %v", err)
+	}
+	return of.NewClient(t.Name())
+}
+
+func TestProvider(t *testing.T) {
+	// "in" is in every rollout, anything else in none
+	bucketer := flags.BucketerFunc(func(_, key string) int {
+		if key == "in" {
+			return 0
+		}
+		return 99
+	})
+	client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		response := `{
+			"intervalAllowed": 60,
+			"flags": [
+				{"enabled": true, "details": {"name": "on-flag", "id": "1"}},
+				{"enabled": false, "value": "blue", "details": {"name": "off-flag", "id": "2"}},
+				{"enabled": true, "rollout": 50, "value": "green", "details": {"name": "rollout-flag", "id": "3"}},
+				{"enabled": true, "value": "blue", "details": {"name": "colour", "id": "4"}},
+				{"enabled": true, "value": "2.5", "details": {"name": "ratio", "id": "5"}},
+				{"enabled": true, "value": "42", "details": {"name": "limit", "id": "6"}},
+				{"enabled": true, "value": "{\"size\": 5}", "details": {"name": "config", "id": "7"}}
+			]
+		}`
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, response)
+	}, flags.WithBucketer(bucketer))
+	ctx := context.Background()
+
+	if name := NewProvider(nil).Metadata().Name; name != "flags.gg" {
+		t.Errorf("Expected the provider to be named flags.gg, got %s", name)
+	}
+
+	t.Run("boolean", func(t *testing.T) {
+		tests := []struct {
+			flag      string
+			evalCtx   of.EvaluationContext
+			value     bool
+			reason    of.Reason
+			errorCode of.ErrorCode
+		}{
+			{"on-flag", of.EvaluationContext{}, true, of.CachedReason, ""},
+			{"off-flag", of.EvaluationContext{}, false, of.CachedReason, ""},
+			{"rollout-flag", of.NewEvaluationContext("in", nil), true, of.TargetingMatchReason, ""},
+			{"rollout-flag", of.NewEvaluationContext("out", nil), false, of.TargetingMatchReason, ""},
+			{"rollout-flag", of.EvaluationContext{}, false, of.TargetingMatchReason, ""},
+			{"unknown-flag", of.EvaluationContext{}, true, of.ErrorReason, of.FlagNotFoundCode},
+		}
+		for _, tt := range tests {
+			got, _ := client.BooleanValueDetails(ctx, tt.flag, true, tt.evalCtx)
+			if got.Value != tt.value || got.Reason != tt.reason || got.ErrorCode != tt.errorCode {
+				t.Errorf("%s for %q: expected (%v, %s, %q), got %+v", tt.flag, tt.evalCtx.TargetingKey(), tt.value, tt.reason, tt.errorCode, got)
+			}
+		}
+	})
+
+	t.Run("typed values", func(t *testing.T) {
+		if got, err := client.StringValueDetails(ctx, "colour", "red", of.EvaluationContext{}); got.Value != "blue" || err != nil {
+			t.Errorf("Expected blue, got %+v, %v", got, err)
+		}
+		if got, _ := client.StringValueDetails(ctx, "off-flag", "red", of.EvaluationContext{}); got.Value != "red" || got.Reason != of.DisabledReason {
+			t.Errorf("Expected the default for a disabled flag, got %+v", got)
+		}
+		if got, _ := client.StringValueDetails(ctx, "rollout-flag", "red", of.NewEvaluationContext("in", nil)); got.Value != "green" || got.Reason != of.TargetingMatchReason {
+			t.Errorf("Expected green for a key in the rollout, got %+v", got)
+		}
+		if got, _ := client.StringValueDetails(ctx, "rollout-flag", "red", of.NewEvaluationContext("out", nil)); got.Value != "red" || got.Reason != of.DisabledReason {
+			t.Errorf("Expected the default for a key outside the rollout, got %+v", got)
+		}
+		if got, _ := client.FloatValueDetails(ctx, "ratio", 1, of.EvaluationContext{}); got.Value != 2.5 {
+			t.Errorf("Expected 2.5, got %+v", got)
+		}
+		if got, _ := client.IntValueDetails(ctx, "limit", 1, of.EvaluationContext{}); got.Value != 42 {
+			t.Errorf("Expected 42, got %+v", got)
+		}
+		if got, err := client.IntValueDetails(ctx, "colour", 1, of.EvaluationContext{}); got.Value != 1 || got.ErrorCode != of.TypeMismatchCode || err == nil {
+			t.Errorf("Expected a type mismatch, got %+v, %v", got, err)
+		}
+		got, _ := client.ObjectValueDetails(ctx, "config", nil, of.EvaluationContext{})
+		if config, ok := got.Value.(map[string]interface{}); !ok || config["size"] != float64(5) {
+			t.Errorf("Expected the decoded object, got %+v", got)
+		}
+	})
+}
+
+func TestProvider_Error(t *testing.T) {
+	client := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	got, err := client.BooleanValueDetails(context.Background(), "on-flag", true, of.EvaluationContext{})
+	if err == nil || !got.Value || got.Reason != of.ErrorReason || got.ErrorCode != of.GeneralCode || got.ErrorMessage == "" {
+		t.Errorf("Expected the default with a general error, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,156 @@
+package flags
+
+import (
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// racyWindow is how long after a change to the override dir its mtime can't be trusted, a second change within the
+// filesystems timestamp granularity can leave it the same
+const racyWindow = time.Second
+
+// overrides holds the last local overrides read, so a change to them can be spotted and the eval cache purged
+type overrides struct {
+	mu sync.Mutex
+	// env is the FLAGS_ env vars the env overrides were parsed from
+	env      []string
+	envFlags map[string]bool
+	files    map[string]bool
+	// dirModTime is the mtime of the override dir when files was read at readAt
+	dirModTime time.Time
+	readAt     time.Time
+	// set are the overrides from SetOverride
+	set map[string]bool
+}
+
+// WithPersistentOverrides keeps the overrides from SetOverride in the SQLite cache, so they're still set after a
+// restart until ClearOverrides, e.g. for a long debugging session
+func WithPersistentOverrides() Option {
+	return func(c *Client) {
+		c.persistOverrides = true
+	}
+}
+
+// loadOverrides reads the overrides kept by WithPersistentOverrides
+func (c *Client) loadOverrides() error {
+	stored, err := c.Cache.Overrides()
+	if err != nil {
+		return err
+	}
+
+	o := c.overrides
+	o.mu.Lock()
+	o.set = stored
+	o.mu.Unlock()
+	return nil
+}
+
+// SetOverride overrides the flag for this client only, e.g. for a test exercising a gated path without setting
+// FLAGS_ env vars that leak into other tests. It wins over the cache, but an env var or override file for the flag
+// still wins over it. With WithPersistentOverrides it's stored too, a failure to store it is reported
+func (c *Client) SetOverride(name string, enabled bool) {
+	name = strings.ToLower(name)
+	o := c.overrides
+	o.mu.Lock()
+	if o.set == nil {
+		o.set = make(map[string]bool)
+	}
+	o.set[name] = enabled
+	o.mu.Unlock()
+
+	if c.persistOverrides {
+		if err := c.Cache.StoreOverride(name, enabled); err != nil {
+			c.reportError(c.errorf("%w: failed to store override: %w", ErrCacheUnavailable, err))
+		}
+	}
+
+	c.evalCache.purge()
+	c.watchers.notify(c)
+}
+
+// ClearOverrides removes every override from SetOverride, so the flags evaluate from the cache again, including the
+// ones stored by WithPersistentOverrides
+func (c *Client) ClearOverrides() {
+	o := c.overrides
+	o.mu.Lock()
+	o.set = nil
+	o.mu.Unlock()
+
+	if c.persistOverrides {
+		if err := c.Cache.ClearOverrides(); err != nil {
+			c.reportError(c.errorf("%w: failed to clear stored overrides: %w", ErrCacheUnavailable, err))
+		}
+	}
+
+	c.evalCache.purge()
+	c.watchers.notify(c)
+}
+
+// setOverride gives the override from SetOverride for the already lowercased flag
+func (o *overrides) setOverride(name string) (bool, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	enabled, ok := o.set[name]
+	return enabled, ok
+}
+
+// localFlags gives the overrides from the override files and the env vars, when either has changed since the last
+// call the eval cache is purged so no evaluation is memoized from the old overrides
+func (c *Client) localFlags() (map[string]bool, map[string]bool) {
+	env := envOverrides()
+
+	o := c.overrides
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	changed := false
+	if !slices.Equal(env, o.env) || o.envFlags == nil {
+		o.env = env
+		o.envFlags = buildLocal(env)
+		changed = true
+	}
+	if c.overrideDir != "" && o.dirChanged(c.overrideDir) {
+		files := c.buildFileLocal(c.overrideDir)
+		if !maps.Equal(files, o.files) {
+			o.files = files
+			changed = true
+		}
+	}
+	if changed {
+		c.evalCache.purge()
+	}
+	return o.files, o.envFlags
+}
+
+// envOverrides gives the FLAGS_ env vars, in the order the environment has them
+func envOverrides() []string {
+	var env []string
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, "FLAGS_") {
+			env = append(env, e)
+		}
+	}
+	return env
+}
+
+// dirChanged reports whether the override dir needs reading again, only a change to its mtime means it has, unless
+// the last read was too soon after the mtime to rule out another change since. It expects the caller to hold the mutex
+func (o *overrides) dirChanged(dir string) bool {
+	info, err := os.Stat(dir)
+	if err != nil {
+		// read it anyway, so the error is reported
+		o.dirModTime, o.readAt = time.Time{}, time.Time{}
+		return true
+	}
+
+	if info.ModTime().Equal(o.dirModTime) && o.readAt.Sub(o.dirModTime) > racyWindow {
+		return false
+	}
+	o.dirModTime, o.readAt = info.ModTime(), time.Now()
+	return true
+}
@@ -0,0 +1,25 @@
+package flags
+
+import (
+	"context"
+)
+
+// Ping does a single authenticated fetch to check the endpoint is reachable and the auth is accepted,
+// it doesn't retry, ignores the circuit breaker, and doesn't touch the cache
+func (c *Client) Ping(ctx context.Context) error {
+	// the auth is only read under the lock
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if _, err := c.transport.Fetch(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WithPingOnStart pings the API when the client is created, NewClient returns nil if it fails
+func WithPingOnStart() Option {
+	return func(c *Client) {
+		c.pingOnStart = true
+	}
+}
@@ -0,0 +1,104 @@
+package flags
+
+import (
+	"time"
+)
+
+// Reason is why an evaluation gave the value it did, the codes are OpenFeature's
+type Reason string
+
+const (
+	// ReasonStatic is a flag fixed by WithStaticFlag, or turned off by the kill switch whatever it's set to
+	ReasonStatic Reason = "STATIC"
+	// ReasonCached is a flag read from the cached flag set
+	ReasonCached Reason = "CACHED"
+	// ReasonDefault is a flag that isn't known, so it's off
+	ReasonDefault Reason = "DEFAULT"
+	// ReasonError is a flag evaluated without the cache since the flags couldn't be refetched
+	ReasonError Reason = "ERROR"
+	// ReasonTargetingMatch is a cached flag its rollout or active window decided
+	ReasonTargetingMatch Reason = "TARGETING_MATCH"
+	// ReasonLocalOverride is a flag set by an override file, an env var, or SetOverride
+	ReasonLocalOverride Reason = "LOCAL_OVERRIDE"
+)
+
+// EvaluateDetailed evaluates the flag like Enabled, it also gives the reason for the value, and why the flags
+// couldn't be refetched when the reason is ReasonError
+func (f *Flag) EvaluateDetailed() (bool, Reason, error) {
+	e := f.EvaluateFor("")
+	return e.Enabled, e.Reason, e.Err
+}
+
+// Evaluation is what one evaluation of a flag gave, Value is its string value as String gives it, and Err why the
+// flags couldn't be refetched when Reason is ReasonError
+type Evaluation struct {
+	Enabled bool
+	Value   string
+	Reason  Reason
+	Err     error
+}
+
+// EvaluateFor evaluates the flag once for the evaluation key (e.g. the user ID), so the value and the reason come from
+// the same evaluation rather than one each. A rollout's reason is ReasonTargetingMatch whichever way the key falls
+func (f *Flag) EvaluateFor(key string) Evaluation {
+	c := f.Client
+	name := f.name()
+	enabled, fallback, err := c.evaluateAt(name, key, time.Time{})
+	name = c.canonical(name)
+	e := Evaluation{Enabled: enabled, Reason: c.reason(name, fallback, err), Err: err}
+	if !enabled {
+		return e
+	}
+
+	if featureFlag, ok := c.Cache.GetFlag(name); ok {
+		e.Value = featureFlag.Value
+		if c.interpolation {
+			e.Value = interpolate(e.Value)
+		}
+	}
+	return e
+}
+
+// Reason evaluates the flag and gives the OpenFeature reason code for the value, e.g. for an OpenFeature provider
+// built on the client. EvaluateDetailed gives the value and the reason from the same evaluation
+func (f *Flag) Reason() string {
+	_, reason, _ := f.EvaluateDetailed()
+	return string(reason)
+}
+
+// reason gives why the already lowercased flag evaluated as it did, fallback is whether it was evaluated without
+// the cache and err why it was
+func (c *Client) reason(name string, fallback bool, err error) Reason {
+	if _, ok := c.static(name); ok {
+		return ReasonStatic
+	}
+	if fallback {
+		if c.killSwitch != "" && name != c.killSwitch {
+			if enabled, _ := c.local(c.killSwitch); !enabled {
+				return ReasonStatic
+			}
+		}
+		if _, ok := c.local(name); ok {
+			return ReasonLocalOverride
+		}
+		if err != nil {
+			return ReasonError
+		}
+		return ReasonDefault
+	}
+
+	if c.killSwitch != "" && name != c.killSwitch && !c.lookup(c.killSwitch) {
+		return ReasonStatic
+	}
+	if _, ok := c.local(name); ok {
+		return ReasonLocalOverride
+	}
+	featureFlag, ok := c.Cache.GetFlag(name)
+	if !ok {
+		return ReasonDefault
+	}
+	if featureFlag.Rollout != nil || featureFlag.Scheduled() {
+		return ReasonTargetingMatch
+	}
+	return ReasonCached
+}
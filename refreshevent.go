@@ -0,0 +1,87 @@
+package flags
+
+import (
+	"github.com/flags-gg/go-flags/flag"
+	"slices"
+	"time"
+)
+
+// RefreshEvent is the outcome of one refresh of the cache, from a refetch or a WebSocket push
+type RefreshEvent struct {
+	// Source is where the flags came from, "http", "grpc", "websocket", or "payload"
+	Source string
+	// Flags is how many flags were cached, Changed how many of them were added, changed, or removed since the last
+	// refresh
+	Flags   int
+	Changed int
+	// Duration is how long the refresh took, including retries
+	Duration time.Duration
+	// Err is why the refresh failed, nil when it succeeded. It matches ErrCircuitOpen when the failure opened the
+	// circuit breaker, or the refetch was skipped because it's open
+	Err error
+}
+
+// WithRefreshHandler is called at the end of every refresh, successful or not, so each one can be logged or
+// counted in one place. It's called with the refetch lock held, so it shouldn't evaluate flags
+func WithRefreshHandler(fn func(RefreshEvent)) Option {
+	return func(c *Client) {
+		c.refreshHandler = fn
+	}
+}
+
+func (c *Client) reportRefresh(event RefreshEvent) {
+	if c.refreshHandler == nil {
+		return
+	}
+	c.refreshHandler(event)
+}
+
+// flagChanges gives the flags that are new, different, or gone in flags compared to previous, as changed at
+func flagChanges(previous map[string]flag.FeatureFlag, flags []flag.FeatureFlag, at time.Time) []FlagChange {
+	var changes []FlagChange
+	seen := make(map[string]struct{}, len(flags))
+	for _, f := range flags {
+		seen[f.Details.Name] = struct{}{}
+		p, ok := previous[f.Details.Name]
+		if ok && sameFlag(p, f) {
+			continue
+		}
+
+		change := FlagChange{Name: f.Details.Name, Enabled: f.Enabled, ChangedAt: at, New: &f}
+		if ok {
+			change.Old = &p
+		}
+		changes = append(changes, change)
+	}
+	for name, p := range previous {
+		if _, ok := seen[name]; !ok {
+			changes = append(changes, FlagChange{Name: name, ChangedAt: at, Old: &p})
+		}
+	}
+	return changes
+}
+
+// sameFlag compares flags as the cache stores them, times to the second and no tags the same as empty tags
+func sameFlag(a, b flag.FeatureFlag) bool {
+	return a.Enabled == b.Enabled &&
+		a.Details == b.Details &&
+		a.Value == b.Value &&
+		sameInt(a.Rollout, b.Rollout) &&
+		sameTime(a.ActiveFrom, b.ActiveFrom) &&
+		sameTime(a.ActiveUntil, b.ActiveUntil) &&
+		slices.Equal(a.Tags, b.Tags)
+}
+
+func sameInt(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Unix() == b.Unix()
+}
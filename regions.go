@@ -0,0 +1,108 @@
+package flags
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// regionProbeInterval is how long a regions latency is trusted, past it the region is fetched from again to
+	// measure it
+	regionProbeInterval = 5 * time.Minute
+	// regionCooldown is how long a region that failed is skipped
+	regionCooldown = 30 * time.Second
+)
+
+// Region is an endpoint the flags API is served from
+type Region struct {
+	Name    string
+	BaseURL string
+}
+
+// WithRegions fetches from whichever of the regions has been answering fastest, rather than one base URL. Each is
+// fetched from first to measure it, and again every few minutes so a region that has sped up is noticed. A region
+// that fails, or answers with a server error, is skipped for a while. It wins over WithBaseURL and WithRegion
+func WithRegions(regions []Region) Option {
+	return func(c *Client) {
+		if len(regions) == 0 {
+			c.regionSet = nil
+			return
+		}
+		set := &regionSet{}
+		for _, r := range regions {
+			set.regions = append(set.regions, regionState{Region: r})
+		}
+		c.regionSet = set
+	}
+}
+
+// regionSet tracks the latency and health of each region, it's shared by the clients copied with WithAuth since the
+// latency to a region doesn't depend on the auth
+type regionSet struct {
+	mu      sync.Mutex
+	regions []regionState
+}
+
+type regionState struct {
+	Region
+	// latency is a moving average of how long the region takes to answer
+	latency    time.Duration
+	measuredAt time.Time
+	failedAt   time.Time
+}
+
+// pick gives the index of the region to fetch from, one that needs measuring first, then the fastest healthy one.
+// When every region has failed recently it's the one that failed longest ago
+func (s *regionSet) pick(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	best := -1
+	for i, r := range s.regions {
+		if !r.failedAt.IsZero() && now.Sub(r.failedAt) < regionCooldown {
+			continue
+		}
+		if r.measuredAt.IsZero() || now.Sub(r.measuredAt) > regionProbeInterval {
+			return i
+		}
+		if best == -1 || r.latency < s.regions[best].latency {
+			best = i
+		}
+	}
+	if best != -1 {
+		return best
+	}
+
+	best = 0
+	for i, r := range s.regions {
+		if r.failedAt.Before(s.regions[best].failedAt) {
+			best = i
+		}
+	}
+	return best
+}
+
+func (s *regionSet) baseURL(i int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.regions[i].BaseURL
+}
+
+// record notes how long the region took to answer, or that it failed
+func (s *regionSet) record(i int, d time.Duration, failed bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &s.regions[i]
+	if failed {
+		r.failedAt = now
+		return
+	}
+	r.failedAt = time.Time{}
+	if r.measuredAt.IsZero() {
+		r.latency = d
+	} else {
+		r.latency = (r.latency*7 + d*3) / 10
+	}
+	r.measuredAt = now
+}
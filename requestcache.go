@@ -0,0 +1,52 @@
+package flags
+
+import (
+	"context"
+	"sync"
+)
+
+// requestCacheKey is per client, so clients sharing a context don't share values
+type requestCacheKey struct {
+	client *Client
+}
+
+// requestCache holds the evaluations made with one context, keyed by flag name and evaluation key
+type requestCache struct {
+	mu     sync.Mutex
+	values map[string]bool
+}
+
+// WithRequestCache gives a context that remembers each EnabledCtx evaluation made with it, so checking the same flag
+// again (e.g. several times in one HTTP request) doesn't go back to the cache. The values are fixed for the life of the
+// context, even if the flags are refreshed, so it should be scoped to a single request
+func (c *Client) WithRequestCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestCacheKey{client: c}, &requestCache{
+		values: make(map[string]bool),
+	})
+}
+
+func (c *Client) requestCache(ctx context.Context) *requestCache {
+	rc, _ := ctx.Value(requestCacheKey{client: c}).(*requestCache)
+	return rc
+}
+
+func (r *requestCache) get(name, key string) (bool, bool) {
+	if r == nil {
+		return false, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enabled, ok := r.values[name+"\x00"+key]
+	return enabled, ok
+}
+
+func (r *requestCache) put(name, key string, enabled bool) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[name+"\x00"+key] = enabled
+}
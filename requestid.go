@@ -0,0 +1,21 @@
+package flags
+
+import (
+	"github.com/google/uuid"
+)
+
+// WithRequestIDGenerator replaces how the X-Request-ID of each fetch is made, by default it's a random UUID
+func WithRequestIDGenerator(fn func() string) Option {
+	return func(c *Client) {
+		c.requestIDGenerator = fn
+	}
+}
+
+// requestID is the X-Request-ID for the next fetch, it's in any error from that fetch so it can be correlated with
+// the server logs
+func (c *Client) requestID() string {
+	if c.requestIDGenerator == nil {
+		return uuid.NewString()
+	}
+	return c.requestIDGenerator()
+}
@@ -0,0 +1,136 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// fetchFunc is anything that gives the flag set, normally transport.Fetch
+type fetchFunc func(ctx context.Context) (*ApiResponse, error)
+
+// retrier holds the retry, backoff and circuit breaker policy, kept apart from the flag logic so any fetch can use it
+type retrier struct {
+	maxAttempts int
+	// maxDuration caps the total time spent retrying, zero means no cap
+	maxDuration time.Duration
+	circuit     *CircuitState
+	// noCircuit never opens the circuit, every call retries per the policy however many have failed before
+	noCircuit bool
+	// cooldown is how long the circuit stays open the first time, it doubles each time it opens again without a
+	// success in between, up to maxCooldown, so a long outage is tried less and less often
+	cooldown    time.Duration
+	maxCooldown time.Duration
+	// backoff is how long to wait after the given (zero based) attempt fails
+	backoff func(attempt int) time.Duration
+
+	now     func() time.Time
+	onError func(error)
+	errorf  func(format string, args ...interface{}) error
+}
+
+func linearBackoff(attempt int) time.Duration {
+	return time.Duration(attempt+1) * time.Second
+}
+
+// retrier gives the clients policy, it uses the clients circuit so it must be used with the mutex held
+func (c *Client) retrier() *retrier {
+	return &retrier{
+		maxAttempts: c.maxRetries,
+		maxDuration: c.maxRetryDuration,
+		circuit:     &c.circuitState,
+		noCircuit:   c.noCircuitBreaker,
+		cooldown:    10 * time.Second,
+		maxCooldown: 5 * time.Minute,
+		backoff:     linearBackoff,
+		now:         time.Now,
+		onError:     c.reportError,
+		errorf:      c.errorf,
+	}
+}
+
+// openFor is how long the circuit stays open after it has opened trips times in a row
+func (r *retrier) openFor(trips int) time.Duration {
+	d := r.cooldown
+	for i := 1; i < trips; i++ {
+		if r.maxCooldown > 0 && d >= r.maxCooldown {
+			break
+		}
+		d *= 2
+	}
+	if r.maxCooldown > 0 && d > r.maxCooldown {
+		return r.maxCooldown
+	}
+	return d
+}
+
+// do calls fetch until it succeeds, the attempts run out, or ctx is done. Each failure is passed to onError, once
+// the circuit has seen maxAttempts failures in a row it opens, that failure is passed on and returned wrapped in
+// ErrCircuitOpen, and ErrCircuitOpen is returned until it's been open for openFor. A success closes it and resets the trips. With
+// noCircuit the last failure is returned instead. A circuit opened by TripCircuit always returns ErrCircuitOpen
+func (r *retrier) do(ctx context.Context, fetch fetchFunc) (*ApiResponse, error) {
+	if r.circuit.tripped {
+		return nil, ErrCircuitOpen
+	}
+	if r.circuit.isOpen {
+		if r.now().Sub(r.circuit.lastFailure) < r.openFor(r.circuit.trips) {
+			return nil, ErrCircuitOpen
+		}
+		r.circuit.isOpen = false
+		r.circuit.failureCount = 0
+	}
+
+	if r.maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.maxDuration)
+		defer cancel()
+	}
+
+	var err error
+	for attempt := 0; attempt < r.maxAttempts; attempt++ {
+		var resp *ApiResponse
+		resp, err = fetch(ctx)
+		if err == nil {
+			r.circuit.failureCount = 0
+			r.circuit.trips = 0
+			return resp, nil
+		}
+		if !r.noCircuit {
+			r.circuit.failureCount++
+			if r.circuit.failureCount >= r.maxAttempts {
+				r.circuit.isOpen = true
+				r.circuit.lastFailure = r.now()
+				r.circuit.trips++
+				// the failure that opens the circuit is reported and returned as opening it
+				err = r.errorf("%w after %d failures: %w", ErrCircuitOpen, r.circuit.failureCount, err)
+				r.report(err)
+				return nil, err
+			}
+		}
+		r.report(err)
+		if r.noCircuit && attempt == r.maxAttempts-1 {
+			break // no point backing off after the last attempt
+		}
+
+		timer := time.NewTimer(r.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, r.errorf("refetch cancelled: %w", err)
+		}
+		if ctx.Err() != nil {
+			return nil, r.errorf("retry time of %s exceeded: %w", r.maxDuration, err)
+		}
+	}
+
+	return nil, err
+}
+
+func (r *retrier) report(err error) {
+	if r.onError != nil {
+		r.onError(err)
+	}
+}
@@ -0,0 +1,119 @@
+package flags
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/flags-gg/go-flags/flag"
+	"time"
+)
+
+// WithEvaluationKeyFromContext gives the evaluation key (e.g. the user ID) for EnabledCtx,
+// so middleware can set it on the context once rather than passing it to every check
+func WithEvaluationKeyFromContext(fn func(context.Context) string) Option {
+	return func(c *Client) {
+		c.evaluationKey = fn
+	}
+}
+
+// EnabledFor evaluates the flag for the evaluation key, the same key always lands in the same rollout bucket
+func (f *Flag) EnabledFor(key string) bool {
+	return f.Client.isEnabledFor(f.name(), key)
+}
+
+// EnabledCtx evaluates the flag for the evaluation key carried by the context, if the context is from
+// WithRequestCache the flag is only evaluated the first time
+func (f *Flag) EnabledCtx(ctx context.Context) bool {
+	key := ""
+	if f.Client.evaluationKey != nil {
+		key = f.Client.evaluationKey(ctx)
+	}
+
+	rc := f.Client.requestCache(ctx)
+	name := f.Client.canonical(f.name())
+	if enabled, ok := rc.get(name, key); ok {
+		f.Client.usage.record(name)
+		return enabled
+	}
+
+	enabled := f.Client.isEnabledAt(ctx, name, key, time.Time{})
+	rc.put(name, key, enabled)
+	return enabled
+}
+
+// WithStickyRollouts remembers every key a rollout has enabled a flag for, so lowering the percentage doesn't
+// turn it back off for them. The keys are kept in the cache, SQLite keeps them across restarts
+func WithStickyRollouts() Option {
+	return func(c *Client) {
+		c.stickyRollouts = true
+	}
+}
+
+// rollout applies the rollout percentage, with sticky rollouts a key that has been enabled once stays enabled
+func (c *Client) rollout(f flag.FeatureFlag, key string) bool {
+	if !c.stickyRollouts || key == "" || !f.Enabled || f.Rollout == nil {
+		return c.rolloutEnabled(f, key)
+	}
+
+	stuck, err := c.Cache.Stuck(f.Details.Name, key)
+	if err != nil {
+		c.reportError(err)
+	}
+	if stuck {
+		return true
+	}
+
+	if !c.rolloutEnabled(f, key) {
+		return false
+	}
+	if !c.readOnly {
+		if err := c.Cache.Stick(f.Details.Name, key); err != nil {
+			c.reportError(err)
+		}
+	}
+	return true
+}
+
+// rolloutEnabled applies the rollout percentage, without an evaluation key a partial rollout is treated as off
+func (c *Client) rolloutEnabled(f flag.FeatureFlag, key string) bool {
+	if !f.Enabled {
+		return false
+	}
+	if f.Rollout == nil {
+		return true
+	}
+	if key == "" {
+		return *f.Rollout >= 100
+	}
+
+	return c.bucketer.Bucket(f.Details.Name, key) < *f.Rollout
+}
+
+// Bucketer places an evaluation key for a flag in one of 100 buckets (0-99), a key is in a rollout of n percent
+// when its bucket is below n. It must always give the same bucket for the same flag and key
+type Bucketer interface {
+	Bucket(flagName, key string) int
+}
+
+// BucketerFunc lets a plain function be used as a Bucketer
+type BucketerFunc func(flagName, key string) int
+
+func (f BucketerFunc) Bucket(flagName, key string) int {
+	return f(flagName, key)
+}
+
+// SHA256Bucketer is the default Bucketer, the bucket is the first 4 bytes of sha256("flagName:key") read as a
+// big endian uint32, modulo 100
+type SHA256Bucketer struct{}
+
+func (SHA256Bucketer) Bucket(flagName, key string) int {
+	sum := sha256.Sum256([]byte(flagName + ":" + key))
+	return int(binary.BigEndian.Uint32(sum[:4]) % 100)
+}
+
+// WithBucketer replaces how keys are bucketed for rollouts, e.g. to match the bucketing of another SDK
+func WithBucketer(b Bucketer) Option {
+	return func(c *Client) {
+		c.bucketer = b
+	}
+}
@@ -0,0 +1,24 @@
+package flags
+
+import (
+	"context"
+	"time"
+)
+
+// WithClock sets what the client takes the time to be when checking a flags active window, defaults to time.Now
+//
+// A window only narrows an enabled flag, so a flag is on when it's enabled, inside its window, and (with a rollout)
+// in the rollout for the key. A disabled flag stays off inside its window, and a flag outside its window is off
+// the same as an unknown flag would be. Local overrides from env vars or override files don't have a window and
+// always win.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		c.now = now
+	}
+}
+
+// EnabledAt evaluates the flag as it would be at t rather than now, e.g. to check when a scheduled launch goes live.
+// Only its active window is checked at t, whether it's enabled, overridden, or rolled out is as it is now
+func (f *Flag) EnabledAt(t time.Time) bool {
+	return f.Client.isEnabledAt(context.Background(), f.name(), "", t)
+}
@@ -0,0 +1,41 @@
+package flags
+
+import (
+	"time"
+)
+
+// WithSoftTTL smooths refetches when the cache has only just gone stale. For d past the TTL the API asked for,
+// evaluations keep serving the cached flags and only a sample of them refetch, the sample grows from none to every
+// evaluation across the window. Past the window every evaluation refetches as usual. Background refreshes, Status
+// and Ping aren't sampled
+func WithSoftTTL(d time.Duration) Option {
+	return func(c *Client) {
+		c.softTTL = d
+	}
+}
+
+// softWindow is how far through the soft TTL window the cache is, from 0 to 1, ok is false outside the window
+func (c *Client) softWindow() (float64, bool) {
+	if c.softTTL <= 0 {
+		return 0, false
+	}
+
+	nextRefresh, ok := c.Cache.NextRefresh()
+	if !ok {
+		return 0, false
+	}
+	past := time.Since(nextRefresh)
+	if past < 0 || past >= c.softTTL {
+		return 0, false
+	}
+	return float64(past) / float64(c.softTTL), true
+}
+
+// skipRefetch reports whether this evaluation was left out of the soft TTL sample, it expects the cache to be stale
+func (c *Client) skipRefetch() bool {
+	through, ok := c.softWindow()
+	if !ok {
+		return false
+	}
+	return c.sample() >= through
+}
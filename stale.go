@@ -0,0 +1,32 @@
+package flags
+
+// WithAllowStaleOnError keeps serving the last fetched flags when the cache is past its TTL and can't be refetched,
+// e.g. during an API outage. Without it a stale cache isn't used, flags are only what the override files and env
+// vars say and everything else is off until a refetch succeeds
+func WithAllowStaleOnError() Option {
+	return func(c *Client) {
+		c.allowStaleOnError = true
+	}
+}
+
+// stale reports whether the cache is past its TTL and soft TTL, a read only client never refetches so its cache is
+// never stale
+func (c *Client) stale() bool {
+	if c.readOnly || !c.Cache.ShouldRefreshCache() {
+		return false
+	}
+	_, soft := c.softWindow()
+	return !soft
+}
+
+// localValue evaluates the already lowercased flag without the cache, a kill switch that isn't set locally is off
+func (c *Client) localValue(name string) bool {
+	if c.killSwitch != "" && name != c.killSwitch {
+		if enabled, _ := c.local(c.killSwitch); !enabled {
+			return false
+		}
+	}
+
+	enabled, _ := c.local(name)
+	return enabled
+}
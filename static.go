@@ -0,0 +1,23 @@
+package flags
+
+import (
+	"strings"
+)
+
+// WithStaticFlag fixes the flag to value for this build, e.g. one that's hard coded on or off. It's evaluated before
+// anything else, so it's never refetched, looked up in the cache, or overridden by an override file, env var,
+// SetOverride, or the kill switch. Unlike a default it applies whether the API has the flag or not
+func WithStaticFlag(name string, value bool) Option {
+	return func(c *Client) {
+		if c.staticFlags == nil {
+			c.staticFlags = make(map[string]bool)
+		}
+		c.staticFlags[strings.ToLower(name)] = value
+	}
+}
+
+// static gives the value of the already lowercased flag if it's static
+func (c *Client) static(name string) (bool, bool) {
+	enabled, ok := c.staticFlags[name]
+	return enabled, ok
+}
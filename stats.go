@@ -0,0 +1,77 @@
+package flags
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Stats about the flag fetches made by the client
+type Stats struct {
+	// Fetches is how many payloads have been downloaded
+	Fetches int64
+	// LastPayloadBytes is the size of the last payload as it came over the wire
+	LastPayloadBytes int64
+	// LastDecompressedBytes is the size of the last payload once decompressed, the same as LastPayloadBytes when it wasn't compressed
+	LastDecompressedBytes int64
+	// TotalPayloadBytes is the wire size of every payload downloaded
+	TotalPayloadBytes int64
+	// LastRetryDuration is how long the last refetch spent, including every retry
+	LastRetryDuration time.Duration
+	// LastProtocol is the protocol the last flags request was made with, e.g. HTTP/2.0 or HTTP/1.1
+	LastProtocol string
+	// LastConnectionReused is whether the last flags request was made on a connection kept alive from an earlier one
+	LastConnectionReused bool
+	// DroppedChanges is how many changes weren't sent to a Subscribe channel because it was full
+	DroppedChanges int64
+}
+
+type fetchStats struct {
+	fetches               atomic.Int64
+	lastPayloadBytes      atomic.Int64
+	lastDecompressedBytes atomic.Int64
+	totalPayloadBytes     atomic.Int64
+	lastRetryDuration     atomic.Int64
+	droppedChanges        atomic.Int64
+	lastProtocol          atomic.Value
+	lastConnectionReused  atomic.Bool
+}
+
+func (s *fetchStats) record(payloadBytes, decompressedBytes int64) {
+	s.fetches.Add(1)
+	s.lastPayloadBytes.Store(payloadBytes)
+	s.lastDecompressedBytes.Store(decompressedBytes)
+	s.totalPayloadBytes.Add(payloadBytes)
+}
+
+func (s *fetchStats) recordConnection(protocol string, reused bool) {
+	s.lastProtocol.Store(protocol)
+	s.lastConnectionReused.Store(reused)
+}
+
+// Stats gives the payload stats for the client
+func (c *Client) Stats() Stats {
+	protocol, _ := c.stats.lastProtocol.Load().(string)
+	return Stats{
+		Fetches:               c.stats.fetches.Load(),
+		LastPayloadBytes:      c.stats.lastPayloadBytes.Load(),
+		LastDecompressedBytes: c.stats.lastDecompressedBytes.Load(),
+		TotalPayloadBytes:     c.stats.totalPayloadBytes.Load(),
+		LastRetryDuration:     time.Duration(c.stats.lastRetryDuration.Load()),
+		LastProtocol:          protocol,
+		LastConnectionReused:  c.stats.lastConnectionReused.Load(),
+		DroppedChanges:        c.stats.droppedChanges.Load(),
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
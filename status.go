@@ -0,0 +1,57 @@
+package flags
+
+// FlagStatus tells apart a disabled flag from one that isn't known at all
+type FlagStatus int
+
+const (
+	StatusUnknown FlagStatus = iota
+	StatusEnabled
+	StatusDisabled
+)
+
+func (s FlagStatus) String() string {
+	switch s {
+	case StatusEnabled:
+		return "enabled"
+	case StatusDisabled:
+		return "disabled"
+	default:
+		return "unknown"
+	}
+}
+
+// Status gives whether the flag is enabled, disabled, or unknown to both the server and the local overrides. Like an
+// evaluation, a stale cache that can't be refetched (e.g. the circuit breaker is open) is only used with
+// WithAllowStaleOnError, otherwise the status is from the local overrides alone
+func (c *Client) Status(name string) (FlagStatus, error) {
+	name = c.canonical(name)
+	if enabled, ok := c.static(name); ok {
+		return status(true, enabled), nil
+	}
+
+	if err := c.refreshIfStale(); err != nil {
+		return StatusUnknown, err
+	}
+
+	if c.stale() && !c.allowStaleOnError {
+		_, exists := c.local(name)
+		return status(exists, c.localValue(name)), nil
+	}
+
+	enabled, exists, _ := c.resolve(name, "")
+	if c.killSwitch != "" && name != c.killSwitch && !c.lookup(c.killSwitch) {
+		enabled = false
+	}
+	return status(exists, enabled), nil
+}
+
+func status(exists, enabled bool) FlagStatus {
+	switch {
+	case !exists:
+		return StatusUnknown
+	case !enabled:
+		return StatusDisabled
+	default:
+		return StatusEnabled
+	}
+}
@@ -0,0 +1,83 @@
+package flags
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// cacheDirName is the directory the cache is kept in under the user cache dir or the temp dir
+const cacheDirName = "flags-gg"
+
+// WithPathResolver picks the directory the SQLite cache is kept in when SetFileName isn't used, it's created if it
+// doesn't exist. By default it's flags-gg in the user cache dir ($XDG_CACHE_HOME, ~/Library/Caches, %LocalAppData%),
+// or in the temp dir when there's no user cache dir or it can't be written to
+func WithPathResolver(fn func() (string, error)) Option {
+	return func(c *Client) {
+		c.pathResolver = fn
+	}
+}
+
+// WithCacheDir keeps the SQLite cache in dir, creating it with 0700 permissions if it doesn't exist. The file is
+// named for the auth like the default, and is only readable and writable by its owner. SetFileName wins over it
+func WithCacheDir(dir string) Option {
+	return func(c *Client) {
+		c.pathResolver = func() (string, error) {
+			return dir, nil
+		}
+		c.restrictCacheFile = true
+	}
+}
+
+// restrictFile creates the cache file before SQLite does so it's never readable by anyone but its owner, one already
+// there is restricted too. SQLite gives the -wal and -shm files the same permissions
+func restrictFile(name string) error {
+	f, err := os.OpenFile(name, os.O_RDONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create the cache file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close the cache file: %w", err)
+	}
+	if err := os.Chmod(name, 0o600); err != nil {
+		return fmt.Errorf("failed to restrict the cache file: %w", err)
+	}
+	return nil
+}
+
+// defaultCacheDir is the default path resolver, it's only an error when neither dir can be created
+func defaultCacheDir() (string, error) {
+	if dir, err := os.UserCacheDir(); err == nil {
+		dir = filepath.Join(dir, cacheDirName)
+		if err := os.MkdirAll(dir, 0o700); err == nil {
+			return dir, nil
+		}
+	}
+
+	dir := filepath.Join(os.TempDir(), cacheDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create the cache dir: %w", err)
+	}
+	return dir, nil
+}
+
+// cacheFileName is where the cache goes when SetFileName isn't used, it's per auth so services for different
+// environments on the same host don't share a cache
+func (c *Client) cacheFileName() (string, error) {
+	resolve := c.pathResolver
+	if resolve == nil {
+		resolve = defaultCacheDir
+	}
+	dir, err := resolve()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create the cache dir: %w", err)
+	}
+
+	if c.auth == (Auth{}) {
+		return filepath.Join(dir, "flags.db"), nil
+	}
+	return filepath.Join(dir, fmt.Sprintf("flags-%s.db", c.auth.hash())), nil
+}
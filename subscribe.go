@@ -0,0 +1,92 @@
+package flags
+
+import (
+	"sync"
+)
+
+// subscriberBuffer is how many changes a subscriber can fall behind by before changes to it are dropped
+const subscriberBuffer = 64
+
+// subscribers are the Subscribe channels of a client, they're sent every change after each refresh
+type subscribers struct {
+	mu     sync.Mutex
+	subs   map[chan FlagChange]struct{}
+	closed bool
+}
+
+// Subscribe gives a channel with every flag change from each refresh, a flag that's added has no Old and one that's
+// removed has no New. The channel is closed by the cancel func or Client.Close. A subscriber that falls more than 64
+// changes behind misses the changes that don't fit, they're counted in Stats().DroppedChanges
+func (c *Client) Subscribe() (<-chan FlagChange, func()) {
+	ch := make(chan FlagChange, subscriberBuffer)
+	if !c.subscribers.add(ch) {
+		close(ch)
+		return ch, func() {}
+	}
+	return ch, func() {
+		c.subscribers.remove(ch)
+	}
+}
+
+func (s *subscribers) add(ch chan FlagChange) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if s.subs == nil {
+		s.subs = make(map[chan FlagChange]struct{})
+	}
+	s.subs[ch] = struct{}{}
+	return true
+}
+
+func (s *subscribers) remove(ch chan FlagChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subs[ch]; !ok {
+		return
+	}
+	delete(s.subs, ch)
+	close(ch)
+}
+
+// any reports whether there's a subscriber, so the changes are only worked out when someone wants them
+func (s *subscribers) any() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.subs) > 0
+}
+
+// publish sends the changes to every subscriber without waiting on them, it gives how many sends were dropped
+func (s *subscribers) publish(changes []FlagChange) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var dropped int64
+	for ch := range s.subs {
+		for _, change := range changes {
+			select {
+			case ch <- change:
+			default:
+				dropped++
+			}
+		}
+	}
+	return dropped
+}
+
+// close closes every subscribers channel, nothing can subscribe afterwards
+func (s *subscribers) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subs {
+		close(ch)
+	}
+	s.subs = nil
+	s.closed = true
+}
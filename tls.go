@@ -0,0 +1,41 @@
+package flags
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+)
+
+// WithClientCertificate presents cert to an API that requires mutual TLS, the WebSocket handshake presents it too.
+// It can be given more than once, the server picks the one it accepts
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(c *Client) {
+		config := c.tlsConfig()
+		config.Certificates = append(config.Certificates, cert)
+	}
+}
+
+// WithRootCAs verifies the APIs certificate against pool rather than the system roots, e.g. for an internal CA
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(c *Client) {
+		c.tlsConfig().RootCAs = pool
+	}
+}
+
+// tlsConfig gives the transports TLS config, created the first time an option sets part of it so the options
+// combine rather than replacing each other
+func (c *Client) tlsConfig() *tls.Config {
+	t := c.roundTripper()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return t.TLSClientConfig
+}
+
+// customTLS gives the TLS config an option has set, nil when the defaults are used
+func (c *Client) customTLS() *tls.Config {
+	if t, ok := c.httpClient.Transport.(*http.Transport); ok {
+		return t.TLSClientConfig
+	}
+	return nil
+}
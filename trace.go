@@ -0,0 +1,49 @@
+package flags
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation scope the spans are recorded under
+const tracerName = "github.com/flags-gg/go-flags"
+
+// WithTracerProvider wraps every fetch in a flags.fetch span, with the source and how many retries it took, and every
+// evaluation in a flags.evaluate span, with the flag name, the result, and whether it came from the cache or a local
+// override. An evaluation through EnabledCtx is a child of the span in its context. A failure is recorded on the span
+// and sets its status. Without it nothing is traced
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *Client) {
+		c.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// startSpan starts a span on the tracer, or a span that does nothing when there isn't one
+func (c *Client) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, noop.Span{}
+	}
+	return c.tracer.Start(ctx, name)
+}
+
+// recordError records the failure on the span and marks it as failed
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// evaluationAttributes are the attributes of a flags.evaluate span
+func evaluationAttributes(name string, enabled, fallback bool) []attribute.KeyValue {
+	source := "cache"
+	if fallback {
+		source = "local"
+	}
+	return []attribute.KeyValue{
+		attribute.String("flags.name", name),
+		attribute.Bool("flags.result", enabled),
+		attribute.String("flags.source", source),
+	}
+}
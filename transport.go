@@ -0,0 +1,31 @@
+package flags
+
+import (
+	"context"
+)
+
+// transport is how the client gets the flag set, the cache and circuit breaker don't care which one is used
+type transport interface {
+	Fetch(ctx context.Context) (*ApiResponse, error)
+	// bind gives the same transport for another client, used when copying a client
+	bind(c *Client) transport
+	// source names the transport in a RefreshEvent
+	source() string
+}
+
+// httpTransport fetches the flags from the flags.gg HTTP API
+type httpTransport struct {
+	client *Client
+}
+
+func (t *httpTransport) Fetch(ctx context.Context) (*ApiResponse, error) {
+	return t.client.fetchFlags(ctx)
+}
+
+func (t *httpTransport) bind(c *Client) transport {
+	return &httpTransport{client: c}
+}
+
+func (t *httpTransport) source() string {
+	return "http"
+}
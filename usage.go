@@ -0,0 +1,48 @@
+package flags
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FlagUsage is how often a flag has been evaluated and when it was last evaluated
+type FlagUsage struct {
+	Evaluations   int64
+	LastEvaluated time.Time
+}
+
+type usageEntry struct {
+	evaluations   atomic.Int64
+	lastEvaluated atomic.Int64
+}
+
+type usageTracker struct {
+	flags sync.Map
+}
+
+func (u *usageTracker) record(name string) {
+	value, ok := u.flags.Load(name)
+	if !ok {
+		value, _ = u.flags.LoadOrStore(name, &usageEntry{})
+	}
+
+	entry := value.(*usageEntry)
+	entry.evaluations.Add(1)
+	entry.lastEvaluated.Store(time.Now().UnixNano())
+}
+
+// FlagUsage gives the usage of every flag that has been evaluated by the client
+func (c *Client) FlagUsage() map[string]FlagUsage {
+	usage := make(map[string]FlagUsage)
+	c.usage.flags.Range(func(key, value interface{}) bool {
+		entry := value.(*usageEntry)
+		usage[key.(string)] = FlagUsage{
+			Evaluations:   entry.evaluations.Load(),
+			LastEvaluated: time.Unix(0, entry.lastEvaluated.Load()),
+		}
+		return true
+	})
+
+	return usage
+}
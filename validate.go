@@ -0,0 +1,67 @@
+package flags
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Validate lints the local overrides, e.g. in CI, giving an error wrapping ErrInvalidOverride for each one that isn't
+// true or false (anything else evaluates as false), and each one for a flag that doesn't exist once the cache has
+// been refreshed (most likely a typo in the name). Without the flags from the API only the values are checked
+func (c *Client) Validate() []error {
+	var errs []error
+
+	var known map[string]bool
+	if err := c.refreshIfStale(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to refetch flags, the override names aren't checked: %w", err))
+	} else if flags, err := c.Cache.GetAllMap(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read flags, the override names aren't checked: %w", err))
+	} else {
+		known = make(map[string]bool, len(flags))
+		for name := range flags {
+			known[strings.ToLower(name)] = true
+		}
+	}
+
+	check := func(source, name, val string) {
+		if val != "true" && val != "false" {
+			errs = append(errs, fmt.Errorf("%w: %s is %q, it has to be true or false", ErrInvalidOverride, source, val))
+		}
+		if known != nil && !c.knownOverride(known, name) {
+			errs = append(errs, fmt.Errorf("%w: %s is for %s, there's no flag with that name", ErrInvalidOverride, source, strings.ToLower(name)))
+		}
+	}
+
+	for _, e := range envOverrides() {
+		key, val, ok := strings.Cut(e, "=")
+		if !ok {
+			continue
+		}
+		check("env var "+key, strings.TrimPrefix(key, "FLAGS_"), val)
+	}
+
+	if c.overrideDir != "" {
+		entries, err := os.ReadDir(c.overrideDir)
+		if err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("failed to read override dir: %w", err))
+		}
+		for _, entry := range entries {
+			// skipped the same as buildFileLocal skips them
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+
+			path := filepath.Join(c.overrideDir, entry.Name())
+			val, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to read override file: %w", err))
+				continue
+			}
+			check("override file "+path, entry.Name(), strings.TrimSpace(string(val)))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,25 @@
+package flags
+
+import (
+	"encoding/json"
+)
+
+// Value decodes the flags string value into T as JSON, e.g. 42 into an int or {"limit": 5} into a struct. A string T
+// is given the value as it is, so it doesn't need quoting. It's def when the flag is off, unknown, has no value, or
+// its value doesn't decode as a T. It's a func rather than a method since methods can't have type parameters
+func Value[T any](f *Flag, def T) T {
+	raw := f.String()
+	if raw == "" {
+		return def
+	}
+
+	var v T
+	if s, ok := any(&v).(*string); ok {
+		*s = raw
+		return v
+	}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return def
+	}
+	return v
+}
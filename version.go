@@ -0,0 +1,20 @@
+package flags
+
+// Version is the version of this client, it's sent to the API in the X-Flags-Client-Version header
+const Version = "0.1.0"
+
+// WithClientVersion adds the callers own build (e.g. its commit SHA) to the reported client version,
+// so the header becomes Version+v
+func WithClientVersion(v string) Option {
+	return func(c *Client) {
+		c.clientVersion = v
+	}
+}
+
+// reportedVersion is the value of the X-Flags-Client-Version header
+func (c *Client) reportedVersion() string {
+	if c.clientVersion == "" {
+		return Version
+	}
+	return Version + "+" + c.clientVersion
+}
@@ -0,0 +1,104 @@
+package flags
+
+import (
+	"sync"
+)
+
+// watcher is a single Watch subscription, last is the value it was last sent
+type watcher struct {
+	name string
+	ch   chan bool
+	last bool
+}
+
+// watchers are the subscriptions of a client, they're sent the new value after each refetch
+type watchers struct {
+	mu     sync.Mutex
+	subs   map[*watcher]struct{}
+	closed bool
+}
+
+// Watch gives a channel with the flags value, sent once straight away and then whenever a refetch changes it,
+// the channel is closed by the cancel func or Client.Close. Only the latest value is kept for a slow reader
+func (f *Flag) Watch() (<-chan bool, func()) {
+	c := f.Client
+	name := c.canonical(f.name())
+	c.isEnabled(name) // refresh if stale, so the first value is current
+
+	// hold the refetch lock so no change can land between reading the value and subscribing
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	w := &watcher{
+		name: name,
+		ch:   make(chan bool, 1),
+		last: c.value(name, ""),
+	}
+	w.ch <- w.last
+
+	if !c.watchers.add(w) {
+		close(w.ch)
+		return w.ch, func() {}
+	}
+	return w.ch, func() {
+		c.watchers.remove(w)
+	}
+}
+
+func (ws *watchers) add(w *watcher) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.closed {
+		return false
+	}
+	if ws.subs == nil {
+		ws.subs = make(map[*watcher]struct{})
+	}
+	ws.subs[w] = struct{}{}
+	return true
+}
+
+func (ws *watchers) remove(w *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if _, ok := ws.subs[w]; !ok {
+		return
+	}
+	delete(ws.subs, w)
+	close(w.ch)
+}
+
+// notify sends each watcher its flags value if it has changed, the caller holds the refetch lock
+func (ws *watchers) notify(c *Client) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	for w := range ws.subs {
+		enabled := c.value(w.name, "")
+		if enabled == w.last {
+			continue
+		}
+		w.last = enabled
+
+		// drop a value the reader hasn't taken yet, it's out of date
+		select {
+		case <-w.ch:
+		default:
+		}
+		w.ch <- enabled
+	}
+}
+
+// close closes every watchers channel, nothing can subscribe afterwards
+func (ws *watchers) close() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	for w := range ws.subs {
+		close(w.ch)
+	}
+	ws.subs = nil
+	ws.closed = true
+}
@@ -0,0 +1,178 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/flags-gg/go-flags/flag"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	minWebSocketRetry = time.Second
+	maxWebSocketRetry = 30 * time.Second
+)
+
+// WithWebSocket has the flags pushed over a WebSocket as they change, rather than only fetched once the cache is
+// stale. The gateway sends snapshot frames with the whole flag set and delta frames with only what changed. While the
+// connection is down it's retried in the background, and stale reads fetch over HTTP as they would without it
+func WithWebSocket(url string) Option {
+	return func(c *Client) {
+		c.webSocketURL = url
+	}
+}
+
+// pushMessage is a frame from the WebSocket gateway
+type pushMessage struct {
+	// Type is snapshot for the whole flag set, or delta for the flags that changed
+	Type            string             `json:"type"`
+	IntervalAllowed int                `json:"intervalAllowed"`
+	Flags           []flag.FeatureFlag `json:"flags"`
+	// Removed is the names of the flags a delta removes
+	Removed []string `json:"removed"`
+}
+
+// watchWebSocket keeps the WebSocket connected until ctx is done, the wait between attempts doubles while it can't
+// connect
+func (c *Client) watchWebSocket(ctx context.Context) {
+	retry := minWebSocketRetry
+	for {
+		connected, err := c.receivePushes(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			c.reportError(err)
+		}
+		if connected {
+			retry = minWebSocketRetry
+		}
+
+		timer := time.NewTimer(retry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		retry = min(retry*2, maxWebSocketRetry)
+	}
+}
+
+// receivePushes connects and applies each frame until the connection drops, it reports whether it connected at all
+func (c *Client) receivePushes(ctx context.Context) (bool, error) {
+	c.mutex.RLock()
+	auth := c.auth
+	err := c.checkAuth()
+	c.mutex.RUnlock()
+	if err != nil {
+		return false, err
+	}
+
+	config, err := websocket.NewConfig(c.webSocketURL, c.baseURL)
+	if err != nil {
+		return false, c.errorf("failed to configure websocket: %v", err)
+	}
+	config.Header = http.Header{}
+	config.Header.Set("User-Agent", "Flags-Go")
+	config.Header.Set("X-Flags-Client-Version", c.reportedVersion())
+	config.Header.Set("X-Project-ID", auth.ProjectID)
+	config.Header.Set("X-Agent-ID", auth.AgentID)
+	config.Header.Set("X-Environment-ID", auth.EnvironmentID)
+	if c.basicAuth != "" {
+		config.Header.Set("Authorization", c.basicAuth)
+	}
+	if tlsConfig := c.customTLS(); tlsConfig != nil {
+		config.TlsConfig = tlsConfig
+	}
+
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		return false, c.errorf("%w: failed to connect websocket: %w", ErrUpstream, err)
+	}
+
+	// closing the connection is what unblocks the read when the client is closed
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = conn.Close()
+	}()
+
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			if ctx.Err() != nil {
+				return true, nil
+			}
+			return true, c.errorf("%w: websocket connection lost: %w", ErrUpstream, err)
+		}
+
+		// a frame that can't be decoded is skipped, the connection is still good
+		var msg pushMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.reportError(c.errorf("%w: failed to decode websocket frame: %w", ErrDecode, err))
+			continue
+		}
+
+		current, err := c.push(auth, msg, data)
+		if err != nil {
+			c.reportError(err)
+		}
+		if !current {
+			// SetAuth has changed the auth, reconnect as the new one
+			return true, nil
+		}
+	}
+}
+
+// push applies a frame to the cache, it's false without applying it if the frame is for an auth that's been replaced.
+// A snapshot is decoded from data like a fetched response, so one without a flags list doesn't wipe the cache
+func (c *Client) push(auth Auth, msg pushMessage, data []byte) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.auth != auth {
+		return false, nil
+	}
+
+	start := time.Now()
+	switch msg.Type {
+	case "snapshot":
+		apiResp, err := decodeResponse(data)
+		if err != nil {
+			err = c.errorf("%w: failed to decode websocket snapshot: %w", ErrDecode, err)
+			c.reportRefresh(RefreshEvent{Source: "websocket", Duration: time.Since(start), Err: err})
+			return true, err
+		}
+		c.pushInterval = apiResp.IntervalAllowed
+		return true, c.apply(apiResp, "websocket", start)
+	case "delta":
+		byName, err := c.Cache.GetAllMap()
+		if err != nil {
+			err = c.errorf("%w: failed to read cache for delta: %w", ErrCacheUnavailable, err)
+			c.reportRefresh(RefreshEvent{Source: "websocket", Duration: time.Since(start), Err: err})
+			return true, err
+		}
+		for _, name := range msg.Removed {
+			delete(byName, strings.ToLower(name))
+		}
+		for _, f := range msg.Flags {
+			f.Details.Name = strings.ToLower(f.Details.Name)
+			byName[f.Details.Name] = f
+		}
+
+		flags := make([]flag.FeatureFlag, 0, len(byName))
+		for _, f := range byName {
+			flags = append(flags, f)
+		}
+		return true, c.apply(&ApiResponse{IntervalAllowed: c.pushInterval, Flags: flags}, "websocket", start)
+	default:
+		return true, c.errorf("%w: unknown websocket message type: %s", ErrDecode, msg.Type)
+	}
+}